@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/audit"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditConfig configures the Audit middleware
+type AuditConfig struct {
+	Methods []string      // audited methods, default POST, PUT, PATCH and DELETE
+	Timeout time.Duration // bound on the write once the response is sent, default 5s
+}
+
+// Audit records an audit entry for every request with an audited method, once it completed
+// The actor comes from the auth middleware, the action is the method and route template, the targets are the
+// route parameters and the outcome follows the response status. Handlers add details with AuditMetadata
+func Audit(writer audit.Writer, cfg AuditConfig) gin.HandlerFunc {
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	methods := map[string]bool{}
+	for _, method := range cfg.Methods {
+		methods[method] = true
+	}
+
+	return func(c *gin.Context) {
+		if !methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := audit.Entry{
+			ActorID:   c.GetString("user_id"),
+			ActorName: c.GetString("username"),
+			TenantID:  c.GetString("tenant_id"),
+			Action:    c.Request.Method + " " + c.FullPath(),
+			Outcome:   auditOutcome(status),
+			Status:    status,
+			RequestID: c.GetString("request_id"),
+			ClientIP:  c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		}
+		if len(c.Params) > 0 {
+			entry.Targets = make(map[string]string, len(c.Params))
+			for _, param := range c.Params {
+				entry.Targets[param.Key] = param.Value
+			}
+		}
+		if len(c.Errors) > 0 {
+			entry.Error = c.Errors.String()
+		}
+		if metadata, ok := c.Get(auditMetadataKey); ok {
+			entry.Metadata = metadata.(map[string]interface{})
+		}
+
+		// The entry is written even if the client went away
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), cfg.Timeout)
+		defer cancel()
+		if err := writer.Write(ctx, entry); err != nil {
+			log.Printf("Failed to write audit entry for %s: %v", entry.Action, err)
+		}
+	}
+}
+
+const auditMetadataKey = "audit_metadata"
+
+// AuditMetadata attaches a detail to the audit entry of the request, e.g. the changed fields
+func AuditMetadata(c *gin.Context, key string, value interface{}) {
+	metadata, ok := c.Get(auditMetadataKey)
+	if !ok {
+		metadata = map[string]interface{}{}
+		c.Set(auditMetadataKey, metadata)
+	}
+	metadata.(map[string]interface{})[key] = value
+}
+
+// auditOutcome maps a response status to an audit outcome
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return audit.OutcomeDenied
+	case status >= http.StatusBadRequest:
+		return audit.OutcomeFailure
+	}
+	return audit.OutcomeSuccess
+}
@@ -1,44 +1,29 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/gadhittana01/go-modules-v3/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+// AuthMiddleware is HTTPAuth for gin, it also sets "user_id" and "username" in the gin context
 func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
+	return wrapHTTP(HTTPAuth(), func(c *gin.Context) {
+		if claims, ok := utils.ClaimsFromContext(c.Request.Context()); ok {
+			c.Set("user_id", claims.UserID)
+			c.Set("username", claims.Username)
 		}
-
-		// Check if header starts with "Bearer "
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
-			return
-		}
-
-		// Extract token
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-
-		// Validate token using Redis
-		claims, err := utils.ValidateTokenWithRedis(c.Request.Context(), token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
-
-		// Set user info in context
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-
 		c.Next()
+	})
+}
+
+// tokenError tells expired tokens apart so clients know to refresh them
+func tokenError(err error) error {
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return utils.NewCodedErrorWithTrace(err, utils.CodeTokenExpired, "Token expired")
 	}
+	return utils.NewCustomErrorWithTrace(err, "Invalid token", http.StatusUnauthorized)
 }
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// responseCachePrefix prefixes the cache keys of CacheResponse
+const responseCachePrefix = "response:"
+
+// cachedResponse is a response stored by CacheResponse
+type cachedResponse struct {
+	Status int                 `json:"status"`
+	Header map[string][]string `json:"header"`
+	Body   []byte              `json:"body"`
+}
+
+// uncachedHeaders are never stored with a cached response
+// The encoding headers are set by an outer Compress on the shared header map while the recorded body is the
+// uncompressed one, Compress sets them again when the cached body is replayed through it
+var uncachedHeaders = []string{"Set-Cookie", "Date", "Connection", "Transfer-Encoding", "X-Request-Id",
+	"Content-Encoding", "Content-Length"}
+
+// CacheKeyByURL keys responses by path and query string, for responses that are the same for every client
+func CacheKeyByURL(c *gin.Context) string {
+	query := c.Request.URL.Query().Encode() // sorted by key
+	return responseCachePrefix + c.Request.URL.Path + "|" + query
+}
+
+// CacheKeyVaryBy keys responses by URL and the given context values, e.g. "user_id" or "tenant_id",
+// so responses depending on the authenticated user or tenant are never served to another one
+func CacheKeyVaryBy(keys ...string) func(c *gin.Context) string {
+	return func(c *gin.Context) string {
+		key := CacheKeyByURL(c)
+		for _, name := range keys {
+			key += "|" + c.GetString(name)
+		}
+		return key
+	}
+}
+
+// CacheResponse caches the 200 responses of GET requests in store for ttl and replays them with X-Cache: HIT
+// keyFunc defaults to CacheKeyByURL, use CacheKeyVaryBy on authenticated or tenant scoped routes.
+// Requests sent with Cache-Control: no-cache bypass the cache. Store failures let the request through
+func CacheResponse(store utils.CacheClient, ttl time.Duration, keyFunc func(c *gin.Context) string) gin.HandlerFunc {
+	if keyFunc == nil {
+		keyFunc = CacheKeyByURL
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet || strings.Contains(c.GetHeader("Cache-Control"), "no-cache") {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := keyFunc(c)
+
+		var cached cachedResponse
+		err := store.Get(ctx, key, &cached)
+		if err == nil {
+			for name, values := range cached.Header {
+				for _, value := range values {
+					c.Writer.Header().Add(name, value)
+				}
+			}
+			c.Header("X-Cache", "HIT")
+			c.Status(cached.Status)
+			c.Writer.Write(cached.Body)
+			c.Abort()
+			return
+		}
+		if !errors.Is(err, utils.ErrCacheMiss) {
+			log.Printf("Response cache unavailable: %v", err)
+		}
+
+		c.Header("X-Cache", "MISS")
+		recorder := newResponseRecorder(c)
+		c.Next()
+
+		if recorder.Status() != http.StatusOK {
+			return
+		}
+		header := recorder.Header().Clone()
+		for _, name := range uncachedHeaders {
+			header.Del(name)
+		}
+		header.Del("X-Cache")
+		dropVaryAcceptEncoding(header)
+
+		cached = cachedResponse{Status: recorder.Status(), Header: header, Body: recorder.body.Bytes()}
+		if err := store.Set(ctx, key, cached, ttl); err != nil {
+			log.Printf("Failed to cache response: %v", err)
+		}
+	}
+}
+
+// dropVaryAcceptEncoding removes the Accept-Encoding added to Vary by Compress, keeping the handler values
+func dropVaryAcceptEncoding(header http.Header) {
+	var kept []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !strings.EqualFold(name, "Accept-Encoding") {
+				kept = append(kept, name)
+			}
+		}
+	}
+	header.Del("Vary")
+	if len(kept) > 0 {
+		header.Set("Vary", strings.Join(kept, ", "))
+	}
+}
+
+// InvalidateCachedResponses drops the cached responses of every URL starting with pathPrefix
+// The store must support pattern deletion, like utils.RedisCache
+func InvalidateCachedResponses(ctx context.Context, store utils.CacheClient, pathPrefix string) error {
+	deleter, ok := store.(interface {
+		DeleteByPattern(ctx context.Context, pattern string) (int64, error)
+	})
+	if !ok {
+		return errors.New("cache store does not support pattern deletion")
+	}
+
+	_, err := deleter.DeleteByPattern(ctx, responseCachePrefix+escapeGlob(pathPrefix)+"*")
+	return err
+}
+
+// InvalidateCachedResponse drops the cached response stored under key, as returned by the key function
+func InvalidateCachedResponse(ctx context.Context, store utils.CacheClient, key string) error {
+	return store.Delete(ctx, key)
+}
+
+// escapeGlob escapes the Redis glob special characters of s
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
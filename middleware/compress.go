@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCompressContentTypes are the media types compressed when CompressConfig.ContentTypes is empty
+var DefaultCompressContentTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/css",
+	"text/csv",
+	"text/html",
+	"text/javascript",
+	"text/plain",
+	"text/xml",
+}
+
+// CompressConfig configures the Compress middleware, zero values use the defaults
+type CompressConfig struct {
+	Level        int      // gzip level, default gzip.DefaultCompression
+	BrotliLevel  int      // brotli level, default 4 which suits dynamic responses
+	MinSize      int      // smaller responses are sent as is, default 1024 bytes
+	ContentTypes []string // media types worth compressing, default DefaultCompressContentTypes
+}
+
+// Compress compresses responses with brotli or gzip according to the Accept-Encoding of the request
+// Only responses of the configured content types reaching MinSize are compressed, the body is buffered
+// until MinSize is reached so small responses keep their Content-Length
+func Compress(cfg CompressConfig) gin.HandlerFunc {
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+	if cfg.BrotliLevel == 0 {
+		cfg.BrotliLevel = 4
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1024
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressContentTypes
+	}
+	contentTypes := map[string]bool{}
+	for _, contentType := range cfg.ContentTypes {
+		contentTypes[contentType] = true
+	}
+
+	gzipWriters := sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, cfg.Level)
+		return w
+	}}
+	brotliWriters := sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, cfg.BrotliLevel)
+	}}
+
+	return func(c *gin.Context) {
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			minSize:        cfg.MinSize,
+			contentTypes:   contentTypes,
+		}
+		defer func() {
+			switch encoder := w.encoder.(type) {
+			case *gzip.Writer:
+				gzipWriters.Put(encoder)
+			case *brotli.Writer:
+				brotliWriters.Put(encoder)
+			}
+		}()
+		w.newEncoder = func(dst io.Writer) io.WriteCloser {
+			if encoding == "br" {
+				encoder := brotliWriters.Get().(*brotli.Writer)
+				encoder.Reset(dst)
+				return encoder
+			}
+			encoder := gzipWriters.Get().(*gzip.Writer)
+			encoder.Reset(dst)
+			return encoder
+		}
+
+		c.Writer = w
+		c.Next()
+		w.finish()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+// negotiateEncoding picks br or gzip from an Accept-Encoding header, empty when neither is accepted
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	switch {
+	case accepted["br"]:
+		return "br"
+	case accepted["gzip"], accepted["*"]:
+		return "gzip"
+	}
+	return ""
+}
+
+// compressWriter buffers the beginning of the body to decide whether it is worth compressing
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding     string
+	minSize      int
+	contentTypes map[string]bool
+	newEncoder   func(io.Writer) io.WriteCloser
+
+	buffer  bytes.Buffer
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buffer.Write(data)
+	if w.buffer.Len() >= w.minSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow is delayed until the body is known, Write and finish send the headers
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *compressWriter) Written() bool {
+	return w.buffer.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide sets the compression headers when the response qualifies and writes the buffered body
+func (w *compressWriter) decide() error {
+	w.decided = true
+
+	header := w.Header()
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	status := w.Status()
+	if w.buffer.Len() >= w.minSize && w.contentTypes[mediaType] && header.Get("Content-Encoding") == "" &&
+		status != http.StatusNoContent && status != http.StatusNotModified {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.encoder = w.newEncoder(w.ResponseWriter)
+	}
+
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	data := w.buffer.Bytes()
+	w.buffer = bytes.Buffer{}
+	if w.encoder != nil {
+		_, err := w.encoder.Write(data)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(data)
+	return err
+}
+
+// finish writes what is still buffered and terminates the compressed stream
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if w.buffer.Len() == 0 {
+			// Nothing was written, let gin send the status alone
+			w.decided = true
+			return
+		}
+		w.decide()
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
@@ -2,47 +2,66 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
+	"github.com/gadhittana01/go-modules-v3/utils"
 	"github.com/gin-gonic/gin"
 )
 
 // CORS middleware
 func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-
-		// Allow specific origins (including localhost for development)
-		allowedOrigins := []string{
-			"http://localhost:3000",
-			"http://localhost:3001",
-			"https://sharehub.gadhittana.com", // Add your production domain
-		}
-
-		// Check if origin is in allowed list
-		isAllowed := false
-		for _, allowedOrigin := range allowedOrigins {
-			if origin == allowedOrigin {
-				isAllowed = true
-				break
+	return CORSWithConfig(utils.CORSConfig{
+		AllowedOrigins:   utils.DefaultCORSOrigins,
+		AllowedMethods:   []string{"POST", "OPTIONS", "GET", "PUT", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "accept", "origin", "Cache-Control", "X-Requested-With"},
+		AllowCredentials: true,
+	})
+}
+
+// CORSWithConfig is the CORS middleware using the allowlist of cfg (Config.CORS)
+func CORSWithConfig(cfg utils.CORSConfig) gin.HandlerFunc {
+	return WrapHTTP(HTTPCORS(cfg))
+}
+
+// HTTPCORS is the net/http version of CORSWithConfig
+func HTTPCORS(cfg utils.CORSConfig) HTTPMiddleware {
+	allowedOrigins := cfg.AllowedOrigins
+	allowedHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	allowedMethods := strings.Join(cfg.AllowedMethods, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+
+			// Check if origin is in allowed list
+			isAllowed := false
+			for _, allowedOrigin := range allowedOrigins {
+				if origin == allowedOrigin {
+					isAllowed = true
+					break
+				}
 			}
-		}
 
-		// Set origin header if allowed, otherwise use first allowed origin as default
-		if isAllowed {
-			c.Header("Access-Control-Allow-Origin", origin)
-		} else if len(allowedOrigins) > 0 {
-			c.Header("Access-Control-Allow-Origin", allowedOrigins[0])
-		}
+			// Set origin header if allowed, otherwise use first allowed origin as default
+			header := w.Header()
+			if isAllowed {
+				header.Set("Access-Control-Allow-Origin", origin)
+			} else if len(allowedOrigins) > 0 {
+				header.Set("Access-Control-Allow-Origin", allowedOrigins[0])
+			}
 
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+			if cfg.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			header.Set("Access-Control-Allow-Headers", allowedHeaders)
+			header.Set("Access-Control-Allow-Methods", allowedMethods)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
 
-		c.Next()
+			next.ServeHTTP(w, r)
+		})
 	}
 }
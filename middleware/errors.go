@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gadhittana01/go-modules-v3/utils/response"
+	"github.com/gin-gonic/gin"
+)
+
+// abortWithError aborts the request with the error envelope of utils/response
+func abortWithError(c *gin.Context, err error) {
+	response.Error(c, err)
+}
+
+// writeHTTPError is abortWithError for net/http handlers
+func writeHTTPError(w http.ResponseWriter, r *http.Request, err error) {
+	response.WriteError(w, r, err)
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag adds a weak ETag computed from the body to 200 responses of GET and HEAD requests that have none,
+// and answers 304 Not Modified when If-None-Match or If-Modified-Since show the client copy is current.
+// Responses are buffered, so do not use it on streaming routes. Handlers that know the version of the
+// entity can call CheckNotModified first to skip the work entirely
+func ETag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.Status() != http.StatusOK {
+			w.flush()
+			return
+		}
+
+		header := w.Header()
+		if header.Get("ETag") == "" {
+			sum := sha256.Sum256(w.body.Bytes())
+			header.Set("ETag", `W/"`+hex.EncodeToString(sum[:16])+`"`)
+		}
+
+		if notModified(c.Request, header.Get("ETag"), header.Get("Last-Modified")) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			w.ResponseWriter.WriteHeaderNow()
+			return
+		}
+		w.flush()
+	}
+}
+
+// SetETag sets a strong ETag from an entity version, e.g. a revision number or updated_at timestamp
+func SetETag(c *gin.Context, version string) {
+	c.Header("ETag", `"`+version+`"`)
+}
+
+// SetLastModified sets the Last-Modified header
+func SetLastModified(c *gin.Context, t time.Time) {
+	c.Header("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// CheckNotModified sets the ETag (when version is not empty) and Last-Modified (when t is not zero) headers
+// and answers 304 when the client copy is current, returning true so the handler can return immediately
+func CheckNotModified(c *gin.Context, version string, t time.Time) bool {
+	if version != "" {
+		SetETag(c, version)
+	}
+	if !t.IsZero() {
+		SetLastModified(c, t)
+	}
+
+	if !notModified(c.Request, c.Writer.Header().Get("ETag"), c.Writer.Header().Get("Last-Modified")) {
+		return false
+	}
+	c.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is no If-None-Match (RFC 9110 13.2.2)
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// Weak comparison, W/"x" matches "x"
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// bufferedWriter holds the whole body until flush so the status can still change
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *bufferedWriter) flush() {
+	if w.body.Len() == 0 {
+		return
+	}
+	w.ResponseWriter.Write(w.body.Bytes())
+}
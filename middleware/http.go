@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPMiddleware is the net/http middleware signature
+// chi uses it as is (router.Use), echo wraps it with echo.WrapMiddleware and gin with WrapHTTP
+type HTTPMiddleware func(http.Handler) http.Handler
+
+// WrapHTTP adapts a net/http middleware to gin, the chain is aborted when the middleware does not call next
+// Values the middleware adds to the request context are visible to the following gin handlers
+func WrapHTTP(mw HTTPMiddleware) gin.HandlerFunc {
+	return wrapHTTP(mw, (*gin.Context).Next)
+}
+
+// wrapHTTP is WrapHTTP calling next in place of c.Next, so gin versions of the middleware can copy what the
+// net/http version added to the request into the gin context
+func wrapHTTP(mw HTTPMiddleware, next func(c *gin.Context)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		called, completed := false, false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			c.Request = r
+			next(c)
+			completed = true
+		})
+
+		mw(handler).ServeHTTP(c.Writer, c.Request)
+		if !called || !completed {
+			// The middleware answered on its own or recovered a panic of the chain, the remaining handlers must not run
+			c.Abort()
+		}
+	}
+}
+
+// HTTPAuth validates the bearer token of the Authorization header with utils.ValidateTokenWithRedis
+// and stores its claims in the request context, available with utils.ClaimsFromContext
+func HTTPAuth() HTTPMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				writeHTTPError(w, r, utils.NewCustomError("Authorization header required", http.StatusUnauthorized))
+				return
+			}
+			if !strings.HasPrefix(authHeader, "Bearer ") {
+				writeHTTPError(w, r, utils.NewCustomError("Invalid authorization header format", http.StatusUnauthorized))
+				return
+			}
+
+			claims, err := utils.ValidateTokenWithRedis(r.Context(), strings.TrimPrefix(authHeader, "Bearer "))
+			if err != nil {
+				writeHTTPError(w, r, tokenError(err))
+				return
+			}
+
+			if fields, ok := r.Context().Value(logFieldsKey{}).(*logFields); ok {
+				fields.userID = claims.UserID
+			}
+			next.ServeHTTP(w, r.WithContext(utils.ContextWithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// HTTPRequestID reuses the X-Request-ID header of the request or generates a UUID, stores it in the request
+// context (utils.RequestIDFromContext) and echoes it in the response header
+// It should be the first middleware so logs and errors of the others carry the ID
+func HTTPRequestID() HTTPMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := incomingRequestID(r)
+			w.Header().Set(utils.RequestIDHeader, requestID)
+			if fields, ok := r.Context().Value(logFieldsKey{}).(*logFields); ok {
+				fields.requestID = requestID
+			}
+			next.ServeHTTP(w, r.WithContext(utils.ContextWithRequestID(r.Context(), requestID)))
+		})
+	}
+}
+
+// HTTPRecovery turns panics into error responses
+// Panics raised with utils.PanicAppError and friends answer with the status and message of the
+// CustomError, other panics are logged with their stack and answer 500. Panics are sent to the global
+// utils.ErrorReporter, and nothing is written when the handler already started the response
+func HTTPRecovery(logger *slog.Logger) HTTPMiddleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w, tracker := trackResponse(w)
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					err := recoveredError(r, logger, recovered)
+					if errors.Is(err, http.ErrAbortHandler) {
+						panic(err)
+					}
+					reportPanic(r, err)
+					if !tracker.Written() {
+						writeHTTPError(w, r, err)
+					}
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// logFieldsKey carries the fields inner middleware report to HTTPRequestLogger
+type logFieldsKey struct{}
+
+type logFields struct {
+	requestID string
+	userID    string
+	route     string
+	clientIP  string
+	errors    string
+}
+
+// HTTPRequestLogger logs method, path, status, latency, response size, client IP, request ID and user ID
+// of each request once it completed. Use a slog.JSONHandler logger for JSON output.
+// 5xx responses are logged at error level and 4xx at warn level. Routers without route templates log the
+// path only, the request ID and user ID are reported by HTTPRequestID and HTTPAuth
+func HTTPRequestLogger(logger *slog.Logger, cfg RequestLoggerConfig) HTTPMiddleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	skip := map[string]bool{}
+	for _, path := range cfg.SkipPaths {
+		skip[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			fields := &logFields{}
+			w, tracker := trackResponse(w)
+			r = r.WithContext(context.WithValue(r.Context(), logFieldsKey{}, fields))
+			next.ServeHTTP(w, r)
+
+			status := tracker.Status()
+			level, ok := requestLogLevel(status, cfg.SampleRate)
+			if !ok {
+				return
+			}
+
+			if fields.clientIP == "" {
+				fields.clientIP = r.RemoteAddr
+				if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+					fields.clientIP = host
+				}
+			}
+			if fields.requestID == "" {
+				fields.requestID = utils.RequestIDFromContext(r.Context())
+			}
+			if fields.requestID == "" {
+				fields.requestID = r.Header.Get(utils.RequestIDHeader)
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			}
+			if fields.route != "" {
+				attrs = append(attrs, slog.String("route", fields.route))
+			}
+			attrs = append(attrs,
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int("size", tracker.Size()),
+				slog.String("client_ip", fields.clientIP),
+				slog.String("user_agent", r.UserAgent()),
+			)
+			if fields.requestID != "" {
+				attrs = append(attrs, slog.String("request_id", fields.requestID))
+			}
+			if fields.userID != "" {
+				attrs = append(attrs, slog.String("user_id", fields.userID))
+			}
+			if fields.errors != "" {
+				attrs = append(attrs, slog.String("errors", fields.errors))
+			}
+
+			logger.LogAttrs(r.Context(), level, "request completed", attrs...)
+		})
+	}
+}
+
+// responseTracker is implemented by writers recording the response, like gin.ResponseWriter and statusRecorder
+type responseTracker interface {
+	Status() int
+	Size() int
+	Written() bool
+}
+
+// trackResponse returns w with its tracker, wrapping w in a statusRecorder unless it records the response already
+func trackResponse(w http.ResponseWriter) (http.ResponseWriter, responseTracker) {
+	if tracker, ok := w.(responseTracker); ok {
+		return w, tracker
+	}
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	return recorder, recorder
+}
+
+// statusRecorder captures the status and size of a net/http response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(data)
+	r.size += n
+	return n, err
+}
+
+func (r *statusRecorder) Status() int {
+	return r.status
+}
+
+func (r *statusRecorder) Size() int {
+	return r.size
+}
+
+func (r *statusRecorder) Written() bool {
+	return r.wroteHeader
+}
+
+// Unwrap lets http.ResponseController reach Flush and Hijack of the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client generated idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyConfig configures the Idempotency middleware
+type IdempotencyConfig struct {
+	TTL     time.Duration // how long responses are replayed (default 24h)
+	LockTTL time.Duration // how long a request in progress blocks duplicates if the instance dies (default 1m)
+	Methods []string      // methods honoring the header (default POST and PATCH)
+	Prefix  string        // Redis key prefix (default "idempotency:")
+}
+
+// idempotencyRecord is the state stored under an idempotency key
+type idempotencyRecord struct {
+	Done        bool                `json:"done"`
+	Fingerprint string              `json:"fingerprint"`
+	Status      int                 `json:"status,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+// Idempotency replays the stored response of requests repeating an Idempotency-Key, see IdempotencyWithConfig
+func Idempotency(client redis.Cmdable) gin.HandlerFunc {
+	return IdempotencyWithConfig(client, IdempotencyConfig{})
+}
+
+// IdempotencyWithConfig executes the first request of an Idempotency-Key and caches its response for cfg.TTL
+// Duplicates replay the stored response, duplicates arriving while the first request is in progress get 409
+// and reusing a key with a different body gets 422. Keys are scoped per user and route, so it must be mounted
+// after AuthMiddleware or SessionMiddleware, requests sending a key without an authenticated user get 401.
+// Server errors are not stored so the client can retry. An unreachable store lets the request through,
+// but a duplicate whose record cannot be read gets 503 rather than running twice
+func IdempotencyWithConfig(client redis.Cmdable, cfg IdempotencyConfig) gin.HandlerFunc {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = time.Minute
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "idempotency:"
+	}
+	methods := map[string]bool{}
+	for _, method := range cfg.Methods {
+		methods[method] = true
+	}
+
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" || !methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+
+		userID := c.GetString("user_id")
+		if userID == "" {
+			// Without a user every anonymous client would share, and could replay, the same keys
+			abortWithError(c, utils.NewCustomError("Idempotency-Key requires an authenticated request", http.StatusUnauthorized))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, utils.NewCustomError("Failed to read request body", http.StatusBadRequest))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		ctx := c.Request.Context()
+		key := cfg.Prefix + userID + ":" + c.Request.Method + ":" + c.FullPath() + ":" + idempotencyKey
+
+		pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+		acquired, err := client.SetNX(ctx, key, pending, cfg.LockTTL).Result()
+		if err != nil {
+			log.Printf("Idempotency store unavailable: %v", err)
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			replayIdempotent(c, client, key, fingerprint)
+			return
+		}
+
+		recorder := newResponseRecorder(c)
+		c.Next()
+
+		// The side effect has run, so the outcome must be stored even if the client disconnected meanwhile,
+		// otherwise the key stays pending and a retry after LockTTL would run it again
+		storeCtx := context.WithoutCancel(ctx)
+
+		// Let the client retry requests that failed on our side
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := client.Del(storeCtx, key).Err(); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+			return
+		}
+
+		done, err := json.Marshal(idempotencyRecord{
+			Done:        true,
+			Fingerprint: fingerprint,
+			Status:      recorder.Status(),
+			Header:      recorder.Header().Clone(),
+			Body:        recorder.body.Bytes(),
+		})
+		if err == nil {
+			err = client.Set(storeCtx, key, done, cfg.TTL).Err()
+		}
+		if err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	}
+}
+
+// replayIdempotent answers a duplicate request from the stored record
+// SetNX showed the key exists, so a record that cannot be read fails closed instead of running a known duplicate
+func replayIdempotent(c *gin.Context, client redis.Cmdable, key, fingerprint string) {
+	data, err := client.Get(c.Request.Context(), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// The first request failed and released the key in the meantime
+		abortWithError(c, utils.NewCustomError("A request with this idempotency key is being processed", http.StatusConflict))
+		return
+	}
+
+	var record idempotencyRecord
+	if err == nil {
+		err = json.Unmarshal(data, &record)
+	}
+	if err != nil {
+		log.Printf("Failed to read idempotency record: %v", err)
+		abortWithError(c, utils.NewCustomError("Idempotency store unavailable, retry later", http.StatusServiceUnavailable))
+		return
+	}
+
+	if record.Fingerprint != fingerprint {
+		abortWithError(c, utils.NewCustomError("Idempotency key reused with a different request body", http.StatusUnprocessableEntity))
+		return
+	}
+	if !record.Done {
+		abortWithError(c, utils.NewCustomError("A request with this idempotency key is being processed", http.StatusConflict))
+		return
+	}
+
+	for name, values := range record.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(record.Status)
+	c.Writer.Write(record.Body)
+	c.Abort()
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// IP filter modes
+const (
+	IPFilterAllow = "allow" // only the listed ranges may pass
+	IPFilterDeny  = "deny"  // the listed ranges are rejected
+)
+
+// IPFilterConfig configures the IPFilter middleware
+type IPFilterConfig struct {
+	Mode           string   // IPFilterAllow (default) or IPFilterDeny
+	Ranges         []string // CIDR ranges or single addresses, e.g. "10.8.0.0/16" or "203.0.113.7"
+	TrustedProxies []string // proxies whose X-Forwarded-For is honored, CIDR ranges or single addresses
+}
+
+// IPFilter restricts requests by client IP, e.g. to lock admin routes to the VPN range
+// The client IP is the remote address, or when it is a trusted proxy the right-most X-Forwarded-For
+// entry that is not a trusted proxy, so clients cannot spoof it by sending the header themselves
+func IPFilter(cfg IPFilterConfig) (gin.HandlerFunc, error) {
+	if cfg.Mode == "" {
+		cfg.Mode = IPFilterAllow
+	}
+	if cfg.Mode != IPFilterAllow && cfg.Mode != IPFilterDeny {
+		return nil, fmt.Errorf("unknown ip filter mode %q", cfg.Mode)
+	}
+
+	ranges, err := parsePrefixes(cfg.Ranges)
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := parsePrefixes(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		ip, ok := clientIP(c.Request, trusted)
+		listed := ok && containsAddr(ranges, ip)
+
+		if (cfg.Mode == IPFilterAllow && !listed) || (cfg.Mode == IPFilterDeny && listed) {
+			abortWithError(c, utils.NewCustomError("Access denied", http.StatusForbidden))
+			return
+		}
+		c.Next()
+	}, nil
+}
+
+// parsePrefixes parses CIDR ranges and single addresses
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ip %q: %w", value, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client, walking X-Forwarded-For back through trusted proxies
+func clientIP(r *http.Request, trusted []netip.Prefix) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	ip = ip.Unmap()
+
+	if !containsAddr(trusted, ip) {
+		return ip, true
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed entry cannot be trusted, neither can anything before it
+			return ip, true
+		}
+		ip = hop.Unmap()
+		if !containsAddr(trusted, ip) {
+			return ip, true
+		}
+	}
+	return ip, true
+}
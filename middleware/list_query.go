@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gadhittana01/go-modules-v3/repository/pagination"
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ListQuery parses the page, limit, sort and filter[field] query parameters against cfg, answering 400 when
+// they are invalid. The result is stored as "list_query" and in the request context (pagination.ListQueryFromContext)
+func ListQuery(cfg pagination.ListQueryConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := pagination.ParseListQuery(c.Request.URL.Query(), cfg)
+		if err != nil {
+			abortWithError(c, utils.NewCustomErrorWithTrace(err, err.Error(), http.StatusBadRequest))
+			return
+		}
+
+		c.Set("list_query", query)
+		c.Request = c.Request.WithContext(pagination.ContextWithListQuery(c.Request.Context(), query))
+
+		c.Next()
+	}
+}
+
+// GetListQuery returns the list query parsed by ListQuery, the default page when the middleware did not run
+func GetListQuery(c *gin.Context) pagination.ListQuery {
+	if query, ok := c.Get("list_query"); ok {
+		return query.(pagination.ListQuery)
+	}
+	return pagination.ListQuery{
+		PageRequest: pagination.PageRequest{Page: 1, Limit: pagination.DefaultLimit},
+		Filters:     map[string]string{},
+	}
+}
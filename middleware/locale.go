@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gadhittana01/go-modules-v3/utils/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// Locale negotiates the language of the request against the languages of bundle (i18n.DefaultBundle when nil):
+// the lang query parameter wins over Accept-Language. The language is stored as "locale" and in the request
+// context so i18n.T and the error responses use it, and is echoed in Content-Language
+func Locale(bundle *i18n.Bundle) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b := bundle
+		if b == nil {
+			b = i18n.DefaultBundle()
+		}
+
+		preference := c.GetHeader("Accept-Language")
+		if lang := c.Query("lang"); lang != "" {
+			preference = lang
+		}
+		locale := b.Match(preference)
+
+		c.Set("locale", locale)
+		c.Request = c.Request.WithContext(i18n.ContextWithLocale(c.Request.Context(), locale))
+		c.Header("Content-Language", locale)
+
+		c.Next()
+	}
+}
+
+// GetLocale returns the language negotiated by Locale
+func GetLocale(c *gin.Context) string {
+	return c.GetString("locale")
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"log/slog"
+	"math/rand"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestLoggerConfig configures the RequestLogger middleware
+type RequestLoggerConfig struct {
+	SkipPaths  []string // exact paths never logged, e.g. /healthz and /metrics
+	SampleRate float64  // fraction of successful requests logged, 0 logs all. Client and server errors are always logged
+}
+
+// RequestLogger logs every request with the structured fields of RequestLoggerWithConfig
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return RequestLoggerWithConfig(logger, RequestLoggerConfig{})
+}
+
+// RequestLoggerWithConfig is HTTPRequestLogger for gin, replacing gin.Logger
+// It also logs the route template, the client IP resolved by gin and the errors added to the gin context
+func RequestLoggerWithConfig(logger *slog.Logger, cfg RequestLoggerConfig) gin.HandlerFunc {
+	return wrapHTTP(HTTPRequestLogger(logger, cfg), func(c *gin.Context) {
+		c.Next()
+
+		fields, ok := c.Request.Context().Value(logFieldsKey{}).(*logFields)
+		if !ok {
+			return
+		}
+		fields.route = c.FullPath()
+		fields.clientIP = c.ClientIP()
+		if requestID := c.GetString("request_id"); requestID != "" {
+			fields.requestID = requestID
+		}
+		if userID := c.GetString("user_id"); userID != "" {
+			fields.userID = userID
+		}
+		if len(c.Errors) > 0 {
+			fields.errors = c.Errors.String()
+		}
+	})
+}
+
+// requestLogLevel returns the level of a request log by status, ok is false for unsampled successful requests
+func requestLogLevel(status int, sampleRate float64) (slog.Level, bool) {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError, true
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn, true
+	case sampleRate > 0 && sampleRate < 1 && rand.Float64() >= sampleRate:
+		return slog.LevelInfo, false
+	}
+	return slog.LevelInfo, true
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// MaintenanceFlag reports whether maintenance mode is enabled, it is checked on every request
+type MaintenanceFlag interface {
+	Enabled(ctx context.Context) (bool, error)
+}
+
+// MaintenanceFlagFunc adapts a function to MaintenanceFlag
+type MaintenanceFlagFunc func(ctx context.Context) (bool, error)
+
+func (f MaintenanceFlagFunc) Enabled(ctx context.Context) (bool, error) {
+	return f(ctx)
+}
+
+// EnvMaintenanceFlag enables maintenance while the environment variable is true
+func EnvMaintenanceFlag(key string) MaintenanceFlag {
+	return MaintenanceFlagFunc(func(ctx context.Context) (bool, error) {
+		value := os.Getenv(key)
+		if value == "" {
+			return false, nil
+		}
+		return strconv.ParseBool(value)
+	})
+}
+
+// FileMaintenanceFlag enables maintenance while the file exists, e.g. touched by a deploy script
+func FileMaintenanceFlag(path string) MaintenanceFlag {
+	return MaintenanceFlagFunc(func(ctx context.Context) (bool, error) {
+		_, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// RedisMaintenanceFlag enables maintenance on every instance while the key exists
+// The result is cached for refresh (1s when <= 0) so Redis is not queried on every request. A single request
+// refreshes it while the others get the cached value, and a failed refresh keeps the last value until the next one
+func RedisMaintenanceFlag(client redis.UniversalClient, key string, refresh time.Duration) MaintenanceFlag {
+	if refresh <= 0 {
+		refresh = time.Second
+	}
+
+	var mu sync.Mutex
+	var enabled, refreshing bool
+	var checkedAt time.Time
+	return MaintenanceFlagFunc(func(ctx context.Context) (bool, error) {
+		mu.Lock()
+		if refreshing || time.Since(checkedAt) < refresh {
+			cached := enabled
+			mu.Unlock()
+			return cached, nil
+		}
+		refreshing = true
+		mu.Unlock()
+
+		n, err := client.Exists(ctx, key).Result()
+
+		mu.Lock()
+		defer mu.Unlock()
+		refreshing, checkedAt = false, time.Now()
+		if err != nil {
+			return enabled, err
+		}
+		enabled = n > 0
+		return enabled, nil
+	})
+}
+
+// MaintenanceConfig configures the Maintenance middleware
+type MaintenanceConfig struct {
+	Flag           MaintenanceFlag
+	RetryAfter     time.Duration // advertised in Retry-After, default 5m
+	Message        string        // default "Service under maintenance"
+	AllowPaths     []string      // paths served during maintenance, default /healthz, /livez, /readyz and /metrics
+	AllowIPs       []string      // CIDR ranges or addresses of admins served during maintenance
+	TrustedProxies []string      // see IPFilterConfig.TrustedProxies
+}
+
+// Maintenance answers 503 while flag is enabled, except for the health check paths
+func Maintenance(flag MaintenanceFlag) gin.HandlerFunc {
+	handler, _ := MaintenanceWithConfig(MaintenanceConfig{Flag: flag})
+	return handler
+}
+
+// MaintenanceWithConfig answers 503 with Retry-After while cfg.Flag is enabled, so traffic can be drained
+// during migrations. Allowed paths and IPs keep being served. Flag errors are logged and ignored
+func MaintenanceWithConfig(cfg MaintenanceConfig) (gin.HandlerFunc, error) {
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = 5 * time.Minute
+	}
+	if cfg.Message == "" {
+		cfg.Message = "Service under maintenance"
+	}
+	if cfg.AllowPaths == nil {
+		cfg.AllowPaths = []string{"/healthz", "/livez", "/readyz", "/metrics"}
+	}
+	allowedPaths := map[string]bool{}
+	for _, path := range cfg.AllowPaths {
+		allowedPaths[path] = true
+	}
+
+	allowedIPs, err := parsePrefixes(cfg.AllowIPs)
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := parsePrefixes(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	retryAfter := strconv.Itoa(int(cfg.RetryAfter.Seconds()))
+
+	return func(c *gin.Context) {
+		enabled, err := cfg.Flag.Enabled(c.Request.Context())
+		if err != nil {
+			log.Printf("Failed to read maintenance flag: %v", err)
+		}
+		if !enabled || allowedPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		if ip, ok := clientIP(c.Request, trusted); ok && containsAddr(allowedIPs, ip) {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", retryAfter)
+		abortWithError(c, utils.NewCustomError(cfg.Message, http.StatusServiceUnavailable))
+	}, nil
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics records RED metrics of every request in the default Prometheus registry, see MetricsWithRegisterer
+func Metrics() gin.HandlerFunc {
+	return MetricsWithRegisterer(prometheus.DefaultRegisterer)
+}
+
+// MetricsWithRegisterer records http_requests_total, http_request_duration_seconds and http_requests_in_flight
+// Requests are labeled by route template rather than path so IDs do not explode the label cardinality,
+// requests matching no route are labeled "unmatched"
+func MetricsWithRegisterer(registerer prometheus.Registerer) gin.HandlerFunc {
+	requests := utils.RegisterCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"}))
+	duration := utils.RegisterCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"}))
+	inFlight := utils.RegisterCollector(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Number of HTTP requests being served.",
+	}))
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		requests.WithLabelValues(route, c.Request.Method, status).Inc()
+		duration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsHandler serves the metrics of the default Prometheus registry
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// MountMetrics serves the default Prometheus registry on path (/metrics when empty)
+func MountMetrics(router gin.IRoutes, path string) {
+	if path == "" {
+		path = "/metrics"
+	}
+	router.GET(path, MetricsHandler())
+}
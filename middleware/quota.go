@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/response"
+	"github.com/gin-gonic/gin"
+)
+
+// QuotaResolver returns the quota subject (user ID or API key) and plan of a request, ok is false to skip the quota
+type QuotaResolver func(c *gin.Context) (subject string, plan utils.QuotaPlan, ok bool)
+
+// Quota enforces the plan quotas returned by resolve, answering 429 once a hard limit is reached.
+// The X-Quota-* headers report the most constrained limit and X-Quota-Warning is set past a soft limit.
+// Manager failures let the request through so an unavailable Redis does not take the API down
+func Quota(manager *utils.QuotaManager, resolve QuotaResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject, plan, ok := resolve(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		allowed, usage, err := manager.Consume(c.Request.Context(), subject, plan, 1)
+		if err != nil {
+			log.Printf("Quota manager unavailable: %v", err)
+			c.Next()
+			return
+		}
+		setQuotaHeaders(c, usage)
+
+		if !allowed {
+			for _, u := range usage {
+				if u.Exceeded {
+					c.Header("Retry-After", strconv.Itoa(int(time.Until(u.ResetAt).Seconds())+1))
+					break
+				}
+			}
+			abortWithError(c, utils.NewCodedError(utils.CodeQuotaExceeded, "Quota exceeded"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// QuotaUsageHandler reports the usage of the plan quotas of the caller, e.g. mounted on GET /v1/usage
+func QuotaUsageHandler(manager *utils.QuotaManager, resolve QuotaResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject, plan, ok := resolve(c)
+		if !ok {
+			abortWithError(c, utils.NewCustomError("No quota plan", http.StatusNotFound))
+			return
+		}
+
+		usage, err := manager.Usage(c.Request.Context(), subject, plan)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		response.OK(c, gin.H{"plan": plan.Name, "usage": usage})
+	}
+}
+
+// setQuotaHeaders reports the hard limit with the least remaining usage
+func setQuotaHeaders(c *gin.Context, usage []utils.QuotaUsage) {
+	var tightest *utils.QuotaUsage
+	for i, u := range usage {
+		if u.SoftExceeded {
+			c.Header("X-Quota-Warning", string(u.Period)+" soft limit exceeded")
+		}
+		if u.Hard > 0 && (tightest == nil || u.Remaining() < tightest.Remaining()) {
+			tightest = &usage[i]
+		}
+	}
+	if tightest == nil {
+		return
+	}
+
+	c.Header("X-Quota-Limit", strconv.FormatInt(tightest.Hard, 10))
+	c.Header("X-Quota-Remaining", strconv.FormatInt(tightest.Remaining(), 10))
+	c.Header("X-Quota-Reset", strconv.FormatInt(tightest.ResetAt.Unix(), 10))
+}
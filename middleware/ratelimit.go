@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitByIP keys rate limits by client IP
+func RateLimitByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimitByUser keys rate limits by the authenticated user (see AuthMiddleware), falling back to the client IP
+func RateLimitByUser(c *gin.Context) string {
+	if userID := c.GetString("user_id"); userID != "" {
+		return "user:" + userID
+	}
+	return RateLimitByIP(c)
+}
+
+// RateLimit limits requests to limit per window for each key, using the global Redis client
+// If no global client is set requests are not limited
+func RateLimit(keyFunc func(c *gin.Context) string, limit int, window time.Duration) gin.HandlerFunc {
+	client := utils.GetGlobalRedisClient()
+	if client == nil {
+		log.Println("Warning: rate limiting disabled, global Redis client not set")
+		return func(c *gin.Context) { c.Next() }
+	}
+	return RateLimitWithLimiter(utils.NewRedisRateLimiter(client, limit, window), keyFunc)
+}
+
+// RateLimitWithLimiter limits requests with any RateLimiter, setting the X-RateLimit-* headers
+// Limiter failures let the request through so an unavailable Redis does not take the API down
+func RateLimitWithLimiter(limiter utils.RateLimiter, keyFunc func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := limiter.Allow(c.Request.Context(), c.FullPath()+"|"+keyFunc(c))
+		if err != nil {
+			log.Printf("Rate limiter unavailable: %v", err)
+			c.Next()
+			return
+		}
+
+		resetSeconds := int(math.Ceil(result.ResetAfter.Seconds()))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(resetSeconds))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(resetSeconds))
+			abortWithError(c, utils.NewCodedError(utils.CodeRateLimited, "Too many requests"))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseRecorder copies the response body while it is written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func newResponseRecorder(c *gin.Context) *responseRecorder {
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	return recorder
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery is HTTPRecovery for gin, replacing gin.Recovery
+// 5xx errors rendered with utils/response are also sent to the global utils.ErrorReporter
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return wrapHTTP(HTTPRecovery(logger), func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusInternalServerError && len(c.Errors) > 0 {
+			reportError(c.Request, c.Errors.Last().Err, c.Writer.Status(), false, nil)
+		}
+	})
+}
+
+// recoveredError converts a recovered value into an error, logging unexpected panics
+func recoveredError(r *http.Request, logger *slog.Logger, recovered interface{}) error {
+	err, ok := recovered.(error)
+	if !ok {
+		err = fmt.Errorf("panic: %v", recovered)
+	}
+
+	var customErr *utils.CustomError
+	if errors.As(err, &customErr) && customErr.StatusCode < http.StatusInternalServerError {
+		return err
+	}
+	if !errors.Is(err, http.ErrAbortHandler) {
+		logger.ErrorContext(r.Context(), "panic recovered",
+			slog.String("error", err.Error()),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("stack", string(debug.Stack())),
+		)
+	}
+	return err
+}
+
+// reportPanic reports a recovered panic unless it was raised with a CustomError below 500
+func reportPanic(r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	var customErr *utils.CustomError
+	if errors.As(err, &customErr) {
+		status = customErr.StatusCode
+	}
+	if status < http.StatusInternalServerError || utils.GetGlobalErrorReporter() == nil {
+		return
+	}
+	// Skip reportPanic, the deferred function and runtime.gopanic, the stack starts where the panic was raised
+	reportError(r, err, status, true, utils.CallerStack(3))
+}
+
+// reportError sends err to the global error reporter with the request, user and tenant of r
+func reportError(r *http.Request, err error, status int, panicked bool, stack []runtime.Frame) {
+	reporter := utils.GetGlobalErrorReporter()
+	if reporter == nil {
+		return
+	}
+
+	ctx := r.Context()
+	report := utils.ErrorReport{
+		Err:       err,
+		Status:    status,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		RequestID: utils.RequestIDFromContext(ctx),
+		Panic:     panicked,
+		Stack:     stack,
+	}
+	if report.Stack == nil {
+		report.Stack = utils.StackTraceOf(err)
+	}
+	if claims, ok := utils.ClaimsFromContext(ctx); ok {
+		report.UserID = claims.UserID
+	}
+	if tenant, ok := utils.TenantFromContext(ctx); ok {
+		report.TenantID = tenant
+	}
+	reporter.Report(ctx, report)
+}
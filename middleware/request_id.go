@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// requestIDPattern bounds incoming request IDs so clients cannot inject arbitrary data into logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID is HTTPRequestID for gin, it also stores the ID as "request_id" in the gin context
+// It should be the first middleware so logs and errors of the others carry the ID
+func RequestID() gin.HandlerFunc {
+	return wrapHTTP(HTTPRequestID(), func(c *gin.Context) {
+		c.Set("request_id", utils.RequestIDFromContext(c.Request.Context()))
+		c.Next()
+	})
+}
+
+// incomingRequestID returns the valid X-Request-ID of the request or a new UUID
+func incomingRequestID(r *http.Request) string {
+	requestID := r.Header.Get(utils.RequestIDHeader)
+	if !requestIDPattern.MatchString(requestID) {
+		requestID = uuid.NewString()
+	}
+	return requestID
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionMiddleware loads the session of the session cookie, if any, and stores it as "session",
+// its user as "user_id" and the session in the request context. Sessions past half of their TTL are refreshed
+func SessionMiddleware(manager *utils.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := c.Cookie(manager.CookieName())
+		if err != nil || id == "" {
+			c.Next()
+			return
+		}
+
+		session, err := manager.Get(c.Request.Context(), id)
+		if err != nil {
+			if errors.Is(err, utils.ErrSessionNotFound) {
+				manager.ClearCookie(c.Writer)
+			} else {
+				log.Printf("Failed to load session: %v", err)
+			}
+			c.Next()
+			return
+		}
+
+		if time.Until(session.ExpiresAt) < manager.TTL()/2 {
+			if err := manager.Refresh(c.Request.Context(), session); err != nil {
+				log.Printf("Failed to refresh session: %v", err)
+			} else {
+				manager.SetCookie(c.Writer, session)
+			}
+		}
+
+		c.Set("session", session)
+		c.Set("user_id", session.UserID)
+		c.Request = c.Request.WithContext(utils.ContextWithSession(c.Request.Context(), session))
+
+		c.Next()
+	}
+}
+
+// RequireSession rejects requests without a session, it must run after SessionMiddleware
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetSession(c) == nil {
+			abortWithError(c, utils.NewCustomError("Session required", http.StatusUnauthorized))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetSession returns the session loaded by SessionMiddleware, nil if there is none
+func GetSession(c *gin.Context) *utils.Session {
+	session, _ := c.Get("session")
+	s, _ := session.(*utils.Session)
+	return s
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// TenantHeader is the default header carrying the tenant identifier
+const TenantHeader = "X-Tenant-ID"
+
+// TenantAuthorizer reports whether the authenticated user of the request may act for tenant,
+// e.g. looking up a membership of c.GetString("user_id"), so it must run after AuthMiddleware
+type TenantAuthorizer func(c *gin.Context, tenant string) (bool, error)
+
+// TenantMiddleware reads the tenant identifier from header (TenantHeader when empty), rejects requests
+// without a valid one or whose user authorize denies, and stores it as "tenant_id" and in the request
+// context for utils.TenantDB. The header is client supplied, so a nil authorize rejects every request
+func TenantMiddleware(header string, authorize TenantAuthorizer) gin.HandlerFunc {
+	if header == "" {
+		header = TenantHeader
+	}
+
+	return func(c *gin.Context) {
+		tenant := c.GetHeader(header)
+		if tenant == "" {
+			abortWithError(c, utils.NewCustomError("Tenant header required", http.StatusBadRequest))
+			return
+		}
+
+		if !utils.ValidTenantID(tenant) {
+			abortWithError(c, utils.NewCustomError("Invalid tenant", http.StatusBadRequest))
+			return
+		}
+
+		allowed := false
+		if authorize != nil {
+			var err error
+			allowed, err = authorize(c, tenant)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+		if !allowed {
+			abortWithError(c, utils.NewCustomError("Access to tenant denied", http.StatusForbidden))
+			return
+		}
+
+		c.Set("tenant_id", tenant)
+		c.Request = c.Request.WithContext(utils.ContextWithTenant(c.Request.Context(), tenant))
+
+		c.Next()
+	}
+}
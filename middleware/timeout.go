@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutOption configures the Timeout middleware
+type TimeoutOption func(*timeoutConfig)
+
+type timeoutConfig struct {
+	routes map[string]time.Duration
+}
+
+// WithRouteTimeout overrides the timeout of a route template (e.g. "/files/:id/upload"), 0 disables it
+func WithRouteTimeout(route string, d time.Duration) TimeoutOption {
+	return func(cfg *timeoutConfig) {
+		cfg.routes[route] = d
+	}
+}
+
+// Timeout gives each request a context deadline of d and answers 504 when it is exceeded
+// Handlers run on the request goroutine, so they must pass c.Request.Context() to the database, Redis and
+// HTTP calls for the deadline to interrupt them. Nested Timeout middleware can only shorten the deadline,
+// longer limits for uploads or exports are set here with WithRouteTimeout
+func Timeout(d time.Duration, opts ...TimeoutOption) gin.HandlerFunc {
+	cfg := &timeoutConfig{routes: map[string]time.Duration{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(c *gin.Context) {
+		timeout := d
+		if override, ok := cfg.routes[c.FullPath()]; ok {
+			timeout = override
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			abortWithError(c, utils.NewCustomError("Request timed out", http.StatusGatewayTimeout))
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// UploadPolicy defines the limits enforced on a multipart file upload
+type UploadPolicy struct {
+	MaxSize             int64    // maximum file size in bytes, 0 means no limit
+	AllowedContentTypes []string // allowed detected content types (e.g. "image/png"), empty allows all
+	AllowedExtensions   []string // allowed file extensions including the dot (e.g. ".png"), empty allows all
+}
+
+// UploadResult is the result of a successful form upload
+type UploadResult struct {
+	URL         string `json:"url"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Key         string `json:"key"`
+	SHA256      string `json:"sha256"`
+}
+
+// UploadFromForm reads a multipart file from the request, enforces the policy and uploads it
+// using the global storage client. Returned errors are CustomErrors carrying the HTTP status to respond with
+func UploadFromForm(c *gin.Context, field string, policy UploadPolicy) (*UploadResult, error) {
+	storage, err := utils.GetGlobalStorageClient()
+	if err != nil {
+		return nil, utils.NewCustomErrorWithTrace(err, "storage is not available", http.StatusInternalServerError)
+	}
+
+	if policy.MaxSize > 0 {
+		// Allow some headroom for the multipart envelope, the file size itself is checked below
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, policy.MaxSize+1<<20)
+	}
+
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, utils.NewCustomError(fmt.Sprintf("file exceeds maximum size of %d bytes", policy.MaxSize), http.StatusRequestEntityTooLarge)
+		}
+		return nil, utils.NewCustomErrorWithTrace(err, fmt.Sprintf("file field %q is required", field), http.StatusBadRequest)
+	}
+
+	if policy.MaxSize > 0 && fileHeader.Size > policy.MaxSize {
+		return nil, utils.NewCustomError(fmt.Sprintf("file exceeds maximum size of %d bytes", policy.MaxSize), http.StatusRequestEntityTooLarge)
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if len(policy.AllowedExtensions) > 0 && !containsFold(policy.AllowedExtensions, ext) {
+		return nil, utils.NewCustomError(fmt.Sprintf("file extension %q is not allowed", ext), http.StatusUnsupportedMediaType)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, utils.NewCustomErrorWithTrace(err, "failed to open uploaded file", http.StatusBadRequest)
+	}
+	defer file.Close()
+
+	// Sniff the content type from the content instead of trusting the client header
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, utils.NewCustomErrorWithTrace(err, "failed to read uploaded file", http.StatusBadRequest)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if len(policy.AllowedContentTypes) > 0 && !containsFold(policy.AllowedContentTypes, baseMediaType(contentType)) {
+		return nil, utils.NewCustomError(fmt.Sprintf("content type %q is not allowed", contentType), http.StatusUnsupportedMediaType)
+	}
+
+	uploaded, err := storage.UploadFileWithResult(c.Request.Context(), io.MultiReader(bytes.NewReader(head), file), fileHeader.Filename, contentType)
+	if err != nil {
+		var customErr *utils.CustomError
+		if errors.As(err, &customErr) {
+			return nil, err
+		}
+		return nil, utils.NewCustomErrorWithTrace(err, "failed to upload file", http.StatusInternalServerError)
+	}
+
+	return &UploadResult{
+		URL:         uploaded.URL,
+		Filename:    fileHeader.Filename,
+		Size:        uploaded.Size,
+		ContentType: contentType,
+		Key:         uploaded.Key,
+		SHA256:      uploaded.SHA256,
+	}, nil
+}
+
+// baseMediaType strips parameters such as charset from a content type
+func baseMediaType(contentType string) string {
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		return strings.TrimSpace(contentType[:i])
+	}
+	return contentType
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes why a request field failed validation
+type FieldError = utils.FieldError
+
+// requestValidator reports fields by the name clients send: json, then form, then uri tag
+var requestValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form", "uri"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	return v
+}()
+
+// BindAndValidate binds the uri parameters, the query string and the JSON body of the request into T
+// and runs its `validate` tags (not gin's `binding` tags). On failure it aborts with 400 for malformed
+// input or 422 with the list of field errors, and returns false so the handler can simply return
+func BindAndValidate[T any](c *gin.Context) (T, bool) {
+	var value T
+
+	params := map[string][]string{}
+	for _, param := range c.Params {
+		params[param.Key] = []string{param.Value}
+	}
+	if err := binding.MapFormWithTag(&value, params, "uri"); err != nil {
+		abortWithError(c, utils.NewCustomErrorWithTrace(err, "Invalid path parameters", http.StatusBadRequest))
+		return value, false
+	}
+	if err := binding.MapFormWithTag(&value, c.Request.URL.Query(), "form"); err != nil {
+		abortWithError(c, utils.NewCustomErrorWithTrace(err, "Invalid query parameters", http.StatusBadRequest))
+		return value, false
+	}
+
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		if err := json.NewDecoder(c.Request.Body).Decode(&value); err != nil && !errors.Is(err, io.EOF) {
+			abortWithError(c, utils.NewCustomErrorWithTrace(err, "Invalid request body", http.StatusBadRequest))
+			return value, false
+		}
+	}
+
+	if reflect.Indirect(reflect.ValueOf(value)).Kind() == reflect.Struct {
+		if validationErr, ok := utils.ValidationErrorFrom(requestValidator.Struct(value)); ok {
+			abortWithError(c, validationErr)
+			return value, false
+		}
+	}
+	return value, true
+}
@@ -19,6 +19,14 @@ func NewBaseRepository(db utils.PGXPool) *BaseRepository {
 	}
 }
 
+// NewBaseRepositoryTx creates a base repository over any DBTX, e.g. a utils.SQLAdapter over MySQL or a pgx.Tx
+// It has no pool, so GetDB returns nil and transactions must be started by the owner of db
+func NewBaseRepositoryTx(db DBTX) *BaseRepository {
+	return &BaseRepository{
+		Queries: New(db),
+	}
+}
+
 // GetDB returns the database pool
 func (r *BaseRepository) GetDB() utils.PGXPool {
 	return r.db
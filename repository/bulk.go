@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxQueryParams is the maximum number of bind parameters allowed by the Postgres protocol
+const maxQueryParams = 65535
+
+// copier is implemented by pgxpool.Pool, pgx.Conn and pgx.Tx
+type copier interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// BulkInsert inserts rows using the COPY protocol when db supports it, otherwise it falls back to
+// batched multi-VALUES inserts. table may be schema qualified ("schema.table")
+func BulkInsert(ctx context.Context, db DBTX, table string, columns []string, rows [][]interface{}) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	if c, ok := db.(copier); ok {
+		count, err := c.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, pgx.CopyFromRows(rows))
+		if err != nil {
+			return count, fmt.Errorf("failed to copy rows into %s: %w", table, err)
+		}
+		return count, nil
+	}
+
+	return BulkInsertBatched(ctx, db, table, columns, rows, 0)
+}
+
+// BulkInsertBatched inserts rows with multi-VALUES INSERT statements of at most batchSize rows
+// A batchSize <= 0 uses the largest batch allowed by the bind parameter limit
+func BulkInsertBatched(ctx context.Context, db DBTX, table string, columns []string, rows [][]interface{}, batchSize int) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("bulk insert into %s requires at least one column", table)
+	}
+
+	maxBatch := maxQueryParams / len(columns)
+	if batchSize <= 0 || batchSize > maxBatch {
+		batchSize = maxBatch
+	}
+
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = pgx.Identifier{column}.Sanitize()
+	}
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ",
+		pgx.Identifier(strings.Split(table, ".")).Sanitize(), strings.Join(quotedColumns, ", "))
+
+	var inserted int64
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var sb strings.Builder
+		sb.WriteString(prefix)
+		args := make([]interface{}, 0, (end-start)*len(columns))
+		for i, row := range rows[start:end] {
+			if len(row) != len(columns) {
+				return inserted, fmt.Errorf("row %d has %d values, expected %d", start+i, len(row), len(columns))
+			}
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("(")
+			for j, value := range row {
+				if j > 0 {
+					sb.WriteString(", ")
+				}
+				args = append(args, value)
+				fmt.Fprintf(&sb, "$%d", len(args))
+			}
+			sb.WriteString(")")
+		}
+
+		tag, err := db.Exec(ctx, sb.String(), args...)
+		if err != nil {
+			return inserted, fmt.Errorf("failed to insert rows into %s: %w", table, err)
+		}
+		inserted += tag.RowsAffected()
+	}
+
+	return inserted, nil
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// column maps a struct field to a table column
+type column struct {
+	name     string
+	index    []int
+	pk       bool
+	readonly bool // set by the database (defaults, generated values), never written
+	version  bool // optimistic locking version, checked and incremented by Update
+}
+
+// CrudRepository implements Create/GetByID/Update/Delete/List for simple entities
+// Columns are mapped from `db:"column"` struct tags, with the options:
+//
+//	db:"id,pk"               primary key (exactly one field is required)
+//	db:"created_at,readonly" populated by the database, excluded from INSERT and UPDATE
+//	db:"version,version"     optimistic locking, Update fails with ErrStaleVersion on concurrent edits
+//
+// Fields without a db tag (or tagged `db:"-"`) are ignored. Complex queries should still use Queries directly
+type CrudRepository[T any] struct {
+	db         DBTX
+	name       string
+	table      string
+	columns    []column
+	pk         column
+	version    *column
+	softDelete bool
+}
+
+// NewCrudRepository creates a CRUD repository for T stored in table (optionally schema qualified)
+func NewCrudRepository[T any](db DBTX, table string) (*CrudRepository[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("crud repository requires a struct type, got %s", typ)
+	}
+
+	repo := &CrudRepository[T]{
+		db:    db,
+		name:  table,
+		table: pgx.Identifier(strings.Split(table, ".")).Sanitize(),
+	}
+
+	hasPK := false
+	for _, field := range reflect.VisibleFields(typ) {
+		tag, ok := field.Tag.Lookup("db")
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		col := column{name: parts[0], index: field.Index}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "pk":
+				col.pk = true
+			case "readonly":
+				col.readonly = true
+			case "version":
+				col.version = true
+			}
+		}
+
+		if col.version {
+			if repo.version != nil {
+				return nil, fmt.Errorf("%s has more than one version column", typ)
+			}
+			versionCol := col
+			repo.version = &versionCol
+		}
+
+		if col.pk {
+			if hasPK {
+				return nil, fmt.Errorf("%s has more than one pk column", typ)
+			}
+			hasPK = true
+			repo.pk = col
+		}
+		repo.columns = append(repo.columns, col)
+	}
+
+	if !hasPK {
+		return nil, fmt.Errorf("%s has no column tagged with the pk option", typ)
+	}
+	return repo, nil
+}
+
+// WithTx returns a copy of the repository bound to the transaction
+func (r *CrudRepository[T]) WithTx(tx pgx.Tx) *CrudRepository[T] {
+	clone := *r
+	clone.db = tx
+	return &clone
+}
+
+// WithSoftDelete returns a copy of the repository using the table's soft delete column (see SetSoftDeleteColumn):
+// Delete marks rows as deleted and GetByID/Update/List ignore deleted rows
+func (r *CrudRepository[T]) WithSoftDelete() *CrudRepository[T] {
+	clone := *r
+	clone.softDelete = true
+	return &clone
+}
+
+// scope returns the soft delete condition prefixed with AND, or "" when soft deletes are disabled
+func (r *CrudRepository[T]) scope() string {
+	if !r.softDelete {
+		return ""
+	}
+	return " AND " + NotDeleted(r.name, "")
+}
+
+// Create inserts the entity and refreshes it with the values returned by the database
+func (r *CrudRepository[T]) Create(ctx context.Context, entity *T) error {
+	value := reflect.ValueOf(entity).Elem()
+
+	var names, placeholders []string
+	var args []interface{}
+	for _, col := range r.columns {
+		if col.readonly {
+			continue
+		}
+		field := value.FieldByIndex(col.index)
+		// Let the database generate zero-valued primary keys
+		if col.pk && field.IsZero() {
+			continue
+		}
+		args = append(args, field.Interface())
+		names = append(names, quoteIdent(col.name))
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		r.table, strings.Join(names, ", "), strings.Join(placeholders, ", "), r.selectList())
+	if len(names) == 0 {
+		query = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES RETURNING %s", r.table, r.selectList())
+	}
+
+	return r.db.QueryRow(ctx, query, args...).Scan(r.scanTargets(value)...)
+}
+
+// GetByID returns the entity with the given primary key, or pgx.ErrNoRows
+func (r *CrudRepository[T]) GetByID(ctx context.Context, id interface{}) (T, error) {
+	var entity T
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1%s", r.selectList(), r.table, quoteIdent(r.pk.name), r.scope())
+	err := r.db.QueryRow(ctx, query, id).Scan(r.scanTargets(reflect.ValueOf(&entity).Elem())...)
+	return entity, err
+}
+
+// Update writes all writable columns of the entity and refreshes it, returns pgx.ErrNoRows if it does not exist
+// With a version column the update only applies if the stored version matches, otherwise ErrStaleVersion is returned
+func (r *CrudRepository[T]) Update(ctx context.Context, entity *T) error {
+	value := reflect.ValueOf(entity).Elem()
+
+	var sets []string
+	var args []interface{}
+	for _, col := range r.columns {
+		if col.pk || col.readonly || col.version {
+			continue
+		}
+		args = append(args, value.FieldByIndex(col.index).Interface())
+		sets = append(sets, fmt.Sprintf("%s = $%d", quoteIdent(col.name), len(args)))
+	}
+	if len(sets) == 0 {
+		return fmt.Errorf("%s has no writable columns", r.table)
+	}
+
+	id := value.FieldByIndex(r.pk.index).Interface()
+	args = append(args, id)
+	where := fmt.Sprintf("%s = $%d%s", quoteIdent(r.pk.name), len(args), r.scope())
+
+	if r.version != nil {
+		versionName := quoteIdent(r.version.name)
+		sets = append(sets, fmt.Sprintf("%s = %s + 1", versionName, versionName))
+		args = append(args, value.FieldByIndex(r.version.index).Interface())
+		where += fmt.Sprintf(" AND %s = $%d", versionName, len(args))
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING %s",
+		r.table, strings.Join(sets, ", "), where, r.selectList())
+
+	err := r.db.QueryRow(ctx, query, args...).Scan(r.scanTargets(value)...)
+	if r.version != nil && errors.Is(err, pgx.ErrNoRows) {
+		return staleOrMissing(ctx, r.db, r.table, r.pk.name, id)
+	}
+	return err
+}
+
+// Delete deletes the entity with the given primary key, returns pgx.ErrNoRows if it does not exist
+func (r *CrudRepository[T]) Delete(ctx context.Context, id interface{}) error {
+	if r.softDelete {
+		return SoftDelete(ctx, r.db, r.name, r.pk.name, id)
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1", r.table, quoteIdent(r.pk.name))
+	tag, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// List returns entities ordered by primary key
+func (r *CrudRepository[T]) List(ctx context.Context, limit, offset int) ([]T, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE TRUE%s ORDER BY %s LIMIT $1 OFFSET $2", r.selectList(), r.table, r.scope(), quoteIdent(r.pk.name))
+	rows, err := r.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := []T{}
+	for rows.Next() {
+		var entity T
+		if err := rows.Scan(r.scanTargets(reflect.ValueOf(&entity).Elem())...); err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
+
+// selectList returns the quoted list of all mapped columns
+func (r *CrudRepository[T]) selectList() string {
+	names := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		names[i] = quoteIdent(col.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// scanTargets returns pointers to the mapped fields of value, in column order
+func (r *CrudRepository[T]) scanTargets(value reflect.Value) []interface{} {
+	targets := make([]interface{}, len(r.columns))
+	for i, col := range r.columns {
+		targets[i] = value.FieldByIndex(col.index).Addr().Interface()
+	}
+	return targets
+}
+
+// quoteIdent quotes a single identifier
+func quoteIdent(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 
+	"github.com/gadhittana01/go-modules-v3/utils"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
@@ -31,6 +32,15 @@ func (q *Queries) WithTx(tx pgx.Tx) *Queries {
 	}
 }
 
+// WithContextTx returns a Queries instance bound to the transaction carried by ctx (see utils.ExecTxContext)
+// If ctx carries no transaction the receiver is returned unchanged
+func (q *Queries) WithContextTx(ctx context.Context) *Queries {
+	if tx, ok := utils.TxFromContext(ctx); ok {
+		return q.WithTx(tx)
+	}
+	return q
+}
+
 // GetDB returns the database connection
 func (q *Queries) GetDB() DBTX {
 	return q.db
@@ -0,0 +1,96 @@
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ListQuery holds the pagination, sort and filter parameters of a list request
+// Sort is validated and can be passed to repository.OrderBy, Filter values to repository.WhereBuilder
+type ListQuery struct {
+	PageRequest
+	Sort    string            // e.g. "-created_at,name", descending fields are prefixed with -
+	Filters map[string]string // filter[status]=active becomes Filters["status"] = "active"
+}
+
+// Filter returns the value of a filter, nil when it is not set so WhereBuilder skips it
+func (q ListQuery) Filter(name string) *string {
+	value, ok := q.Filters[name]
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+// ListQueryConfig lists the sort and filter fields a list endpoint accepts
+type ListQueryConfig struct {
+	SortFields   []string
+	FilterFields []string
+	DefaultSort  string // used when the request has no sort
+	DefaultLimit int    // default DefaultLimit
+	MaxLimit     int    // default MaxLimit
+}
+
+// ParseListQuery parses the page, limit, cursor, sort and filter[field] query parameters
+// Sorting or filtering on a field not allowed by cfg is rejected
+func ParseListQuery(values url.Values, cfg ListQueryConfig) (ListQuery, error) {
+	if cfg.DefaultLimit <= 0 {
+		cfg.DefaultLimit = DefaultLimit
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = MaxLimit
+	}
+
+	page, err := parsePageRequest(values, cfg.DefaultLimit, cfg.MaxLimit)
+	if err != nil {
+		return ListQuery{}, err
+	}
+	query := ListQuery{PageRequest: page, Sort: cfg.DefaultSort, Filters: map[string]string{}}
+
+	if raw := values.Get("sort"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			name := strings.TrimPrefix(strings.TrimSpace(field), "-")
+			if !contains(cfg.SortFields, name) {
+				return ListQuery{}, fmt.Errorf("invalid sort %q: allowed fields are %s", name, strings.Join(cfg.SortFields, ", "))
+			}
+		}
+		query.Sort = raw
+	}
+
+	for key, vals := range values {
+		if !strings.HasPrefix(key, "filter[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		name := key[len("filter[") : len(key)-1]
+		if !contains(cfg.FilterFields, name) {
+			return ListQuery{}, fmt.Errorf("invalid filter %q: allowed fields are %s", name, strings.Join(cfg.FilterFields, ", "))
+		}
+		query.Filters[name] = vals[0]
+	}
+
+	return query, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type listQueryContextKey struct{}
+
+// ContextWithListQuery returns a copy of ctx carrying the list query of the request
+func ContextWithListQuery(ctx context.Context, query ListQuery) context.Context {
+	return context.WithValue(ctx, listQueryContextKey{}, query)
+}
+
+// ListQueryFromContext returns the list query carried by ctx
+func ListQueryFromContext(ctx context.Context) (ListQuery, bool) {
+	query, ok := ctx.Value(listQueryContextKey{}).(ListQuery)
+	return query, ok
+}
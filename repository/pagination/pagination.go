@@ -0,0 +1,217 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// DefaultLimit is the page size used when the request does not specify one
+	DefaultLimit = 20
+	// MaxLimit is the largest page size accepted by ParsePageRequest
+	MaxLimit = 100
+)
+
+// PageRequest holds the pagination parameters of a list request
+// Page/Limit are used for offset pagination, Cursor/Limit for keyset pagination
+type PageRequest struct {
+	Page   int
+	Limit  int
+	Cursor string
+}
+
+// Offset returns the row offset for offset pagination
+func (p PageRequest) Offset() int {
+	if p.Page <= 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.Limit
+}
+
+// ParsePageRequest parses the "page", "limit" and "cursor" query parameters
+// Missing values fall back to page 1 and DefaultLimit, limits above MaxLimit are rejected
+func ParsePageRequest(values url.Values) (PageRequest, error) {
+	return parsePageRequest(values, DefaultLimit, MaxLimit)
+}
+
+// parsePageRequest is ParsePageRequest with custom default and maximum page sizes
+func parsePageRequest(values url.Values, defaultLimit, maxLimit int) (PageRequest, error) {
+	req := PageRequest{
+		Page:   1,
+		Limit:  defaultLimit,
+		Cursor: values.Get("cursor"),
+	}
+
+	if raw := values.Get("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return PageRequest{}, fmt.Errorf("invalid page %q: must be a positive integer", raw)
+		}
+		req.Page = page
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return PageRequest{}, fmt.Errorf("invalid limit %q: must be a positive integer", raw)
+		}
+		if limit > maxLimit {
+			return PageRequest{}, fmt.Errorf("invalid limit %d: must not exceed %d", limit, maxLimit)
+		}
+		req.Limit = limit
+	}
+
+	return req, nil
+}
+
+// ApplyLimitOffset appends LIMIT/OFFSET placeholders to the query and their values to args
+func ApplyLimitOffset(query string, args []interface{}, req PageRequest) (string, []interface{}) {
+	args = append(args, req.Limit, req.Offset())
+	return fmt.Sprintf("%s LIMIT $%d OFFSET $%d", query, len(args)-1, len(args)), args
+}
+
+// Keyset describes the ordered, unique column tuple used for cursor pagination (e.g. created_at, id)
+type Keyset struct {
+	Columns []string
+	Desc    bool
+}
+
+// Condition returns the row comparison selecting rows after the cursor values, e.g. "(created_at, id) < ($3, $4)",
+// appending the values to args. The caller places it in its WHERE clause
+func (k Keyset) Condition(args []interface{}, cursorValues []interface{}) (string, []interface{}) {
+	placeholders := make([]string, len(cursorValues))
+	for i, value := range cursorValues {
+		args = append(args, value)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+
+	operator := ">"
+	if k.Desc {
+		operator = "<"
+	}
+	return fmt.Sprintf("(%s) %s (%s)", k.columnList(), operator, strings.Join(placeholders, ", ")), args
+}
+
+// OrderBy returns the ORDER BY clause matching the keyset
+func (k Keyset) OrderBy() string {
+	direction := "ASC"
+	if k.Desc {
+		direction = "DESC"
+	}
+
+	columns := make([]string, len(k.Columns))
+	for i, column := range k.Columns {
+		columns[i] = pgx.Identifier{column}.Sanitize() + " " + direction
+	}
+	return "ORDER BY " + strings.Join(columns, ", ")
+}
+
+// ApplyKeyset appends the keyset condition (when a cursor is given), ORDER BY and LIMIT to the query
+// hasWhere tells whether the query already has a WHERE clause. One extra row is fetched to detect a next page
+func ApplyKeyset(query string, args []interface{}, hasWhere bool, keyset Keyset, req PageRequest) (string, []interface{}, error) {
+	if req.Cursor != "" {
+		var cursorValues []interface{}
+		if err := DecodeCursor(req.Cursor, &cursorValues); err != nil {
+			return "", nil, err
+		}
+		if len(cursorValues) != len(keyset.Columns) {
+			return "", nil, fmt.Errorf("invalid cursor: expected %d values, got %d", len(keyset.Columns), len(cursorValues))
+		}
+
+		var condition string
+		condition, args = keyset.Condition(args, cursorValues)
+		if hasWhere {
+			query += " AND " + condition
+		} else {
+			query += " WHERE " + condition
+		}
+	}
+
+	args = append(args, req.Limit+1)
+	return fmt.Sprintf("%s %s LIMIT $%d", query, keyset.OrderBy(), len(args)), args, nil
+}
+
+// columnList returns the quoted, comma separated keyset columns
+func (k Keyset) columnList() string {
+	columns := make([]string, len(k.Columns))
+	for i, column := range k.Columns {
+		columns[i] = pgx.Identifier{column}.Sanitize()
+	}
+	return strings.Join(columns, ", ")
+}
+
+// EncodeCursor encodes cursor values as an opaque URL-safe string
+func EncodeCursor(values interface{}) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a cursor produced by EncodeCursor into dest
+func DecodeCursor(cursor string, dest interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("invalid cursor: %w", err)
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("invalid cursor: %w", err)
+	}
+	return nil
+}
+
+// PagedResult is the response shape of a paginated list
+type PagedResult[T any] struct {
+	Items      []T    `json:"items"`
+	Total      *int64 `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// NewOffsetResult builds a result for offset pagination
+func NewOffsetResult[T any](items []T, total int64, req PageRequest) PagedResult[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return PagedResult[T]{
+		Items:   items,
+		Total:   &total,
+		Page:    req.Page,
+		Limit:   req.Limit,
+		HasMore: int64(req.Offset()+len(items)) < total,
+	}
+}
+
+// NewCursorResult builds a result for keyset pagination from rows fetched with ApplyKeyset (limit + 1 rows)
+// cursorValues returns the keyset column values of an item, in keyset column order
+func NewCursorResult[T any](items []T, req PageRequest, cursorValues func(T) []interface{}) (PagedResult[T], error) {
+	result := PagedResult[T]{
+		Items: items,
+		Limit: req.Limit,
+	}
+	if result.Items == nil {
+		result.Items = []T{}
+	}
+
+	if len(items) > req.Limit {
+		result.Items = items[:req.Limit]
+		result.HasMore = true
+
+		cursor, err := EncodeCursor(cursorValues(result.Items[req.Limit-1]))
+		if err != nil {
+			return PagedResult[T]{}, err
+		}
+		result.NextCursor = cursor
+	}
+
+	return result, nil
+}
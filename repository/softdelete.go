@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultSoftDeleteColumn is the column used for soft deletes unless configured otherwise
+const DefaultSoftDeleteColumn = "deleted_at"
+
+var (
+	softDeleteMu      sync.RWMutex
+	softDeleteColumns = map[string]string{}
+)
+
+// SetSoftDeleteColumn configures the soft delete timestamp column of a table
+func SetSoftDeleteColumn(table, column string) {
+	softDeleteMu.Lock()
+	defer softDeleteMu.Unlock()
+	softDeleteColumns[table] = column
+}
+
+// SoftDeleteColumn returns the soft delete column configured for the table, or DefaultSoftDeleteColumn
+func SoftDeleteColumn(table string) string {
+	softDeleteMu.RLock()
+	defer softDeleteMu.RUnlock()
+	if column, ok := softDeleteColumns[table]; ok {
+		return column
+	}
+	return DefaultSoftDeleteColumn
+}
+
+// NotDeleted returns the condition selecting rows of the table that are not soft deleted, e.g. "deleted_at IS NULL"
+// alias qualifies the column when the table is aliased in the query, pass "" otherwise
+func NotDeleted(table, alias string) string {
+	column := quoteIdent(SoftDeleteColumn(table))
+	if alias != "" {
+		column = quoteIdent(alias) + "." + column
+	}
+	return column + " IS NULL"
+}
+
+// ScopeNotDeleted appends the NotDeleted condition to a query, as WHERE or AND depending on hasWhere
+func ScopeNotDeleted(query string, hasWhere bool, table, alias string) string {
+	if hasWhere {
+		return query + " AND " + NotDeleted(table, alias)
+	}
+	return query + " WHERE " + NotDeleted(table, alias)
+}
+
+// SoftDelete marks the row as deleted, returns pgx.ErrNoRows if it does not exist or is already deleted
+func SoftDelete(ctx context.Context, db DBTX, table, idColumn string, id interface{}) error {
+	column := quoteIdent(SoftDeleteColumn(table))
+	query := fmt.Sprintf("UPDATE %s SET %s = NOW() WHERE %s = $1 AND %s IS NULL",
+		pgx.Identifier(strings.Split(table, ".")).Sanitize(), column, quoteIdent(idColumn), column)
+	return execAffectingOne(ctx, db, query, id)
+}
+
+// Restore clears the deleted mark of the row, returns pgx.ErrNoRows if it does not exist or is not deleted
+func Restore(ctx context.Context, db DBTX, table, idColumn string, id interface{}) error {
+	column := quoteIdent(SoftDeleteColumn(table))
+	query := fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s = $1 AND %s IS NOT NULL",
+		pgx.Identifier(strings.Split(table, ".")).Sanitize(), column, quoteIdent(idColumn), column)
+	return execAffectingOne(ctx, db, query, id)
+}
+
+// execAffectingOne executes a statement and returns pgx.ErrNoRows when no row was affected
+func execAffectingOne(ctx context.Context, db DBTX, query string, args ...interface{}) error {
+	tag, err := db.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
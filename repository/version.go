@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrStaleVersion is returned when an update is based on an outdated version of a row
+// It is a CustomError with status 409 so handlers can return it as is
+var ErrStaleVersion = utils.NewCustomError("record was modified by another request, reload and retry", http.StatusConflict)
+
+// VersionColumn is the default column name used for optimistic locking
+const VersionColumn = "version"
+
+// UpdateWithVersion updates the row identified by idColumn = id only if its version column still equals
+// expectedVersion, incrementing the version on success. It returns the new version, ErrStaleVersion if the
+// row was modified concurrently, or pgx.ErrNoRows if the row does not exist
+func UpdateWithVersion(ctx context.Context, db DBTX, table, idColumn string, id interface{}, expectedVersion int64, values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, fmt.Errorf("update of %s requires at least one value", table)
+	}
+
+	// Sort columns so the generated SQL is stable (and prepared statement caches stay effective)
+	columns := make([]string, 0, len(values))
+	for name := range values {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+
+	sets := make([]string, 0, len(columns)+1)
+	args := make([]interface{}, 0, len(columns)+2)
+	for _, name := range columns {
+		args = append(args, values[name])
+		sets = append(sets, fmt.Sprintf("%s = $%d", quoteIdent(name), len(args)))
+	}
+	sets = append(sets, fmt.Sprintf("%s = %s + 1", quoteIdent(VersionColumn), quoteIdent(VersionColumn)))
+
+	args = append(args, id, expectedVersion)
+	quotedTable := pgx.Identifier(strings.Split(table, ".")).Sanitize()
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d AND %s = $%d RETURNING %s",
+		quotedTable, strings.Join(sets, ", "), quoteIdent(idColumn), len(args)-1,
+		quoteIdent(VersionColumn), len(args), quoteIdent(VersionColumn))
+
+	var newVersion int64
+	err := db.QueryRow(ctx, query, args...).Scan(&newVersion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, staleOrMissing(ctx, db, quotedTable, idColumn, id)
+	}
+	return newVersion, err
+}
+
+// staleOrMissing tells apart a concurrent modification from a missing row after a versioned update matched nothing
+func staleOrMissing(ctx context.Context, db DBTX, quotedTable, idColumn string, id interface{}) error {
+	var exists bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s = $1)", quotedTable, quoteIdent(idColumn))
+	if err := db.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrStaleVersion
+	}
+	return pgx.ErrNoRows
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WhereBuilder builds a WHERE clause from optional filters, numbering placeholders as arguments are added
+// Filters whose value is nil (including nil pointers) are skipped and pointers are dereferenced, so optional
+// request fields can be passed as is:
+//
+//	where := repository.NewWhereBuilder()
+//	where.Eq("status", req.Status).ILike("name", req.Search).Range("created_at", req.From, req.To)
+//	clause, args := where.Build()
+//	query := "SELECT id, name FROM users" + clause
+type WhereBuilder struct {
+	conds []string
+	args  *[]interface{}
+}
+
+// NewWhereBuilder creates a builder, args are existing arguments already bound as $1..$n in the query
+func NewWhereBuilder(args ...interface{}) *WhereBuilder {
+	bound := append([]interface{}{}, args...)
+	return &WhereBuilder{args: &bound}
+}
+
+// Arg binds a value and returns its placeholder, e.g. for LIMIT or a subquery
+func (b *WhereBuilder) Arg(value interface{}) string {
+	*b.args = append(*b.args, value)
+	return fmt.Sprintf("$%d", len(*b.args))
+}
+
+// Where adds a raw condition, ? in cond are replaced by placeholders bound to args in order
+func (b *WhereBuilder) Where(cond string, args ...interface{}) *WhereBuilder {
+	var sb strings.Builder
+	next := 0
+	for _, r := range cond {
+		if r == '?' && next < len(args) {
+			sb.WriteString(b.Arg(args[next]))
+			next++
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	b.conds = append(b.conds, sb.String())
+	return b
+}
+
+// Eq adds column = value
+func (b *WhereBuilder) Eq(column string, value interface{}) *WhereBuilder {
+	return b.compare(column, "=", value)
+}
+
+// NotEq adds column <> value
+func (b *WhereBuilder) NotEq(column string, value interface{}) *WhereBuilder {
+	return b.compare(column, "<>", value)
+}
+
+// Gt adds column > value
+func (b *WhereBuilder) Gt(column string, value interface{}) *WhereBuilder {
+	return b.compare(column, ">", value)
+}
+
+// Gte adds column >= value
+func (b *WhereBuilder) Gte(column string, value interface{}) *WhereBuilder {
+	return b.compare(column, ">=", value)
+}
+
+// Lt adds column < value
+func (b *WhereBuilder) Lt(column string, value interface{}) *WhereBuilder {
+	return b.compare(column, "<", value)
+}
+
+// Lte adds column <= value
+func (b *WhereBuilder) Lte(column string, value interface{}) *WhereBuilder {
+	return b.compare(column, "<=", value)
+}
+
+// Range adds from <= column < to, either bound may be nil to leave that side open
+func (b *WhereBuilder) Range(column string, from, to interface{}) *WhereBuilder {
+	return b.Gte(column, from).Lt(column, to)
+}
+
+// In adds column = ANY(values), values must be a slice. An empty slice matches nothing
+func (b *WhereBuilder) In(column string, values interface{}) *WhereBuilder {
+	value, ok := deref(values)
+	if !ok {
+		return b
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return b.Eq(column, value)
+	}
+	if v.Len() == 0 {
+		b.conds = append(b.conds, "FALSE")
+		return b
+	}
+	b.conds = append(b.conds, fmt.Sprintf("%s = ANY(%s)", quoteColumn(column), b.Arg(value)))
+	return b
+}
+
+// ILike adds a case insensitive substring match, LIKE wildcards in search are escaped. Empty searches are skipped
+func (b *WhereBuilder) ILike(column string, search interface{}) *WhereBuilder {
+	value, ok := deref(search)
+	if !ok {
+		return b
+	}
+	text := fmt.Sprint(value)
+	if text == "" {
+		return b
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+	b.conds = append(b.conds, fmt.Sprintf("%s ILIKE %s", quoteColumn(column), b.Arg("%"+escaped+"%")))
+	return b
+}
+
+// IsNull adds column IS NULL
+func (b *WhereBuilder) IsNull(column string) *WhereBuilder {
+	b.conds = append(b.conds, quoteColumn(column)+" IS NULL")
+	return b
+}
+
+// Or adds a parenthesized group whose conditions are joined with OR, empty groups are skipped
+func (b *WhereBuilder) Or(fn func(or *WhereBuilder)) *WhereBuilder {
+	return b.group(" OR ", fn)
+}
+
+// And adds a parenthesized group whose conditions are joined with AND, e.g. inside Or
+func (b *WhereBuilder) And(fn func(and *WhereBuilder)) *WhereBuilder {
+	return b.group(" AND ", fn)
+}
+
+// HasConditions reports whether any condition was added
+func (b *WhereBuilder) HasConditions() bool {
+	return len(b.conds) > 0
+}
+
+// Args returns all bound arguments in placeholder order
+func (b *WhereBuilder) Args() []interface{} {
+	return *b.args
+}
+
+// Build returns " WHERE ..." (or "" without conditions) and the arguments
+func (b *WhereBuilder) Build() (string, []interface{}) {
+	if len(b.conds) == 0 {
+		return "", b.Args()
+	}
+	return " WHERE " + strings.Join(b.conds, " AND "), b.Args()
+}
+
+// compare adds a binary comparison unless value is nil
+func (b *WhereBuilder) compare(column, operator string, value interface{}) *WhereBuilder {
+	value, ok := deref(value)
+	if !ok {
+		return b
+	}
+	b.conds = append(b.conds, fmt.Sprintf("%s %s %s", quoteColumn(column), operator, b.Arg(value)))
+	return b
+}
+
+// group adds the conditions built by fn joined with sep, sharing the argument list
+func (b *WhereBuilder) group(sep string, fn func(*WhereBuilder)) *WhereBuilder {
+	inner := &WhereBuilder{args: b.args}
+	fn(inner)
+	if len(inner.conds) > 0 {
+		b.conds = append(b.conds, "("+strings.Join(inner.conds, sep)+")")
+	}
+	return b
+}
+
+// deref unwraps pointers, returning false for nil values
+func deref(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	return v.Interface(), true
+}
+
+// quoteColumn quotes a possibly qualified column name such as "u.created_at"
+func quoteColumn(column string) string {
+	return pgx.Identifier(strings.Split(column, ".")).Sanitize()
+}
+
+// OrderBy returns an ORDER BY clause for a requested sort such as "name" or "-created_at" (descending),
+// mapping it through allowed (request name to column) so only whitelisted columns can be sorted on.
+// Unknown or empty sorts fall back to fallback, which is trusted SQL such as "id DESC"
+func OrderBy(sort string, allowed map[string]string, fallback string) string {
+	var clauses []string
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			field = field[1:]
+		}
+
+		column, ok := allowed[field]
+		if !ok {
+			continue
+		}
+		clauses = append(clauses, quoteColumn(column)+" "+direction)
+	}
+
+	if len(clauses) == 0 {
+		if fallback == "" {
+			return ""
+		}
+		return " ORDER BY " + fallback
+	}
+	return " ORDER BY " + strings.Join(clauses, ", ")
+}
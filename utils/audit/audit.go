@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/outbox"
+	"github.com/google/uuid"
+)
+
+// Table is the name of the audit table
+const Table = "audit_log"
+
+// Migration creates the audit table, copy it into a service migration or run it with EnsureTable
+const Migration = `CREATE TABLE IF NOT EXISTS audit_log (
+	id UUID PRIMARY KEY,
+	occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	actor_id TEXT NOT NULL DEFAULT '',
+	actor_name TEXT NOT NULL DEFAULT '',
+	tenant_id TEXT NOT NULL DEFAULT '',
+	action TEXT NOT NULL,
+	targets JSONB NOT NULL DEFAULT '{}',
+	outcome TEXT NOT NULL,
+	status INT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	request_id TEXT NOT NULL DEFAULT '',
+	client_ip TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	metadata JSONB NOT NULL DEFAULT '{}'
+);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id, occurred_at);
+CREATE INDEX IF NOT EXISTS audit_log_occurred_at_idx ON audit_log (occurred_at);`
+
+// Outcomes of an audited action
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Entry is a single audit trail record
+type Entry struct {
+	ID         string                 `json:"id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	ActorID    string                 `json:"actor_id,omitempty"`
+	ActorName  string                 `json:"actor_name,omitempty"`
+	TenantID   string                 `json:"tenant_id,omitempty"`
+	Action     string                 `json:"action"`            // e.g. "DELETE /articles/:id"
+	Targets    map[string]string      `json:"targets,omitempty"` // affected entity ids, e.g. {"id": "42"}
+	Outcome    string                 `json:"outcome"`
+	Status     int                    `json:"status,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	ClientIP   string                 `json:"client_ip,omitempty"`
+	UserAgent  string                 `json:"user_agent,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Writer persists audit entries
+type Writer interface {
+	Write(ctx context.Context, entry Entry) error
+}
+
+// WriterFunc adapts a function to Writer
+type WriterFunc func(ctx context.Context, entry Entry) error
+
+func (f WriterFunc) Write(ctx context.Context, entry Entry) error {
+	return f(ctx, entry)
+}
+
+// prepare fills the id and time of an entry
+func prepare(entry Entry) Entry {
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = time.Now()
+	}
+	return entry
+}
+
+// EnsureTable creates the audit table if it does not exist
+func EnsureTable(ctx context.Context, db utils.PGXPool) error {
+	if _, err := db.Exec(ctx, Migration); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", Table, err)
+	}
+	return nil
+}
+
+// PostgresWriter appends entries to the audit table
+type PostgresWriter struct {
+	db utils.PGXPool
+}
+
+// NewPostgresWriter creates a writer inserting into the audit table of db
+func NewPostgresWriter(db utils.PGXPool) *PostgresWriter {
+	return &PostgresWriter{db: db}
+}
+
+func (w *PostgresWriter) Write(ctx context.Context, entry Entry) error {
+	entry = prepare(entry)
+
+	targets, err := json.Marshal(entry.Targets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit targets: %w", err)
+	}
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit metadata: %w", err)
+	}
+	if entry.Targets == nil {
+		targets = []byte("{}")
+	}
+	if entry.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	_, err = w.db.Exec(ctx, `INSERT INTO `+Table+` (id, occurred_at, actor_id, actor_name, tenant_id, action, targets,
+		outcome, status, error, request_id, client_ip, user_agent, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		entry.ID, entry.OccurredAt, entry.ActorID, entry.ActorName, entry.TenantID, entry.Action, targets,
+		entry.Outcome, entry.Status, entry.Error, entry.RequestID, entry.ClientIP, entry.UserAgent, metadata)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// PublisherWriter emits entries as events, e.g. with an outbox.RedisStreamPublisher
+type PublisherWriter struct {
+	publisher outbox.Publisher
+	topic     string
+}
+
+// NewPublisherWriter creates a writer publishing entries on topic, keyed by actor
+func NewPublisherWriter(publisher outbox.Publisher, topic string) *PublisherWriter {
+	return &PublisherWriter{publisher: publisher, topic: topic}
+}
+
+func (w *PublisherWriter) Write(ctx context.Context, entry Entry) error {
+	entry = prepare(entry)
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	event := outbox.Event{
+		ID:        entry.ID,
+		Topic:     w.topic,
+		Key:       entry.ActorID,
+		Payload:   payload,
+		CreatedAt: entry.OccurredAt,
+	}
+	if err := w.publisher.Publish(ctx, event); err != nil {
+		return fmt.Errorf("failed to publish audit entry: %w", err)
+	}
+	return nil
+}
+
+// MultiWriter writes entries to every writer, returning the first error
+func MultiWriter(writers ...Writer) Writer {
+	return WriterFunc(func(ctx context.Context, entry Entry) error {
+		entry = prepare(entry)
+		var firstErr error
+		for _, writer := range writers {
+			if err := writer.Write(ctx, entry); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	})
+}
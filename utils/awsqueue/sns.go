@@ -0,0 +1,92 @@
+package awsqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/gadhittana01/go-modules-v3/utils/event"
+)
+
+// SNSClient is the part of the SNS API used by Publisher, satisfied by *sns.Client
+type SNSClient interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// Message attributes set on published events, so subscriptions can filter on them
+const (
+	AttributeEventID   = "event_id"
+	AttributeEventType = "event_type"
+)
+
+// Publisher publishes event bus envelopes as JSON to SNS topics
+type Publisher struct {
+	client    SNSClient
+	arnPrefix string
+}
+
+// NewPublisher creates a publisher resolving topic names under arnPrefix, e.g. "arn:aws:sns:eu-west-1:123456789012:"
+// Topics given as full ARNs are used as is
+func NewPublisher(client SNSClient, arnPrefix string) *Publisher {
+	return &Publisher{client: client, arnPrefix: arnPrefix}
+}
+
+// NewSNSClient creates an SNS client using the default AWS credential chain and the given region
+func NewSNSClient(ctx context.Context, region string) (*sns.Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return sns.NewFromConfig(cfg), nil
+}
+
+// TopicARN returns the ARN of a topic
+func (p *Publisher) TopicARN(topic string) string {
+	if strings.HasPrefix(topic, "arn:") {
+		return topic
+	}
+	return p.arnPrefix + topic
+}
+
+// Publish publishes an envelope to topic, FIFO topics are grouped by env.Key and deduplicated by env.ID
+// Publisher implements event.Publisher, e.g. to publish with event.Publish or dead-letter with event.DeadLetter
+func (p *Publisher) Publish(ctx context.Context, topic string, env event.Envelope) error {
+	arn := p.TopicARN(topic)
+	env = event.Prepare(ctx, topicName(arn), env)
+	body, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", env.ID, err)
+	}
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(arn),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			AttributeEventID:   {DataType: aws.String("String"), StringValue: aws.String(env.ID)},
+			AttributeEventType: {DataType: aws.String("String"), StringValue: aws.String(env.Type)},
+		},
+	}
+	if strings.HasSuffix(arn, ".fifo") {
+		groupID := env.Key
+		if groupID == "" {
+			groupID = env.Type
+		}
+		input.MessageGroupId = aws.String(groupID)
+		input.MessageDeduplicationId = aws.String(env.ID)
+	}
+
+	if _, err := p.client.Publish(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish event %s to %s: %w", env.ID, arn, err)
+	}
+	return nil
+}
+
+// topicName returns the topic name of an ARN
+func topicName(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
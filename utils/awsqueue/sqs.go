@@ -0,0 +1,470 @@
+package awsqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/event"
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/queue"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+)
+
+// SQSClient is the part of the SQS API used by Consumer, satisfied by *sqs.Client
+type SQSClient interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// maxVisibilityTimeout is the longest visibility timeout SQS accepts
+const maxVisibilityTimeout = 12 * time.Hour
+
+// ConsumerOption configures a Consumer
+type ConsumerOption func(*Consumer)
+
+// WithConcurrency sets how many messages are handled at the same time (default 1)
+func WithConcurrency(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.concurrency = n
+	}
+}
+
+// WithVisibilityTimeout sets how long a received message is hidden from other consumers (default 30s, at least 2s)
+// It is extended while the handler runs, so it only bounds how fast the message of a crashed consumer is redelivered
+func WithVisibilityTimeout(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.visibilityTimeout = d
+	}
+}
+
+// WithWaitTime sets the long polling duration of a receive, at most 20s (default 20s)
+func WithWaitTime(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.waitTime = d
+	}
+}
+
+// WithRetryPolicy sets the backoff before a failed message is received again (default 5 attempts from 1s up to 1m)
+// The attempts are only enforced with WithDeadLetterQueue, otherwise the redrive policy of the queue bounds them
+func WithRetryPolicy(policy retry.Policy) ConsumerOption {
+	return func(c *Consumer) {
+		c.policy = policy
+	}
+}
+
+// WithDeadLetterQueue sends the messages that exhausted their attempts or failed permanently to the queue at url
+func WithDeadLetterQueue(url string) ConsumerOption {
+	return func(c *Consumer) {
+		c.deadLetterURL = url
+	}
+}
+
+// WithJobTimeout bounds the duration of a single handler call (default 0, unbounded)
+func WithJobTimeout(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.jobTimeout = d
+	}
+}
+
+// Consumer runs a queue.Handler for the messages of an SQS queue with long polling
+// The visibility of a message is extended while it is handled, handled messages are deleted in batches and failed
+// ones become visible again after the retry backoff. Delivery is at-least-once, handlers must be idempotent
+type Consumer struct {
+	client   SQSClient
+	queueURL string
+	handler  queue.Handler
+
+	concurrency       int
+	visibilityTimeout time.Duration
+	waitTime          time.Duration
+	policy            retry.Policy
+	deadLetterURL     string
+	jobTimeout        time.Duration
+
+	deletes chan types.Message
+}
+
+// NewConsumer creates a consumer of the queue at queueURL
+func NewConsumer(client SQSClient, queueURL string, handler queue.Handler, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		client:            client,
+		queueURL:          queueURL,
+		handler:           handler,
+		concurrency:       1,
+		visibilityTimeout: 30 * time.Second,
+		waitTime:          20 * time.Second,
+		policy: retry.Policy{
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Minute,
+			Multiplier:  2,
+			Jitter:      0.2,
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.concurrency < 1 {
+		c.concurrency = 1
+	}
+	if c.visibilityTimeout <= 0 {
+		c.visibilityTimeout = 30 * time.Second
+	}
+	// SQS counts the visibility timeout in seconds and extendVisibility ticks at half of it
+	c.visibilityTimeout = min(max(c.visibilityTimeout, 2*time.Second), maxVisibilityTimeout)
+	if c.waitTime > 20*time.Second {
+		c.waitTime = 20 * time.Second
+	}
+	return c
+}
+
+// NewSQSClient creates an SQS client using the default AWS credential chain and the given region
+func NewSQSClient(ctx context.Context, region string) (*sqs.Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return sqs.NewFromConfig(cfg), nil
+}
+
+// Name implements worker.Worker
+func (c *Consumer) Name() string {
+	return "sqs-consumer-" + queueName(c.queueURL)
+}
+
+// Run receives messages until ctx is canceled, then waits for the messages being handled and deletes them
+func (c *Consumer) Run(ctx context.Context) error {
+	log := logger.Named("sqs").With("queue", queueName(c.queueURL))
+
+	// Messages in progress finish and are deleted on a context that is not canceled with ctx
+	jobCtx := context.WithoutCancel(ctx)
+	c.deletes = make(chan types.Message, 10*c.concurrency)
+	deleterDone := make(chan struct{})
+	go func() {
+		defer close(deleterDone)
+		c.deleteLoop(jobCtx)
+	}()
+
+	// A message is only received when a slot is free to handle it, so received messages never wait unextended
+	slots := make(chan struct{}, c.concurrency)
+	for i := 0; i < c.concurrency; i++ {
+		slots <- struct{}{}
+	}
+	var wg sync.WaitGroup
+
+	attempt := 0
+	for ctx.Err() == nil {
+		free := c.acquireSlots(ctx, slots)
+		if free == 0 {
+			break
+		}
+
+		out, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(c.queueURL),
+			MaxNumberOfMessages:   int32(free),
+			WaitTimeSeconds:       int32(c.waitTime / time.Second),
+			VisibilityTimeout:     int32(c.visibilityTimeout / time.Second),
+			MessageAttributeNames: []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+				types.MessageSystemAttributeNameApproximateReceiveCount,
+				types.MessageSystemAttributeNameSentTimestamp,
+				types.MessageSystemAttributeNameMessageGroupId,
+			},
+		})
+		if err != nil {
+			releaseSlots(slots, free)
+			if ctx.Err() != nil {
+				break
+			}
+			delay := c.policy.Delay(attempt)
+			log.Error("failed to receive messages", "attempt", attempt+1, "delay", delay, "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		// Received messages are handled even if ctx is canceled meanwhile, they are already invisible
+		releaseSlots(slots, free-len(out.Messages))
+		for _, msg := range out.Messages {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer releaseSlots(slots, 1)
+				c.process(jobCtx, msg)
+			}()
+		}
+	}
+
+	wg.Wait()
+	close(c.deletes)
+	<-deleterDone
+	return nil
+}
+
+// acquireSlots waits for a free slot and takes the other free ones, up to the 10 messages of a receive
+// It returns 0 when ctx is canceled
+func (c *Consumer) acquireSlots(ctx context.Context, slots chan struct{}) int {
+	select {
+	case <-ctx.Done():
+		return 0
+	case <-slots:
+	}
+	free := 1
+	for free < 10 {
+		select {
+		case <-slots:
+			free++
+		default:
+			return free
+		}
+	}
+	return free
+}
+
+// releaseSlots returns n slots
+func releaseSlots(slots chan struct{}, n int) {
+	for i := 0; i < n; i++ {
+		slots <- struct{}{}
+	}
+}
+
+// process runs the handler while extending the visibility of the message, then deletes, retries or dead-letters it
+func (c *Consumer) process(ctx context.Context, msg types.Message) {
+	job := newJob(c.queueURL, msg)
+
+	extendCtx, stopExtending := context.WithCancel(ctx)
+	go c.extendVisibility(extendCtx, msg)
+	err := c.handle(ctx, job)
+	stopExtending()
+
+	if err == nil {
+		c.deletes <- msg
+		return
+	}
+
+	log := logger.Named("sqs")
+	exhausted := retry.IsPermanent(err) || job.Attempt >= c.policy.Attempts()
+	if c.deadLetterURL != "" && exhausted {
+		log.ErrorContext(ctx, "message failed, dead-lettering", "queue", job.Queue, "message_id", job.ID, "attempt", job.Attempt, "error", err)
+		if err := c.deadLetter(ctx, msg, err); err != nil {
+			log.ErrorContext(ctx, "failed to dead-letter message", "queue", job.Queue, "message_id", job.ID, "error", err)
+			return
+		}
+		c.deletes <- msg
+		return
+	}
+
+	delay := min(c.policy.Delay(job.Attempt-1), maxVisibilityTimeout)
+	log.ErrorContext(ctx, "message failed, retrying", "queue", job.Queue, "message_id", job.ID, "attempt", job.Attempt, "delay", delay, "error", err)
+	if err := c.changeVisibility(ctx, msg, delay); err != nil {
+		log.WarnContext(ctx, "failed to schedule retry", "queue", job.Queue, "message_id", job.ID, "error", err)
+	}
+}
+
+// handle calls the handler with the job timeout, reporting panics as errors
+func (c *Consumer) handle(ctx context.Context, job *queue.Job) (err error) {
+	if c.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.jobTimeout)
+		defer cancel()
+	}
+
+	defer utils.RecoverAndReport(ctx, c.Name(), &err)
+	return c.handler(ctx, job)
+}
+
+// extendVisibility keeps the message invisible until ctx is canceled
+func (c *Consumer) extendVisibility(ctx context.Context, msg types.Message) {
+	ticker := time.NewTicker(c.visibilityTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.changeVisibility(ctx, msg, c.visibilityTimeout); err != nil && ctx.Err() == nil {
+				logger.Named("sqs").WarnContext(ctx, "failed to extend visibility", "message_id", aws.ToString(msg.MessageId), "error", err)
+			}
+		}
+	}
+}
+
+// changeVisibility makes the message visible again after d
+func (c *Consumer) changeVisibility(ctx context.Context, msg types.Message, d time.Duration) error {
+	_, err := c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(c.queueURL),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: int32(d / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change message visibility: %w", err)
+	}
+	return nil
+}
+
+// deadLetter copies the message and its attributes to the dead-letter queue with the error
+func (c *Consumer) deadLetter(ctx context.Context, msg types.Message, cause error) error {
+	attributes := make(map[string]types.MessageAttributeValue, len(msg.MessageAttributes)+3)
+	for key, value := range msg.MessageAttributes {
+		attributes[key] = value
+	}
+	attributes["dead_letter_error"] = stringAttribute(cause.Error())
+	attributes["dead_letter_queue"] = stringAttribute(c.queueURL)
+	attributes["dead_letter_at"] = stringAttribute(time.Now().UTC().Format(time.RFC3339))
+	if len(attributes) > 10 {
+		// SQS accepts at most 10 attributes, the dead-letter ones are kept
+		for key := range msg.MessageAttributes {
+			if len(attributes) <= 10 {
+				break
+			}
+			delete(attributes, key)
+		}
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(c.deadLetterURL),
+		MessageBody:       msg.Body,
+		MessageAttributes: attributes,
+	}
+	if strings.HasSuffix(c.deadLetterURL, ".fifo") {
+		// FIFO queues require a group, messages from a standard queue share a single one
+		groupID := msg.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+		if groupID == "" {
+			groupID = queueName(c.queueURL)
+		}
+		input.MessageGroupId = aws.String(groupID)
+		input.MessageDeduplicationId = msg.MessageId
+	}
+	if _, err := c.client.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("failed to send message to dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+// deleteLoop deletes handled messages in batches of up to 10, at least every second
+func (c *Consumer) deleteLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	batch := make([]types.Message, 0, 10)
+	for {
+		select {
+		case msg, ok := <-c.deletes:
+			if !ok {
+				c.deleteBatch(ctx, batch)
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) < 10 {
+				continue
+			}
+		case <-ticker.C:
+		}
+		c.deleteBatch(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// deleteBatch deletes messages, a message that fails to be deleted is received and handled again
+func (c *Consumer) deleteBatch(ctx context.Context, batch []types.Message) {
+	if len(batch) == 0 {
+		return
+	}
+	entries := make([]types.DeleteMessageBatchRequestEntry, len(batch))
+	for i, msg := range batch {
+		entries[i] = types.DeleteMessageBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), ReceiptHandle: msg.ReceiptHandle}
+	}
+
+	log := logger.Named("sqs")
+	out, err := c.client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{QueueUrl: aws.String(c.queueURL), Entries: entries})
+	if err != nil {
+		log.ErrorContext(ctx, "failed to delete messages", "queue", queueName(c.queueURL), "count", len(batch), "error", err)
+		return
+	}
+	for _, failed := range out.Failed {
+		log.ErrorContext(ctx, "failed to delete message", "queue", queueName(c.queueURL),
+			"entry", aws.ToString(failed.Id), "code", aws.ToString(failed.Code), "error", aws.ToString(failed.Message))
+	}
+}
+
+// newJob builds a queue.Job from an SQS message
+func newJob(queueURL string, msg types.Message) *queue.Job {
+	job := &queue.Job{
+		ID:      aws.ToString(msg.MessageId),
+		Queue:   queueName(queueURL),
+		Payload: json.RawMessage(aws.ToString(msg.Body)),
+		Attempt: 1,
+	}
+	if count, err := strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]); err == nil {
+		job.Attempt = count
+	}
+	if sent, err := strconv.ParseInt(msg.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64); err == nil {
+		job.EnqueuedAt = time.UnixMilli(sent)
+	}
+	return job
+}
+
+// queueName returns the last path segment of a queue URL
+func queueName(queueURL string) string {
+	for i := len(queueURL) - 1; i >= 0; i-- {
+		if queueURL[i] == '/' {
+			return queueURL[i+1:]
+		}
+	}
+	return queueURL
+}
+
+// snsNotification is the JSON body of a message delivered by an SNS subscription without raw message delivery
+type snsNotification struct {
+	Type     string `json:"Type"`
+	TopicArn string `json:"TopicArn"`
+	Message  string `json:"Message"`
+}
+
+// EnvelopeHandler adapts an event bus handler, so event.Retry and event.DeadLetter can bound the attempts
+// Envelopes published with Publisher are unwrapped from SNS notifications, bodies that are not envelopes are
+// logged and deleted
+func EnvelopeHandler(handler event.Handler) queue.Handler {
+	return func(ctx context.Context, job *queue.Job) error {
+		body := []byte(job.Payload)
+		var notification snsNotification
+		if json.Unmarshal(body, &notification) == nil && notification.Type == "Notification" {
+			body = []byte(notification.Message)
+		}
+
+		var env event.Envelope
+		if err := json.Unmarshal(body, &env); err != nil || env.Type == "" {
+			if err == nil {
+				err = errors.New("missing event type")
+			}
+			logger.Named("sqs").ErrorContext(ctx, "skipping malformed event", "queue", job.Queue, "message_id", job.ID, "error", err)
+			return nil
+		}
+		if env.Topic == "" {
+			env.Topic = topicName(notification.TopicArn)
+		}
+		return handler(event.HandlerContext(ctx, env), env)
+	}
+}
+
+// stringAttribute returns a String message attribute
+func stringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
@@ -0,0 +1,359 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned without calling the dependency while the breaker is open
+// or while the half-open trial calls are in flight
+var ErrOpen = errors.New("circuit breaker is open")
+
+// errPanicked is recorded for calls of Execute that panicked, the panic keeps propagating to the caller
+var errPanicked = errors.New("circuit breaker call panicked")
+
+// State is the state of a breaker
+type State int
+
+const (
+	StateClosed   State = iota // calls go through and outcomes are recorded
+	StateOpen                  // calls are rejected until OpenTimeout elapses
+	StateHalfOpen              // a few trial calls decide whether to close or reopen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+// Config configures a Breaker, zero values use the defaults
+type Config struct {
+	Name string // reported to OnStateChange, in errors and as the "breaker" metric label
+
+	WindowSize           int     // number of most recent calls the rates are computed over, default 20
+	MinCalls             int     // calls needed in the window before the breaker can open, default 10
+	FailureRateThreshold float64 // failure rate opening the breaker, default 0.5
+
+	SlowCallDuration      time.Duration // calls taking longer count as slow, 0 disables slow call tracking
+	SlowCallRateThreshold float64       // slow call rate opening the breaker, default 1 (every call in the window)
+
+	OpenTimeout      time.Duration // how long the breaker stays open before trial calls, default 30s
+	HalfOpenMaxCalls int           // trial calls allowed when half-open, all must succeed to close, default 1
+
+	// IsFailure classifies errors, the default counts every error except context.Canceled
+	// Use utils.IsRetryable to ignore errors caused by the caller, like validation or not found
+	IsFailure func(err error) bool
+
+	// OnStateChange is called after each transition, outside the breaker lock
+	OnStateChange func(name string, from, to State)
+}
+
+// withDefaults fills the unset fields of cfg
+func (cfg Config) withDefaults() Config {
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = 20
+	}
+	if cfg.MinCalls <= 0 {
+		cfg.MinCalls = 10
+	}
+	if cfg.MinCalls > cfg.WindowSize {
+		cfg.MinCalls = cfg.WindowSize
+	}
+	if cfg.FailureRateThreshold <= 0 {
+		cfg.FailureRateThreshold = 0.5
+	}
+	if cfg.SlowCallRateThreshold <= 0 {
+		cfg.SlowCallRateThreshold = 1
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		}
+	}
+	return cfg
+}
+
+// Metrics is a snapshot of a breaker
+type Metrics struct {
+	State        State
+	Calls        int     // calls in the window
+	Failures     int     // failed calls in the window
+	SlowCalls    int     // slow calls in the window
+	FailureRate  float64 // Failures / Calls
+	SlowCallRate float64 // SlowCalls / Calls
+
+	TotalCalls       uint64 // calls recorded since creation
+	TotalFailures    uint64
+	TotalSlowCalls   uint64
+	TotalRejected    uint64 // calls rejected with ErrOpen
+	TotalTransitions uint64
+}
+
+// outcome is a call recorded in the sliding window
+type outcome struct {
+	failed bool
+	slow   bool
+}
+
+// Breaker stops calling a degraded dependency once the failure or slow call rate over the recent calls
+// crosses a threshold, and probes it again after OpenTimeout. It is safe for concurrent use
+type Breaker struct {
+	cfg Config
+
+	mu         sync.Mutex
+	state      State
+	generation uint64 // bumped on every transition, outcomes of older calls are ignored
+	openedAt   time.Time
+
+	window    []outcome // ring buffer
+	next      int
+	calls     int
+	failures  int
+	slowCalls int
+
+	trials       int // trial calls started while half-open
+	trialSuccess int
+
+	totalCalls, totalFailures, totalSlowCalls, totalRejected, totalTransitions uint64
+}
+
+// New creates a closed breaker
+func New(cfg Config) *Breaker {
+	cfg = cfg.withDefaults()
+	return &Breaker{cfg: cfg, window: make([]outcome, cfg.WindowSize)}
+}
+
+// Name returns the configured name
+func (b *Breaker) Name() string {
+	return b.cfg.Name
+}
+
+// Execute calls fn unless the breaker is open and records its outcome
+func (b *Breaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	// A panicking call is recorded as a failure, otherwise a half-open trial would never complete
+	panicked := true
+	defer func() {
+		if panicked {
+			done(errPanicked)
+		}
+	}()
+	err = fn(ctx)
+	panicked = false
+	done(err)
+	return err
+}
+
+// Call is Execute for functions returning a value
+func Call[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := b.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+// Allow reserves a call, for callers that classify outcomes themselves, e.g. by HTTP status
+// It returns ErrOpen when the call must not be made, otherwise done must be called exactly once
+// with the outcome of the call; its duration is measured from Allow
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	change := b.refreshLocked(time.Now())
+
+	switch {
+	case b.state == StateOpen,
+		b.state == StateHalfOpen && b.trials >= b.cfg.HalfOpenMaxCalls:
+		b.totalRejected++
+		b.mu.Unlock()
+		b.notify(change)
+		if b.cfg.Name != "" {
+			return nil, fmt.Errorf("%s: %w", b.cfg.Name, ErrOpen)
+		}
+		return nil, ErrOpen
+	case b.state == StateHalfOpen:
+		b.trials++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	b.notify(change)
+
+	start := time.Now()
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.record(generation, err, time.Since(start)) })
+	}, nil
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	change := b.refreshLocked(time.Now())
+	state := b.state
+	b.mu.Unlock()
+	b.notify(change)
+	return state
+}
+
+// Metrics returns a snapshot of the window and the lifetime counters
+func (b *Breaker) Metrics() Metrics {
+	b.mu.Lock()
+	change := b.refreshLocked(time.Now())
+	m := Metrics{
+		State:            b.state,
+		Calls:            b.calls,
+		Failures:         b.failures,
+		SlowCalls:        b.slowCalls,
+		TotalCalls:       b.totalCalls,
+		TotalFailures:    b.totalFailures,
+		TotalSlowCalls:   b.totalSlowCalls,
+		TotalRejected:    b.totalRejected,
+		TotalTransitions: b.totalTransitions,
+	}
+	b.mu.Unlock()
+	b.notify(change)
+
+	if m.Calls > 0 {
+		m.FailureRate = float64(m.Failures) / float64(m.Calls)
+		m.SlowCallRate = float64(m.SlowCalls) / float64(m.Calls)
+	}
+	return m
+}
+
+// Reset closes the breaker and clears the window
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	change := b.transitionLocked(StateClosed, time.Now())
+	b.mu.Unlock()
+	b.notify(change)
+}
+
+// record adds the outcome of a call started in generation
+func (b *Breaker) record(generation uint64, err error, elapsed time.Duration) {
+	failed := err == errPanicked || b.cfg.IsFailure(err)
+	slow := b.cfg.SlowCallDuration > 0 && elapsed > b.cfg.SlowCallDuration
+
+	b.mu.Lock()
+	b.totalCalls++
+	if failed {
+		b.totalFailures++
+	}
+	if slow {
+		b.totalSlowCalls++
+	}
+
+	var change *transition
+	if generation == b.generation {
+		now := time.Now()
+		switch b.state {
+		case StateClosed:
+			b.addLocked(outcome{failed: failed, slow: slow})
+			if b.tripLocked() {
+				change = b.transitionLocked(StateOpen, now)
+			}
+		case StateHalfOpen:
+			if failed || slow {
+				change = b.transitionLocked(StateOpen, now)
+			} else if b.trialSuccess++; b.trialSuccess >= b.cfg.HalfOpenMaxCalls {
+				change = b.transitionLocked(StateClosed, now)
+			}
+		}
+	}
+	b.mu.Unlock()
+	b.notify(change)
+}
+
+// addLocked pushes an outcome into the window, evicting the oldest one when full
+func (b *Breaker) addLocked(o outcome) {
+	if b.calls == len(b.window) {
+		evicted := b.window[b.next]
+		if evicted.failed {
+			b.failures--
+		}
+		if evicted.slow {
+			b.slowCalls--
+		}
+	} else {
+		b.calls++
+	}
+
+	b.window[b.next] = o
+	b.next = (b.next + 1) % len(b.window)
+	if o.failed {
+		b.failures++
+	}
+	if o.slow {
+		b.slowCalls++
+	}
+}
+
+// tripLocked reports whether the window crosses a threshold
+func (b *Breaker) tripLocked() bool {
+	if b.calls < b.cfg.MinCalls {
+		return false
+	}
+	calls := float64(b.calls)
+	if float64(b.failures)/calls >= b.cfg.FailureRateThreshold {
+		return true
+	}
+	return b.cfg.SlowCallDuration > 0 && float64(b.slowCalls)/calls >= b.cfg.SlowCallRateThreshold
+}
+
+// refreshLocked moves an open breaker to half-open once OpenTimeout has elapsed
+func (b *Breaker) refreshLocked(now time.Time) *transition {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return b.transitionLocked(StateHalfOpen, now)
+	}
+	return nil
+}
+
+// transition is a state change waiting to be reported
+type transition struct {
+	from, to State
+}
+
+// transitionLocked switches to state and starts a new generation with an empty window
+func (b *Breaker) transitionLocked(to State, now time.Time) *transition {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.window = make([]outcome, len(b.window))
+	b.next, b.calls, b.failures, b.slowCalls = 0, 0, 0, 0
+	b.trials, b.trialSuccess = 0, 0
+	if to == StateOpen {
+		b.openedAt = now
+	}
+	if from == to {
+		return nil
+	}
+	b.totalTransitions++
+	return &transition{from: from, to: to}
+}
+
+// notify calls OnStateChange for a transition
+func (b *Breaker) notify(change *transition) {
+	if change != nil && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(b.cfg.Name, change.from, change.to)
+	}
+}
@@ -0,0 +1,79 @@
+package breaker
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector exports the state and counters of breakers as Prometheus metrics, labelled by breaker name
+// Values are read from the breakers on every scrape
+type Collector struct {
+	mu       sync.Mutex
+	breakers []*Breaker
+
+	state       *prometheus.Desc
+	calls       *prometheus.Desc
+	failures    *prometheus.Desc
+	slowCalls   *prometheus.Desc
+	rejected    *prometheus.Desc
+	transitions *prometheus.Desc
+}
+
+// NewCollector creates a collector for breakers, more can be added with Add
+func NewCollector(breakers ...*Breaker) *Collector {
+	desc := func(metric, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc("circuit_breaker_"+metric, help, append([]string{"breaker"}, labels...), nil)
+	}
+
+	return &Collector{
+		breakers:    breakers,
+		state:       desc("state", "Current state of the breaker, 1 for the active state.", "state"),
+		calls:       desc("calls_total", "Cumulative count of calls recorded by the breaker."),
+		failures:    desc("failures_total", "Cumulative count of failed calls."),
+		slowCalls:   desc("slow_calls_total", "Cumulative count of calls slower than the slow call duration."),
+		rejected:    desc("rejected_total", "Cumulative count of calls rejected while the breaker was open."),
+		transitions: desc("transitions_total", "Cumulative count of state transitions."),
+	}
+}
+
+// Add starts exporting the metrics of b
+func (c *Collector) Add(b *Breaker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breakers = append(c.breakers, b)
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.state
+	ch <- c.calls
+	ch <- c.failures
+	ch <- c.slowCalls
+	ch <- c.rejected
+	ch <- c.transitions
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	breakers := append([]*Breaker(nil), c.breakers...)
+	c.mu.Unlock()
+
+	for _, b := range breakers {
+		m := b.Metrics()
+		name := b.Name()
+		for _, state := range []State{StateClosed, StateOpen, StateHalfOpen} {
+			value := 0.0
+			if m.State == state {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, value, name, state.String())
+		}
+		ch <- prometheus.MustNewConstMetric(c.calls, prometheus.CounterValue, float64(m.TotalCalls), name)
+		ch <- prometheus.MustNewConstMetric(c.failures, prometheus.CounterValue, float64(m.TotalFailures), name)
+		ch <- prometheus.MustNewConstMetric(c.slowCalls, prometheus.CounterValue, float64(m.TotalSlowCalls), name)
+		ch <- prometheus.MustNewConstMetric(c.rejected, prometheus.CounterValue, float64(m.TotalRejected), name)
+		ch <- prometheus.MustNewConstMetric(c.transitions, prometheus.CounterValue, float64(m.TotalTransitions), name)
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCacheMiss is returned by CacheClient.Get when the key does not exist
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheCodec serializes cached values
+type CacheCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the default CacheCodec
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// CacheClient stores serialized values under prefixed keys
+// Use CacheGet and CacheGetOrSet for typed access
+type CacheClient interface {
+	// Get decodes the value of key into dest, returning ErrCacheMiss if it does not exist
+	Get(ctx context.Context, key string, dest interface{}) error
+	// Set stores value for ttl, a zero ttl keeps the value until it is deleted
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	// Delete removes keys, missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+	// GetOrSet decodes key into dest, or calls fn, stores its result for ttl and decodes it into dest
+	GetOrSet(ctx context.Context, key string, dest interface{}, ttl time.Duration, fn func() (interface{}, error)) error
+}
+
+// CacheOption configures a RedisCache
+type CacheOption func(*RedisCache)
+
+// WithCachePrefix prefixes every key, e.g. "user-service:" so services sharing a Redis do not collide
+func WithCachePrefix(prefix string) CacheOption {
+	return func(c *RedisCache) {
+		c.prefix = prefix
+	}
+}
+
+// WithCacheCodec replaces the JSON codec, e.g. with a msgpack implementation
+func WithCacheCodec(codec CacheCodec) CacheOption {
+	return func(c *RedisCache) {
+		c.codec = codec
+	}
+}
+
+// RedisCache implements CacheClient on Redis
+type RedisCache struct {
+	client redis.Cmdable
+	prefix string
+	codec  CacheCodec
+}
+
+// NewRedisCache creates a Redis backed cache
+func NewRedisCache(client redis.Cmdable, opts ...CacheOption) *RedisCache {
+	c := &RedisCache{
+		client: client,
+		codec:  JSONCodec{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Key returns the Redis key of a cache key
+func (c *RedisCache) Key(key string) string {
+	return c.prefix + key
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
+	data, err := c.client.Get(ctx, c.Key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ErrCacheMiss
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get cache key %s: %w", key, err)
+	}
+
+	if err := c.codec.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed to decode cache key %s: %w", key, err)
+	}
+	return nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache key %s: %w", key, err)
+	}
+
+	if err := c.client.Set(ctx, c.Key(key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set cache key %s: %w", key, err)
+	}
+	return nil
+}
+
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.Key(key)
+	}
+	if err := c.client.Del(ctx, prefixed...).Err(); err != nil {
+		return fmt.Errorf("failed to delete cache keys: %w", err)
+	}
+	return nil
+}
+
+func (c *RedisCache) GetOrSet(ctx context.Context, key string, dest interface{}, ttl time.Duration, fn func() (interface{}, error)) error {
+	err := c.Get(ctx, key, dest)
+	if err == nil || !errors.Is(err, ErrCacheMiss) {
+		return err
+	}
+
+	value, err := fn()
+	if err != nil {
+		return err
+	}
+	if err := c.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	// Round trip through the codec so dest is filled exactly like on a hit
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, dest)
+}
+
+// DeleteByPattern deletes the cache keys matching a glob pattern, see the package level DeleteByPattern
+func (c *RedisCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	if client, ok := c.client.(redis.UniversalClient); ok {
+		return DeleteByPattern(ctx, client, c.Key(pattern), 0)
+	}
+	return scanAndDelete(ctx, c.client, c.Key(pattern), redisBatchSize)
+}
+
+// CacheGet returns the cached value of key as T, or ErrCacheMiss
+func CacheGet[T any](ctx context.Context, cache CacheClient, key string) (T, error) {
+	var value T
+	err := cache.Get(ctx, key, &value)
+	return value, err
+}
+
+// CacheGetOrSet returns the cached value of key, or calls fn and caches its result for ttl
+func CacheGetOrSet[T any](ctx context.Context, cache CacheClient, key string, ttl time.Duration, fn func() (T, error)) (T, error) {
+	var value T
+	err := cache.GetOrSet(ctx, key, &value, ttl, func() (interface{}, error) {
+		return fn()
+	})
+	return value, err
+}
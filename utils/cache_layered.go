@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+)
+
+// cacheInvalidation is published on the invalidation channel when keys change
+type cacheInvalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+// LayeredCache serves hot keys from an in-process LRU and falls back to Redis
+// Writes and deletes are broadcast over Redis pub/sub so other instances drop their local copy,
+// the short local TTL bounds staleness if an invalidation message is lost
+type LayeredCache struct {
+	remote  *RedisCache
+	client  redis.UniversalClient
+	local   *expirable.LRU[string, []byte]
+	channel string
+	origin  string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewLayeredCache creates a two-tier cache keeping up to size entries locally for localTTL
+// The options configure the Redis tier, the prefix also namespaces the invalidation channel
+func NewLayeredCache(client redis.UniversalClient, size int, localTTL time.Duration, opts ...CacheOption) *LayeredCache {
+	remote := NewRedisCache(client, opts...)
+	return &LayeredCache{
+		remote:  remote,
+		client:  client,
+		local:   expirable.NewLRU[string, []byte](size, nil, localTTL),
+		channel: remote.Key("cache:invalidate"),
+		origin:  uuid.NewString(),
+	}
+}
+
+// Start listens for invalidations from other instances until Stop is called or ctx is done
+func (c *LayeredCache) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	pubsub := c.client.Subscribe(ctx, c.channel)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer pubsub.Close()
+
+		// The channel is resubscribed by go-redis after a connection loss
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				c.handleInvalidation(msg.Payload)
+			}
+		}
+	}()
+}
+
+// Stop stops listening for invalidations
+func (c *LayeredCache) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+// Purge drops every local entry, Redis is left untouched
+func (c *LayeredCache) Purge() {
+	c.local.Purge()
+}
+
+func (c *LayeredCache) handleInvalidation(payload string) {
+	var inv cacheInvalidation
+	if err := json.Unmarshal([]byte(payload), &inv); err != nil {
+		log.Printf("Invalid cache invalidation message: %v", err)
+		return
+	}
+	if inv.Origin == c.origin {
+		return
+	}
+	for _, key := range inv.Keys {
+		c.local.Remove(key)
+	}
+}
+
+// invalidate drops keys locally and tells the other instances to do the same
+func (c *LayeredCache) invalidate(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		c.local.Remove(key)
+	}
+
+	payload, err := json.Marshal(cacheInvalidation{Origin: c.origin, Keys: keys})
+	if err != nil {
+		return err
+	}
+	if err := c.client.Publish(ctx, c.channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish cache invalidation: %w", err)
+	}
+	return nil
+}
+
+func (c *LayeredCache) Get(ctx context.Context, key string, dest interface{}) error {
+	if data, ok := c.local.Get(key); ok {
+		if err := c.remote.codec.Unmarshal(data, dest); err != nil {
+			return fmt.Errorf("failed to decode cache key %s: %w", key, err)
+		}
+		return nil
+	}
+
+	data, err := c.remote.client.Get(ctx, c.remote.Key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ErrCacheMiss
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get cache key %s: %w", key, err)
+	}
+
+	if err := c.remote.codec.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed to decode cache key %s: %w", key, err)
+	}
+	c.local.Add(key, data)
+	return nil
+}
+
+func (c *LayeredCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := c.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key)
+}
+
+func (c *LayeredCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := c.remote.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, keys...)
+}
+
+func (c *LayeredCache) GetOrSet(ctx context.Context, key string, dest interface{}, ttl time.Duration, fn func() (interface{}, error)) error {
+	err := c.Get(ctx, key, dest)
+	if err == nil || !errors.Is(err, ErrCacheMiss) {
+		return err
+	}
+
+	value, err := fn()
+	if err != nil {
+		return err
+	}
+	if err := c.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	data, err := c.remote.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.remote.codec.Unmarshal(data, dest)
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheLoads deduplicates concurrent loads of the same key within the process
+var cacheLoads singleflight.Group
+
+// cacheEntry wraps values stored by GetOrLoad with their freshness deadline
+type cacheEntry[T any] struct {
+	Value      T         `json:"v"`
+	FreshUntil time.Time `json:"f"`
+}
+
+// loadOptions holds the GetOrLoad options
+type loadOptions struct {
+	stale time.Duration
+}
+
+// LoadOption configures GetOrLoad
+type LoadOption func(*loadOptions)
+
+// WithStaleWhileRevalidate keeps values for stale after their ttl: during that window the stale value is
+// returned immediately while a single background load refreshes it
+func WithStaleWhileRevalidate(stale time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.stale = stale
+	}
+}
+
+// GetOrLoad returns the cached value of key or loads it, caching the result for ttl (cache-aside)
+// Concurrent misses of the same key in this process share a single loader call, so hot keys expiring do not
+// stampede the database. Cache errors are logged and fall back to the loader.
+// Values are stored in an envelope, so keys written by GetOrLoad must only be read through GetOrLoad
+func GetOrLoad[T any](ctx context.Context, cache CacheClient, key string, ttl time.Duration, loader func() (T, error), opts ...LoadOption) (T, error) {
+	var options loadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var entry cacheEntry[T]
+	err := cache.Get(ctx, key, &entry)
+	switch {
+	case err == nil:
+		if time.Now().Before(entry.FreshUntil) {
+			return entry.Value, nil
+		}
+		// Stale hit: serve it and refresh in the background
+		go func() {
+			if _, err := loadAndStore(cache, key, ttl, options, loader); err != nil {
+				log.Printf("Failed to refresh cache key %s: %v", key, err)
+			}
+		}()
+		return entry.Value, nil
+	case !errors.Is(err, ErrCacheMiss):
+		log.Printf("Cache unavailable for key %s, loading directly: %v", key, err)
+	}
+
+	return loadAndStore(cache, key, ttl, options, loader)
+}
+
+// loadAndStore calls the loader once per key across concurrent callers and caches the result
+func loadAndStore[T any](cache CacheClient, key string, ttl time.Duration, options loadOptions, loader func() (T, error)) (T, error) {
+	value, err, _ := cacheLoads.Do(fmt.Sprintf("%p|%s", cache, key), func() (interface{}, error) {
+		value, err := loader()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := cacheEntry[T]{Value: value, FreshUntil: time.Now().Add(ttl)}
+		// The store must not be cancelled with the request that happened to trigger the load
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := cache.Set(ctx, key, entry, ttl+options.stale); err != nil {
+			log.Printf("Failed to cache key %s: %v", key, err)
+		}
+		return value, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	typed, _ := value.(T)
+	return typed, nil
+}
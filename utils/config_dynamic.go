@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DynamicConfig holds a configuration that can change at runtime (log level, rate limits, feature flags, ...)
+// Get is lock free and always returns a complete snapshot, which must be treated as read only
+type DynamicConfig[T any] struct {
+	current atomic.Pointer[T]
+	load    func(ctx context.Context) (*T, error)
+
+	mu       sync.Mutex
+	handlers []func(old, updated *T)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDynamicConfig creates a dynamic config from a source, which is loaded once immediately
+// The source can read a file, a remote config service or anything else returning a fresh snapshot
+func NewDynamicConfig[T any](ctx context.Context, load func(ctx context.Context) (*T, error)) (*DynamicConfig[T], error) {
+	cfg, err := load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	d := &DynamicConfig[T]{load: load}
+	d.current.Store(cfg)
+	return d, nil
+}
+
+// NewFileDynamicConfig creates a dynamic config reloading path layered with environment variables (see LoadConfigFile)
+func NewFileDynamicConfig[T any](path, prefix string) (*DynamicConfig[T], error) {
+	return NewDynamicConfig(context.Background(), func(ctx context.Context) (*T, error) {
+		return LoadConfigFile[T](path, prefix)
+	})
+}
+
+// Get returns the current configuration snapshot
+func (d *DynamicConfig[T]) Get() *T {
+	return d.current.Load()
+}
+
+// OnChange registers a callback called with the previous and new snapshots after each change
+func (d *DynamicConfig[T]) OnChange(fn func(old, updated *T)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers = append(d.handlers, fn)
+}
+
+// Reload loads the source and swaps the snapshot if it changed, invalid configs keep the current snapshot
+func (d *DynamicConfig[T]) Reload(ctx context.Context) error {
+	updated, err := d.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	old := d.current.Load()
+	if reflect.DeepEqual(old, updated) {
+		return nil
+	}
+	d.current.Store(updated)
+
+	for _, handler := range d.handlers {
+		handler(old, updated)
+	}
+	return nil
+}
+
+// Start reloads the source every interval (30s when <= 0) in the background until Stop is called or ctx is done
+func (d *DynamicConfig[T]) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ctx, d.cancel = context.WithCancel(ctx)
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := d.Reload(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Config reload failed, keeping the current config: %v", err)
+			}
+		}
+	}()
+}
+
+// Stop stops the reload loop
+func (d *DynamicConfig[T]) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	envErrorsMu sync.Mutex
+	envErrors   []error
+)
+
+// reportEnvError logs an unparsable environment variable and records it for EnvParseErrors
+func reportEnvError(key, value string, err error) {
+	envErr := fmt.Errorf("invalid %s %q: %w", key, value, err)
+	log.Printf("%v, using the default value", envErr)
+
+	envErrorsMu.Lock()
+	envErrors = append(envErrors, envErr)
+	envErrorsMu.Unlock()
+}
+
+// EnvParseErrors returns the parse errors of every typed getter call so far, Config.Validate reports them
+func EnvParseErrors() []error {
+	envErrorsMu.Lock()
+	defer envErrorsMu.Unlock()
+	return append([]error(nil), envErrors...)
+}
+
+// GetEnvInt gets an integer environment variable, unparsable values are reported and fall back to the default
+func GetEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		reportEnvError(key, value, err)
+		return defaultValue
+	}
+	return n
+}
+
+// GetEnvBool gets a boolean environment variable (1, t, true, 0, f, false, ...), unparsable values fall back to the default
+func GetEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		reportEnvError(key, value, err)
+		return defaultValue
+	}
+	return b
+}
+
+// GetEnvDuration gets a duration environment variable such as "30s" or "5m", unparsable values fall back to the default
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		reportEnvError(key, value, err)
+		return defaultValue
+	}
+	return d
+}
+
+// GetEnvStringSlice gets a comma separated environment variable, values are trimmed and empty ones dropped
+func GetEnvStringSlice(key string, defaultValue []string) []string {
+	values := splitAndTrim(os.Getenv(key), ",")
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
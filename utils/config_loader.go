@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// LoadConfig populates a new T from environment variables described by struct tags:
+//
+//	type ServiceConfig struct {
+//		Port     int           `env:"PORT" default:"8000"`
+//		DBURL    string        `env:"DB_CONN_STRING,required"`
+//		Timeout  time.Duration `env:"TIMEOUT" default:"5s"`
+//		Brokers  []string      `env:"KAFKA_BROKERS"` // comma separated
+//		Storage  StorageConfig `envPrefix:"STORAGE_"` // nested struct, keys become STORAGE_...
+//		LogLevel string        `env:"LOG_LEVEL" default:"debug" default_production:"info"` // per APP_ENV default
+//		APIKey   string        `env:"PARTNER_KEY" redact:"true"` // masked by DumpConfig and RedactConfig
+//	}
+//
+//	cfg, err := utils.LoadConfig[ServiceConfig]("USER_")
+//
+// prefix is prepended to every key (USER_PORT above). Supported field types are strings, bools, ints, uints,
+// floats, time.Duration, slices of those, map[string]string ("k=v,k2=v2") and encoding.TextUnmarshaler.
+// Secret references such as "vault:secret/data/app#jwt_secret" are resolved (see RegisterSecretsProvider).
+// Struct fields may also carry `validate` tags (go-playground/validator) and T may implement Validate() error;
+// all missing required values, parse errors and validation problems are reported together as a *ConfigError
+func LoadConfig[T any](prefix string) (*T, error) {
+	return LoadConfigFile[T]("", prefix)
+}
+
+// LoadConfigFile is like LoadConfig but layers a config file between the defaults and the environment:
+// env > file > default tags. The format follows the extension (.yaml/.yml, .json or .toml), fields are
+// matched with json tags for JSON and YAML and toml tags for TOML (field names case insensitively otherwise).
+// An empty path skips the file, required fields may be satisfied by either the file or the environment
+func LoadConfigFile[T any](path, prefix string) (*T, error) {
+	cfg := new(T)
+	v := reflect.ValueOf(cfg).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config type must be a struct, got %s", v.Type())
+	}
+
+	var problems configProblems
+	applyDefaults(v, &problems)
+	if len(problems) > 0 {
+		return nil, problems.err()
+	}
+
+	if path != "" {
+		if err := decodeConfigFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	applyEnv(v, prefix, &problems)
+	if len(problems) > 0 {
+		return nil, problems.err()
+	}
+
+	// Values such as "vault:secret/data/app#jwt_secret" are replaced by the registered SecretsProvider
+	if err := ResolveSecrets(context.Background(), cfg); err != nil {
+		return nil, err
+	}
+
+	if err := validateLoaded(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decodeConfigFile unmarshals a YAML, JSON or TOML file into target
+func decodeConfigFile(path string, target interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, target)
+	case ".json":
+		err = json.Unmarshal(data, target)
+	case ".toml":
+		err = toml.Unmarshal(data, target)
+	default:
+		return fmt.Errorf("unsupported config file format %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// configField is a struct field tagged with env
+type configField struct {
+	value    reflect.Value
+	field    reflect.StructField
+	key      string
+	required bool
+}
+
+// walkConfig calls fn for every env tagged field, descending into untagged nested structs
+func walkConfig(v reflect.Value, prefix string, fn func(f configField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := v.Field(i)
+
+		tag, hasTag := field.Tag.Lookup("env")
+		if !hasTag || tag == "-" {
+			// Untagged structs are descended into so configs can be grouped
+			if tag != "-" && field.Type.Kind() == reflect.Struct && !isTextUnmarshaler(value) {
+				walkConfig(value, prefix+field.Tag.Get("envPrefix"), fn)
+			}
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		f := configField{value: value, field: field, key: prefix + parts[0]}
+		for _, opt := range parts[1:] {
+			if opt == "required" {
+				f.required = true
+			}
+		}
+		fn(f)
+	}
+}
+
+// applyDefaults sets every field that has a default tag, a default_<APP_ENV> tag takes precedence
+func applyDefaults(v reflect.Value, problems *configProblems) {
+	profileTag := "default_" + CurrentAppEnv()
+	walkConfig(v, "", func(f configField) {
+		raw, ok := f.field.Tag.Lookup(profileTag)
+		if !ok {
+			raw, ok = f.field.Tag.Lookup("default")
+		}
+		if !ok {
+			return
+		}
+		if err := setFieldValue(f.value, raw); err != nil {
+			problems.add("invalid default of %s: %v", f.field.Name, err)
+		}
+	})
+}
+
+// applyEnv overrides fields with the environment variables that are set and checks required fields
+func applyEnv(v reflect.Value, prefix string, problems *configProblems) {
+	walkConfig(v, prefix, func(f configField) {
+		raw, ok := os.LookupEnv(f.key)
+		if !ok || raw == "" {
+			if f.required && f.value.IsZero() {
+				problems.add("%s is required", f.key)
+			}
+			return
+		}
+		if err := setFieldValue(f.value, raw); err != nil {
+			problems.add("invalid %s: %v", f.key, err)
+		}
+	})
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// isTextUnmarshaler reports whether a pointer to v implements encoding.TextUnmarshaler
+func isTextUnmarshaler(v reflect.Value) bool {
+	if !v.CanAddr() {
+		return false
+	}
+	_, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// setFieldValue parses raw into v according to its type
+func setFieldValue(v reflect.Value, raw string) error {
+	if isTextUnmarshaler(v) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := splitAndTrim(raw, ",")
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFieldValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map type %s", v.Type())
+		}
+		m := reflect.MakeMap(v.Type())
+		for key, value := range parseKeyValues(raw) {
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), reflect.ValueOf(value).Convert(v.Type().Elem()))
+		}
+		v.Set(m)
+	case reflect.Pointer:
+		ptr := reflect.New(v.Type().Elem())
+		if err := setFieldValue(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(ptr)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// Application environments selected with APP_ENV
+const (
+	AppEnvDevelopment = "development"
+	AppEnvTest        = "test"
+	AppEnvStaging     = "staging"
+	AppEnvProduction  = "production"
+)
+
+// CurrentAppEnv returns the environment from APP_ENV, defaulting to development
+// Common abbreviations (dev, stg, prod) are normalized
+func CurrentAppEnv() string {
+	return normalizeAppEnv(os.Getenv("APP_ENV"))
+}
+
+// normalizeAppEnv maps APP_ENV values to the AppEnv constants
+func normalizeAppEnv(env string) string {
+	switch strings.ToLower(strings.TrimSpace(env)) {
+	case "", "dev", "develop", "development", "local":
+		return AppEnvDevelopment
+	case "stg", "stage", "staging":
+		return AppEnvStaging
+	case "prod", "production":
+		return AppEnvProduction
+	case "test", "testing":
+		return AppEnvTest
+	default:
+		return strings.ToLower(strings.TrimSpace(env))
+	}
+}
+
+// IsProduction reports whether the config was loaded for production
+func (c *Config) IsProduction() bool {
+	return c.AppEnv == AppEnvProduction
+}
+
+// IsStaging reports whether the config was loaded for staging
+func (c *Config) IsStaging() bool {
+	return c.AppEnv == AppEnvStaging
+}
+
+// IsDevelopment reports whether the config was loaded for development (the default)
+func (c *Config) IsDevelopment() bool {
+	return c.AppEnv == AppEnvDevelopment || c.AppEnv == ""
+}
+
+// addProductionProblems records settings that must never reach production
+func addProductionProblems(problems *configProblems, c *Config) {
+	if !c.IsProduction() {
+		return
+	}
+
+	if c.JWTSecret == DefaultJWTSecret {
+		problems.add("JWT_SECRET must be changed from the default value in production")
+	} else if len(c.JWTSecret) < MinJWTSecretLength {
+		problems.add("JWT_SECRET must be at least %d characters in production", MinJWTSecretLength)
+	}
+
+	if c.RedisPassword == "" && !isLoopbackRedis(c.Redis) {
+		problems.add("REDIS_PASSWORD is required in production when Redis is not on localhost")
+	}
+	if c.Redis.TLSInsecureSkipVerify {
+		problems.add("REDIS_TLS_INSECURE_SKIP_VERIFY must not be enabled in production")
+	}
+}
+
+// isLoopbackRedis reports whether every node of cfg is on the loopback interface
+func isLoopbackRedis(cfg RedisConfig) bool {
+	if len(cfg.Addrs) == 0 {
+		return isLoopbackHost(cfg.Host)
+	}
+	for _, addr := range cfg.Addrs {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil || !isLoopbackHost(host) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback address
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
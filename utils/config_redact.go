@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RedactedValue replaces secrets in printed and logged configs
+const RedactedValue = "[REDACTED]"
+
+// secretFieldNames are name fragments of fields treated as secrets without a redact tag
+var secretFieldNames = []string{"password", "secret", "token", "accesskey", "apikey", "privatekey", "credential"}
+
+// isSecretField reports whether a field must be redacted, `redact:"true"` and `redact:"false"` override the name check
+func isSecretField(field reflect.StructField) bool {
+	if tag, ok := field.Tag.Lookup("redact"); ok {
+		return tag == "true"
+	}
+	return isSecretName(field.Name)
+}
+
+// isSecretName reports whether name looks like it holds a secret
+func isSecretName(name string) bool {
+	lower := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	for _, fragment := range secretFieldNames {
+		if strings.Contains(lower, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString hides secret values and the password of URLs such as connection strings
+func redactString(value string, secret bool) string {
+	if value == "" {
+		return ""
+	}
+	if secret {
+		return RedactedValue
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), RedactedValue)
+			return strings.Replace(u.String(), url.QueryEscape(RedactedValue), RedactedValue, 1)
+		}
+	}
+	return value
+}
+
+// redactedField is a config field with its printable value
+type redactedField struct {
+	name  string
+	value interface{}
+}
+
+// redactFields returns the exported fields of a struct with secrets masked, nested structs become nested fields
+func redactFields(v reflect.Value) []redactedField {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	t := v.Type()
+	var fields []redactedField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fields = append(fields, redactedField{name: field.Name, value: redactValue(v.Field(i), isSecretField(field))})
+	}
+	return fields
+}
+
+// redactValue returns a printable copy of v with secrets masked
+func redactValue(v reflect.Value, secret bool) interface{} {
+	switch v.Kind() {
+	case reflect.String:
+		return redactString(v.String(), secret)
+	case reflect.Struct:
+		if _, ok := v.Interface().(fmt.Stringer); ok {
+			if secret {
+				return RedactedValue
+			}
+			return v.Interface()
+		}
+		return redactedMap(redactFields(v))
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), secret)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = redactValue(v.Index(i), secret)
+		}
+		return values
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		values := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			name := fmt.Sprint(key.Interface())
+			values[name] = redactValue(v.MapIndex(key), secret || isSecretName(name))
+		}
+		return values
+	default:
+		if secret && !v.IsZero() {
+			return RedactedValue
+		}
+		return v.Interface()
+	}
+}
+
+// redactedMap converts redacted fields to a map for JSON encoding
+func redactedMap(fields []redactedField) map[string]interface{} {
+	values := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		values[field.name] = field.value
+	}
+	return values
+}
+
+// RedactConfig returns the fields of a config struct (or pointer) as a map with secrets masked
+// Fields tagged `redact:"true"`, fields named like secrets (Password, Secret, Token, AccessKey, ...) and
+// passwords embedded in URLs are masked. Use `redact:"false"` to print a field despite its name
+func RedactConfig(cfg interface{}) map[string]interface{} {
+	return redactedMap(redactFields(reflect.ValueOf(cfg)))
+}
+
+// DumpConfig logs every field of a config struct with secrets masked, one line per field, for startup logs
+func DumpConfig(cfg interface{}) {
+	fields := redactFields(reflect.ValueOf(cfg))
+	for _, line := range flattenFields("", fields) {
+		log.Printf("Config %s", line)
+	}
+}
+
+// flattenFields renders fields as name=value lines, nested configs are prefixed with their parent name
+func flattenFields(prefix string, fields []redactedField) []string {
+	var lines []string
+	for _, field := range fields {
+		name := prefix + field.name
+		if nested, ok := field.value.(map[string]interface{}); ok && isStructMap(nested) {
+			lines = append(lines, flattenMap(name+".", nested)...)
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s=%v", name, field.value))
+	}
+	return lines
+}
+
+// isStructMap tells nested configs apart from map fields, which are printed inline
+func isStructMap(m map[string]interface{}) bool {
+	for key := range m {
+		if key == "" || key[0] < 'A' || key[0] > 'Z' {
+			return false
+		}
+	}
+	return len(m) > 0
+}
+
+// flattenMap renders a nested config map in sorted order
+func flattenMap(prefix string, m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]redactedField, len(names))
+	for i, name := range names {
+		fields[i] = redactedField{name: name, value: m[name]}
+	}
+	return flattenFields(prefix, fields)
+}
+
+// String prints the config with secrets masked, so logging a Config never leaks credentials
+func (c Config) String() string {
+	fields := redactFields(reflect.ValueOf(c))
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = fmt.Sprintf("%s: %v", field.name, field.value)
+	}
+	return "Config{" + strings.Join(parts, ", ") + "}"
+}
+
+// MarshalJSON encodes the config with secrets masked
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(RedactConfig(c))
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultJWTSecret is the placeholder JWT secret used when JWT_SECRET is not set
+const DefaultJWTSecret = "your_jwt_secret_key_here_change_in_production"
+
+// MinJWTSecretLength is the minimum accepted JWT secret length outside development
+const MinJWTSecretLength = 32
+
+// ConfigError reports every configuration problem found at startup
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// configProblems collects problems and turns them into a *ConfigError
+type configProblems []string
+
+func (p *configProblems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p configProblems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return &ConfigError{Problems: p}
+}
+
+// Validate checks required fields, URL formats, port ranges and secret strength, returning all problems
+// at once as a *ConfigError so misconfigured deployments fail fast with a complete report
+func (c *Config) Validate() error {
+	var problems configProblems
+	addStructProblems(&problems, c)
+	for _, err := range EnvParseErrors() {
+		problems.add("%v", err)
+	}
+	problems = append(problems, c.secretProblems...)
+
+	if c.DBConnString == "" {
+		problems.add("DB_CONN_STRING is required")
+	} else {
+		checkURL(&problems, "DB_CONN_STRING", c.DBConnString, "postgres", "postgresql")
+	}
+	for i, replica := range c.DBReplicaConnStrings {
+		checkURL(&problems, fmt.Sprintf("DB_REPLICA_CONN_STRINGS[%d]", i), replica, "postgres", "postgresql")
+	}
+	checkURL(&problems, "MIGRATION_URL", c.MigrationURL)
+	checkURL(&problems, "USER_SERVICE_URL", c.UserServiceURL, "http", "https")
+	checkURL(&problems, "ARTICLE_SERVICE_URL", c.ArticleServiceURL, "http", "https")
+	checkURL(&problems, "COMMENT_SERVICE_URL", c.CommentServiceURL, "http", "https")
+	checkURL(&problems, "STORAGE_ENDPOINT", c.StorageEndpoint, "http", "https")
+
+	checkPort(&problems, "PORT", c.Port)
+	checkPort(&problems, "REDIS_PORT", c.RedisPort)
+	for i, addr := range c.Redis.Addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || host == "" {
+			problems.add("REDIS_ADDRS[%d] must be a host:port address, got %q", i, addr)
+			continue
+		}
+		checkPort(&problems, fmt.Sprintf("REDIS_ADDRS[%d]", i), port)
+	}
+	switch c.Redis.Mode {
+	case "", RedisModeStandalone, RedisModeCluster:
+	case RedisModeSentinel:
+		if c.Redis.MasterName == "" {
+			problems.add("REDIS_MASTER_NAME is required when REDIS_MODE is %s", RedisModeSentinel)
+		}
+	default:
+		problems.add("REDIS_MODE must be %s, %s or %s, got %q", RedisModeStandalone, RedisModeSentinel, RedisModeCluster, c.Redis.Mode)
+	}
+	if c.DBHost != "" {
+		checkPort(&problems, "DB_PORT", c.DBPort)
+	}
+
+	if c.JWTSecret == "" {
+		problems.add("JWT_SECRET is required")
+	}
+	addProductionProblems(&problems, c)
+
+	return problems.err()
+}
+
+// addStructProblems runs the `validate` struct tags of v and records each failure
+func addStructProblems(problems *configProblems, v interface{}) {
+	err := validator.New().Struct(v)
+	if err == nil {
+		return
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		problems.add("%v", err)
+		return
+	}
+	for _, fe := range fieldErrors {
+		if fe.Tag() == "required" {
+			problems.add("%s is required", fe.Namespace())
+			continue
+		}
+		problems.add("%s failed the %s validation", fe.Namespace(), fe.Tag())
+	}
+}
+
+// checkURL records a problem when a non empty value is not an absolute URL with one of the schemes
+func checkURL(problems *configProblems, name, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		problems.add("%s must be an absolute URL", name)
+		return
+	}
+	if len(schemes) == 0 {
+		return
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return
+		}
+	}
+	problems.add("%s must use one of the schemes %s, got %q", name, strings.Join(schemes, ", "), u.Scheme)
+}
+
+// checkPort records a problem when a non empty value is not a port number
+func checkPort(problems *configProblems, name, value string) {
+	if value == "" {
+		return
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		problems.add("%s must be a port between 1 and 65535, got %q", name, value)
+	}
+}
+
+// validateLoaded runs the `validate` tags and the Validate method (if any) of a config loaded by LoadConfig
+func validateLoaded(cfg interface{}) error {
+	var problems configProblems
+	addStructProblems(&problems, cfg)
+
+	if v, ok := cfg.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			var configErr *ConfigError
+			if errors.As(err, &configErr) {
+				problems = append(problems, configErr.Problems...)
+			} else {
+				problems.add("%v", err)
+			}
+		}
+	}
+	return problems.err()
+}
@@ -3,10 +3,13 @@ package utils
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -21,78 +24,160 @@ type PGXPool interface {
 	Close()
 }
 
+// TxBeginner is implemented by pools beginning transactions with options, like *pgxpool.Pool and ReadWritePool
+// It is not part of PGXPool so existing implementations and mocks of PGXPool keep compiling, see BeginTx
+type TxBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// BeginTx begins a transaction with txOptions, pools that do not implement TxBeginner only support the default options
+func BeginTx(ctx context.Context, pool PGXPool, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	if beginner, ok := pool.(TxBeginner); ok {
+		return beginner.BeginTx(ctx, txOptions)
+	}
+	if txOptions != (pgx.TxOptions{}) {
+		return nil, errors.New("transaction options require a pool implementing BeginTx")
+	}
+	return pool.Begin(ctx)
+}
+
 // ConnectDBPool creates a new database connection pool with retry logic
 func ConnectDBPool(databaseURL string) (PGXPool, error) {
+	return ConnectDBPoolCtx(context.Background(), databaseURL, DefaultDBRetryPolicy())
+}
+
+// DefaultDBRetryPolicy returns the retry policy used by ConnectDBPool (5 attempts, 2 seconds apart)
+func DefaultDBRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     2 * time.Second,
+		Multiplier:  1,
+	}
+}
+
+// PoolOption customizes the pgxpool configuration before the pool is created
+type PoolOption func(*pgxpool.Config)
+
+// WithQueryTracer installs a pgx query tracer on every connection of the pool
+func WithQueryTracer(tracer pgx.QueryTracer) PoolOption {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.Tracer = tracer
+	}
+}
+
+// ConnectDBPoolCtx creates a new database connection pool, retrying according to the policy
+// The retry loop stops as soon as ctx is cancelled
+func ConnectDBPoolCtx(ctx context.Context, databaseURL string, policy RetryPolicy, opts ...PoolOption) (PGXPool, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database url: %w", err)
+	}
+	for _, opt := range opts {
+		opt(poolConfig)
+	}
+
 	var dbPool *pgxpool.Pool
-	var err error
+	attempt := 0
+	maxRetries := policy.Attempts()
 
-	// Retry logic for database connection
-	maxRetries := 5
-	for i := 0; i < maxRetries; i++ {
-		dbPool, err = pgxpool.New(context.Background(), databaseURL)
+	err = retry.Do(ctx, policy, func(ctx context.Context) error {
+		attempt++
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 		if err == nil {
 			// Test the connection
-			err = dbPool.Ping(context.Background())
+			err = pool.Ping(ctx)
 			if err == nil {
-				log.Println("Successfully connected to database")
-				return dbPool, nil
+				dbPool = pool
+				return nil
 			}
+			pool.Close()
 		}
 
-		log.Printf("Failed to connect to database (attempt %d/%d): %v", i+1, maxRetries, err)
-		if i < maxRetries-1 {
-			time.Sleep(time.Second * 2)
-		}
+		logger.Default().WarnContext(ctx, "failed to connect to database",
+			slog.Int("attempt", attempt), slog.Int("max_attempts", maxRetries), slog.String("error", err.Error()))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", attempt, err)
 	}
 
-	return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+	logger.Default().InfoContext(ctx, "connected to database")
+	return dbPool, nil
 }
 
-// ConnectDB creates a sql.DB connection for migrations
+// ConnectDB creates a sql.DB connection for migrations, retrying according to DefaultDBRetryPolicy
 func ConnectDB(databaseURL string) (*sql.DB, error) {
 	var db *sql.DB
-	var err error
+	attempt := 0
+	policy := DefaultDBRetryPolicy()
 
-	// Retry logic for database connection
-	maxRetries := 5
-	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("postgres", databaseURL)
+	err := retry.Do(context.Background(), policy, func(ctx context.Context) error {
+		attempt++
+		conn, err := sql.Open("postgres", databaseURL)
 		if err == nil {
 			// Test the connection
-			err = db.Ping()
+			err = conn.PingContext(ctx)
 			if err == nil {
-				log.Println("Successfully connected to database (sql.DB)")
-				return db, nil
+				db = conn
+				return nil
 			}
+			conn.Close()
 		}
 
-		log.Printf("Failed to connect to database (attempt %d/%d): %v", i+1, maxRetries, err)
-		if i < maxRetries-1 {
-			time.Sleep(time.Second * 2)
-		}
+		logger.Default().Warn("failed to connect to database",
+			slog.Int("attempt", attempt), slog.Int("max_attempts", policy.Attempts()), slog.String("error", err.Error()))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", attempt, err)
 	}
 
-	return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+	logger.Default().Info("connected to database", slog.String("driver", "postgres"))
+	return db, nil
 }
 
 // ExecTxPool executes a function within a database transaction
+// Savepoints only work through ExecTxContext: ExecTxPool joins a transaction carried by ctx, but fn does not
+// receive such a context, so an ExecTxPool call made inside fn starts an independent transaction on another
+// connection, which is not atomic with the outer one and can deadlock a small pool. The same goes for AfterCommit
 func ExecTxPool(ctx context.Context, pool PGXPool, fn func(pgx.Tx) error) error {
-	tx, err := pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
+	return ExecTxPoolWithOptions(ctx, pool, pgx.TxOptions{}, fn)
+}
 
-	err = fn(tx)
-	if err != nil {
-		if rbErr := tx.Rollback(ctx); rbErr != nil {
-			return fmt.Errorf("tx error: %v, rb error: %v", err, rbErr)
-		}
-		return err
+// ExecTxPoolWithOptions executes a function within a database transaction started with the given options
+// (isolation level, access mode, deferrable mode). Options are ignored when nested in an outer transaction
+func ExecTxPoolWithOptions(ctx context.Context, pool PGXPool, txOptions pgx.TxOptions, fn func(pgx.Tx) error) error {
+	return execTx(ctx, pool, txOptions, func(_ context.Context, tx pgx.Tx) error {
+		return fn(tx)
+	})
+}
+
+// IsSerializationFailure reports whether the error is a serialization failure (40001) or deadlock (40P01)
+// Transactions failing with these errors can safely be retried
+func IsSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
 	}
+	return false
+}
+
+// ExecTxWithRetry executes a function within a transaction, retrying the whole transaction
+// on serialization failures and deadlocks according to the policy
+func ExecTxWithRetry(ctx context.Context, pool PGXPool, txOptions pgx.TxOptions, policy RetryPolicy, fn func(pgx.Tx) error) error {
+	return ExecTxContextWithRetry(ctx, pool, txOptions, policy, func(_ context.Context, tx pgx.Tx) error {
+		return fn(tx)
+	})
+}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+// ExecTxContextWithRetry is ExecTxWithRetry passing fn the transaction context, hooks of failed attempts are rolled back
+func ExecTxContextWithRetry(ctx context.Context, pool PGXPool, txOptions pgx.TxOptions, policy RetryPolicy, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	// A serialization failure aborts the outer transaction, so only the outermost call can retry
+	if _, nested := TxFromContext(ctx); nested {
+		return execTx(ctx, pool, txOptions, fn)
 	}
 
-	return nil
+	return retry.Do(ctx, policy, func(ctx context.Context) error {
+		return execTx(ctx, pool, txOptions, fn)
+	}, retry.RetryIf(IsSerializationFailure))
 }
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// BuildPostgresDSN builds a postgres:// connection URL from discrete fields, escaping the user,
+// password and database name so special characters (@, :, /, %, ...) are safe
+// Empty port, sslmode and password are left out, params are added as query parameters (e.g. search_path)
+func BuildPostgresDSN(host, port, user, password, dbname, sslmode string, params map[string]string) string {
+	u := url.URL{
+		Scheme: "postgres",
+		Host:   host,
+		Path:   "/" + dbname,
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	}
+
+	switch {
+	case user != "" && password != "":
+		u.User = url.UserPassword(user, password)
+	case user != "":
+		u.User = url.User(user)
+	}
+
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	if sslmode != "" {
+		query.Set("sslmode", sslmode)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// parseKeyValues parses "key=value,key2=value2" into a map, ignoring malformed pairs
+func parseKeyValues(s string) map[string]string {
+	values := map[string]string{}
+	for _, pair := range splitAndTrim(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return values
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DBErrorDetails are the details of a CustomError returned by MapDBError
+type DBErrorDetails struct {
+	Constraint string `json:"constraint,omitempty"`
+	Table      string `json:"table,omitempty"`
+	Column     string `json:"column,omitempty"`
+}
+
+// MapDBError translates database errors into CustomErrors clients can act on:
+// pgx.ErrNoRows becomes 404, unique violations 409, foreign key and check violations 422 and not-null
+// violations 400, with the constraint in the details. Other errors, including CustomErrors, are returned as is
+func MapDBError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var customErr *CustomError
+	if errors.As(err, &customErr) {
+		return err
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return WrapError(err, ErrNotFound, "Record not found")
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	var code, message string
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		code, message = CodeConflict, "Record already exists"
+	case "23503": // foreign_key_violation
+		code, message = CodeInvalidReference, "Referenced record does not exist"
+	case "23514": // check_violation
+		code, message = CodeValidationFailed, "Value violates a constraint"
+	case "23502": // not_null_violation
+		code, message = CodeMissingField, "Required field is missing"
+	default:
+		return err
+	}
+
+	statusCode, _ := ErrorCodeStatus(code)
+	return &CustomError{
+		Code:       code,
+		Message:    message,
+		StatusCode: statusCode,
+		Details: DBErrorDetails{
+			Constraint: pgErr.ConstraintName,
+			Table:      pgErr.TableName,
+			Column:     pgErr.ColumnName,
+		},
+		Err: err,
+	}
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolStater is implemented by pools exposing pgxpool statistics
+type poolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+// DBPoolStats holds connection pool statistics
+type DBPoolStats struct {
+	TotalConns    int32 `json:"total_conns"`
+	AcquiredConns int32 `json:"acquired_conns"`
+	IdleConns     int32 `json:"idle_conns"`
+	MaxConns      int32 `json:"max_conns"`
+}
+
+// DBHealthStatus is the result of a database health check
+type DBHealthStatus struct {
+	Healthy   bool         `json:"healthy"`
+	Latency   string       `json:"latency"`
+	LatencyMs int64        `json:"latency_ms"`
+	Pool      *DBPoolStats `json:"pool,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	CheckedAt time.Time    `json:"checked_at"`
+}
+
+// DBHealthChecker reports database health, suitable for a /healthz endpoint
+type DBHealthChecker struct {
+	pool    PGXPool
+	timeout time.Duration
+}
+
+// NewDBHealthChecker creates a new database health checker
+func NewDBHealthChecker(pool PGXPool) *DBHealthChecker {
+	return &DBHealthChecker{
+		pool:    pool,
+		timeout: 2 * time.Second,
+	}
+}
+
+// Check runs a round-trip query against the database and collects pool statistics
+func (h *DBHealthChecker) Check(ctx context.Context) DBHealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := h.pool.Exec(ctx, "SELECT 1")
+	latency := time.Since(start)
+
+	status := DBHealthStatus{
+		Healthy:   err == nil,
+		Latency:   latency.String(),
+		LatencyMs: latency.Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	if stater, ok := h.pool.(poolStater); ok && stater.Stat() != nil {
+		stat := stater.Stat()
+		status.Pool = &DBPoolStats{
+			TotalConns:    stat.TotalConns(),
+			AcquiredConns: stat.AcquiredConns(),
+			IdleConns:     stat.IdleConns(),
+			MaxConns:      stat.MaxConns(),
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DBPoolCollector exports pgxpool statistics as Prometheus metrics
+// Statistics are read from the pool on every scrape, so values are always current
+type DBPoolCollector struct {
+	pool poolStater
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	newConnsCount        *prometheus.Desc
+	maxLifetimeDestroy   *prometheus.Desc
+	maxIdleDestroy       *prometheus.Desc
+}
+
+// NewDBPoolCollector creates a collector for the pool, name is exported as the "pool" label
+func NewDBPoolCollector(pool PGXPool, name string) (*DBPoolCollector, error) {
+	stater, ok := pool.(poolStater)
+	if !ok {
+		return nil, fmt.Errorf("pool %T does not expose statistics", pool)
+	}
+
+	labels := prometheus.Labels{"pool": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+metric, help, nil, labels)
+	}
+
+	return &DBPoolCollector{
+		pool:                 stater,
+		acquiredConns:        desc("acquired_conns", "Number of currently acquired connections."),
+		idleConns:            desc("idle_conns", "Number of currently idle connections."),
+		totalConns:           desc("total_conns", "Total number of connections in the pool."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		constructingConns:    desc("constructing_conns", "Number of connections being established."),
+		acquireCount:         desc("acquire_count_total", "Cumulative count of successful acquires."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Total time spent acquiring connections."),
+		emptyAcquireCount:    desc("empty_acquire_count_total", "Cumulative count of acquires that waited for a connection because the pool was empty."),
+		canceledAcquireCount: desc("canceled_acquire_count_total", "Cumulative count of acquires cancelled by a context."),
+		newConnsCount:        desc("new_conns_count_total", "Cumulative count of new connections opened."),
+		maxLifetimeDestroy:   desc("max_lifetime_destroy_count_total", "Cumulative count of connections closed due to MaxConnLifetime."),
+		maxIdleDestroy:       desc("max_idle_destroy_count_total", "Cumulative count of connections closed due to MaxConnIdleTime."),
+	}, nil
+}
+
+// RegisterDBPoolMetrics registers a pool collector with the registerer
+func RegisterDBPoolMetrics(registerer prometheus.Registerer, pool PGXPool, name string) error {
+	collector, err := NewDBPoolCollector(pool, name)
+	if err != nil {
+		return err
+	}
+	return registerer.Register(collector)
+}
+
+// Describe implements prometheus.Collector
+func (c *DBPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.constructingConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquireCount
+	ch <- c.canceledAcquireCount
+	ch <- c.newConnsCount
+	ch <- c.maxLifetimeDestroy
+	ch <- c.maxIdleDestroy
+}
+
+// Collect implements prometheus.Collector
+func (c *DBPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	if stat == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.newConnsCount, prometheus.CounterValue, float64(stat.NewConnsCount()))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeDestroy, prometheus.CounterValue, float64(stat.MaxLifetimeDestroyCount()))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleDestroy, prometheus.CounterValue, float64(stat.MaxIdleDestroyCount()))
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+	"github.com/go-sql-driver/mysql"
+)
+
+// SQLPoolOption customizes a database/sql connection pool
+type SQLPoolOption func(*sql.DB)
+
+// WithSQLPoolLimits sets the connection limits of a database/sql pool
+func WithSQLPoolLimits(maxOpen, maxIdle int, maxLifetime time.Duration) SQLPoolOption {
+	return func(db *sql.DB) {
+		db.SetMaxOpenConns(maxOpen)
+		db.SetMaxIdleConns(maxIdle)
+		db.SetConnMaxLifetime(maxLifetime)
+	}
+}
+
+// ConnectMySQLPool creates a MySQL/MariaDB connection pool with retry logic
+// dsn uses the go-sql-driver format, e.g. "user:password@tcp(host:3306)/dbname"
+func ConnectMySQLPool(dsn string) (*sql.DB, error) {
+	return ConnectMySQLPoolCtx(context.Background(), dsn, DefaultDBRetryPolicy())
+}
+
+// ConnectMySQLPoolCtx creates a MySQL/MariaDB connection pool, retrying according to the policy
+// parseTime is always enabled so DATETIME columns scan into time.Time. Running migrations with
+// multiple statements per file requires multiStatements=true in the dsn
+func ConnectMySQLPoolCtx(ctx context.Context, dsn string, policy RetryPolicy, opts ...SQLPoolOption) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mysql dsn: %w", err)
+	}
+	cfg.ParseTime = true
+
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mysql connector: %w", err)
+	}
+
+	db := sql.OpenDB(connector)
+	// MySQL closes idle connections on its side (wait_timeout), recycle them before that happens
+	db.SetConnMaxLifetime(3 * time.Minute)
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	attempt := 0
+	maxRetries := policy.Attempts()
+	err = retry.Do(ctx, policy, func(ctx context.Context) error {
+		attempt++
+		err := db.PingContext(ctx)
+		if err != nil {
+			logger.Default().WarnContext(ctx, "failed to connect to database", slog.String("driver", "mysql"),
+				slog.Int("attempt", attempt), slog.Int("max_attempts", maxRetries), slog.String("error", err.Error()))
+		}
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to mysql after %d attempts: %w", attempt, err)
+	}
+
+	logger.Default().InfoContext(ctx, "connected to database", slog.String("driver", "mysql"))
+	return db, nil
+}
+
+// NewMySQLAdapter wraps a MySQL pool so repositories written against DBTX with $N placeholders can use it
+func NewMySQLAdapter(db *sql.DB) *SQLAdapter {
+	return NewSQLAdapter(db, PlaceholderQuestion)
+}
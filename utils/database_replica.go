@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type usePrimaryKey struct{}
+
+type useReplicaKey struct{}
+
+// UsePrimary marks the context so that reads on a ReadWritePool go to the primary, overriding UseReplica
+// Use it for read-after-write consistency inside code paths whose caller opted into replicas
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usePrimaryKey{}, true)
+}
+
+// UseReplica marks the context so that Query and QueryRow on a ReadWritePool may go to a replica
+// Both are also used for writes returning rows (INSERT ... RETURNING), so replicas are only read when asked to
+func UseReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, useReplicaKey{}, true)
+}
+
+// replica is a replica pool with its last known health
+type replica struct {
+	pool    PGXPool
+	healthy atomic.Bool
+}
+
+// ReadWritePool implements PGXPool by routing everything to the primary, except Query and QueryRow with a
+// context marked by UseReplica, which go to a replica. Replicas are picked round-robin among the healthy ones, falling back to the primary when none is healthy
+type ReadWritePool struct {
+	primary  PGXPool
+	replicas []*replica
+	next     atomic.Uint64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewReadWritePool creates a new read/write splitting pool and starts replica health checks
+// A healthInterval <= 0 disables health checks and treats every replica as healthy
+func NewReadWritePool(primary PGXPool, replicas []PGXPool, healthInterval time.Duration) *ReadWritePool {
+	p := &ReadWritePool{primary: primary}
+	for _, pool := range replicas {
+		r := &replica{pool: pool}
+		r.healthy.Store(true)
+		p.replicas = append(p.replicas, r)
+	}
+
+	if healthInterval > 0 && len(p.replicas) > 0 {
+		var ctx context.Context
+		ctx, p.cancel = context.WithCancel(context.Background())
+		p.wg.Add(1)
+		go p.healthLoop(ctx, healthInterval)
+	}
+
+	return p
+}
+
+// ConnectReadWritePool connects to the primary and all replicas and returns a read/write splitting pool
+func ConnectReadWritePool(ctx context.Context, primaryURL string, replicaURLs []string, policy RetryPolicy, opts ...PoolOption) (PGXPool, error) {
+	primary, err := ConnectDBPoolCtx(ctx, primaryURL, policy, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]PGXPool, 0, len(replicaURLs))
+	for i, replicaURL := range replicaURLs {
+		pool, err := ConnectDBPoolCtx(ctx, replicaURL, policy, opts...)
+		if err != nil {
+			primary.Close()
+			for _, r := range replicas {
+				r.Close()
+			}
+			return nil, fmt.Errorf("failed to connect to replica %d: %w", i, err)
+		}
+		replicas = append(replicas, pool)
+	}
+
+	return NewReadWritePool(primary, replicas, 10*time.Second), nil
+}
+
+// ConnectConfigDBPool connects to cfg.DBConnString, through a ReadWritePool when cfg.DBReplicaConnStrings is set
+func ConnectConfigDBPool(ctx context.Context, cfg *Config, policy RetryPolicy, opts ...PoolOption) (PGXPool, error) {
+	if len(cfg.DBReplicaConnStrings) == 0 {
+		return ConnectDBPoolCtx(ctx, cfg.DBConnString, policy, opts...)
+	}
+	return ConnectReadWritePool(ctx, cfg.DBConnString, cfg.DBReplicaConnStrings, policy, opts...)
+}
+
+// Primary returns the primary pool
+func (p *ReadWritePool) Primary() PGXPool {
+	return p.primary
+}
+
+// reader picks the pool used for a read, the primary unless ctx opted into replicas
+func (p *ReadWritePool) reader(ctx context.Context) PGXPool {
+	useReplica, _ := ctx.Value(useReplicaKey{}).(bool)
+	if forcePrimary, _ := ctx.Value(usePrimaryKey{}).(bool); !useReplica || forcePrimary || len(p.replicas) == 0 {
+		return p.primary
+	}
+
+	start := p.next.Add(1)
+	for i := 0; i < len(p.replicas); i++ {
+		r := p.replicas[(start+uint64(i))%uint64(len(p.replicas))]
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+	return p.primary
+}
+
+// Stat returns the statistics of the primary pool, or nil when the primary does not expose them
+func (p *ReadWritePool) Stat() *pgxpool.Stat {
+	if stater, ok := p.primary.(poolStater); ok {
+		return stater.Stat()
+	}
+	return nil
+}
+
+// Begin starts a transaction on the primary
+func (p *ReadWritePool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return p.primary.Begin(ctx)
+}
+
+// BeginTx starts a transaction with the given options on the primary
+func (p *ReadWritePool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return BeginTx(ctx, p.primary, txOptions)
+}
+
+// Exec executes a statement on the primary
+func (p *ReadWritePool) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	return p.primary.Exec(ctx, sql, arguments...)
+}
+
+// Query runs a query on the primary, or on a replica when ctx was marked with UseReplica
+func (p *ReadWritePool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return p.reader(ctx).Query(ctx, sql, args...)
+}
+
+// QueryRow runs a single-row query on the primary, or on a replica when ctx was marked with UseReplica
+func (p *ReadWritePool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return p.reader(ctx).QueryRow(ctx, sql, args...)
+}
+
+// Close stops health checks and closes the primary and all replicas
+func (p *ReadWritePool) Close() {
+	if p.cancel != nil {
+		p.cancel()
+		p.wg.Wait()
+	}
+
+	for _, r := range p.replicas {
+		r.pool.Close()
+	}
+	p.primary.Close()
+}
+
+// healthLoop periodically pings replicas and updates their health
+func (p *ReadWritePool) healthLoop(ctx context.Context, interval time.Duration) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for i, r := range p.replicas {
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			_, err := r.pool.Exec(checkCtx, "SELECT 1")
+			cancel()
+
+			healthy := err == nil
+			if r.healthy.Swap(healthy) != healthy {
+				if healthy {
+					logger.Default().InfoContext(ctx, "database replica is healthy again", slog.Int("replica", i))
+				} else {
+					logger.Default().WarnContext(ctx, "database replica is unhealthy", slog.Int("replica", i), slog.String("error", err.Error()))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PlaceholderStyle is the bind parameter syntax of a database/sql driver
+type PlaceholderStyle int
+
+const (
+	// PlaceholderDollar keeps Postgres style $1, $2 placeholders
+	PlaceholderDollar PlaceholderStyle = iota
+	// PlaceholderQuestion rewrites $N placeholders to ? (MySQL, MariaDB, SQLite)
+	PlaceholderQuestion
+)
+
+// SQLQuerier is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type SQLQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// SQLAdapter exposes a database/sql connection through the pgx style Exec/Query/QueryRow methods,
+// so it satisfies repository.DBTX and queries written with $N placeholders run on other databases.
+// Errors keep the driver types, except sql.ErrNoRows which is reported as pgx.ErrNoRows
+type SQLAdapter struct {
+	db    SQLQuerier
+	style PlaceholderStyle
+}
+
+// NewSQLAdapter creates an adapter over db using the placeholder style of its driver
+func NewSQLAdapter(db SQLQuerier, style PlaceholderStyle) *SQLAdapter {
+	return &SQLAdapter{db: db, style: style}
+}
+
+// WithTx returns an adapter running on the transaction with the same placeholder style
+func (a *SQLAdapter) WithTx(tx *sql.Tx) *SQLAdapter {
+	return &SQLAdapter{db: tx, style: a.style}
+}
+
+// ExecTx runs fn in a transaction, committing if it returns nil and rolling back otherwise
+// The adapter must have been created over a *sql.DB
+func (a *SQLAdapter) ExecTx(ctx context.Context, fn func(tx *SQLAdapter) error) error {
+	db, ok := a.db.(*sql.DB)
+	if !ok {
+		return errors.New("transactions require an adapter created over *sql.DB")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(a.WithTx(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx error: %v, rb error: %v", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Exec executes a statement and returns a command tag carrying the affected row count
+func (a *SQLAdapter) Exec(ctx context.Context, query string, args ...interface{}) (pgconn.CommandTag, error) {
+	query, args, err := a.rebind(query, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	result, err := a.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		// Some drivers do not report affected rows for every statement
+		affected = 0
+	}
+	return pgconn.NewCommandTag(commandTag(query, affected)), nil
+}
+
+// Query executes a query returning rows
+func (a *SQLAdapter) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	query, args, err := a.rebind(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlRows{rows: rows, query: query}, nil
+}
+
+// QueryRow executes a query returning at most one row, errors are deferred to Scan
+func (a *SQLAdapter) QueryRow(ctx context.Context, query string, args ...interface{}) pgx.Row {
+	query, args, err := a.rebind(query, args)
+	if err != nil {
+		return sqlRow{err: err}
+	}
+	return sqlRow{row: a.db.QueryRowContext(ctx, query, args...)}
+}
+
+// rebind converts the placeholders of query to the adapter style
+func (a *SQLAdapter) rebind(query string, args []interface{}) (string, []interface{}, error) {
+	if a.style != PlaceholderQuestion {
+		return query, args, nil
+	}
+	return rebindQuestion(query, args)
+}
+
+// rebindQuestion replaces $N placeholders with ? and reorders args to match, $N may appear several times
+// Placeholders inside quoted strings and identifiers are left untouched, strings may escape quotes with a backslash
+func rebindQuestion(query string, args []interface{}) (string, []interface{}, error) {
+	var sb strings.Builder
+	sb.Grow(len(query))
+	bound := make([]interface{}, 0, len(args))
+
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' && i+1 < len(query) {
+				// MySQL escapes quotes with a backslash inside strings, e.g. 'it\'s $1'
+				sb.WriteByte(c)
+				i++
+				c = query[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			end := i + 1
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			n, _ := strconv.Atoi(query[i+1 : end])
+			if n < 1 || n > len(args) {
+				return "", nil, fmt.Errorf("placeholder $%d has no matching argument", n)
+			}
+			bound = append(bound, args[n-1])
+			sb.WriteByte('?')
+			i = end - 1
+			continue
+		}
+		sb.WriteByte(c)
+	}
+
+	if len(bound) == 0 {
+		// Queries already written with ? placeholders pass through unchanged
+		return query, args, nil
+	}
+	return sb.String(), bound, nil
+}
+
+// commandTag builds a Postgres style command tag so pgconn.CommandTag helpers (RowsAffected, Insert, ...) work
+func commandTag(query string, affected int64) string {
+	verb := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+	if verb == "INSERT" {
+		return fmt.Sprintf("INSERT 0 %d", affected)
+	}
+	return fmt.Sprintf("%s %d", verb, affected)
+}
+
+// sqlRow adapts *sql.Row to pgx.Row
+type sqlRow struct {
+	row *sql.Row
+	err error
+}
+
+// Scan reads the row, returning pgx.ErrNoRows when there is none
+func (r sqlRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	err := r.row.Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+	return err
+}
+
+// sqlRows adapts *sql.Rows to pgx.Rows
+type sqlRows struct {
+	rows    *sql.Rows
+	query   string
+	count   int64
+	columns []string
+	err     error
+}
+
+func (r *sqlRows) Close() {
+	r.rows.Close()
+}
+
+func (r *sqlRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.rows.Err()
+}
+
+func (r *sqlRows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(commandTag(r.query, r.count))
+}
+
+func (r *sqlRows) FieldDescriptions() []pgconn.FieldDescription {
+	columns, err := r.columnNames()
+	if err != nil {
+		return nil
+	}
+
+	fields := make([]pgconn.FieldDescription, len(columns))
+	for i, name := range columns {
+		fields[i] = pgconn.FieldDescription{Name: name}
+	}
+	return fields
+}
+
+func (r *sqlRows) Next() bool {
+	if !r.rows.Next() {
+		return false
+	}
+	r.count++
+	return true
+}
+
+func (r *sqlRows) Scan(dest ...interface{}) error {
+	return r.rows.Scan(dest...)
+}
+
+func (r *sqlRows) Values() ([]interface{}, error) {
+	columns, err := r.columnNames()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(columns))
+	targets := make([]interface{}, len(columns))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err := r.rows.Scan(targets...); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// RawValues is not supported by database/sql and always returns nil
+func (r *sqlRows) RawValues() [][]byte {
+	return nil
+}
+
+// Conn returns nil, the rows do not come from a pgx connection
+func (r *sqlRows) Conn() *pgx.Conn {
+	return nil
+}
+
+// columnNames returns the column names, cached after the first call
+func (r *sqlRows) columnNames() ([]string, error) {
+	if r.columns != nil {
+		return r.columns, nil
+	}
+	columns, err := r.rows.Columns()
+	if err != nil {
+		r.err = err
+		return nil, err
+	}
+	r.columns = columns
+	return columns, nil
+}
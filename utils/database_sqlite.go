@@ -0,0 +1,57 @@
+//go:build sqlite
+
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// SQLite support is only compiled with the sqlite build tag (go test -tags sqlite ./...),
+// so services that do not need it do not pull the SQLite engine into their binaries
+
+// DialectSQLite selects SQLite in BaseConfig.Dialect
+const DialectSQLite = "sqlite"
+
+func init() {
+	// The SQLite driver uses db directly and closing it would close db, so there is nothing to release
+	migrationDrivers[DialectSQLite] = func(ctx context.Context, db *sql.DB) (database.Driver, func(), error) {
+		driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+		return driver, func() {}, err
+	}
+}
+
+// ConnectSQLite opens a SQLite database file, or a private in-memory database for ":memory:"
+// Foreign keys are enforced and file databases use WAL journaling so readers do not block the writer
+func ConnectSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite has a single writer, and every connection to :memory: would get its own empty database
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	log.Printf("Successfully opened sqlite database %s", path)
+	return db, nil
+}
+
+// NewSQLiteAdapter wraps a SQLite database so repositories written against DBTX with $N placeholders can use it
+func NewSQLiteAdapter(db *sql.DB) *SQLAdapter {
+	return NewSQLAdapter(db, PlaceholderQuestion)
+}
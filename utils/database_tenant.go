@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the tenant identifier
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant identifier carried by ctx, if any
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// tenantPattern restricts tenant identifiers to characters that are safe in schema names and are not case
+// folded or rewritten, so every tenant maps to its own schema
+var tenantPattern = regexp.MustCompile(`^[a-z0-9_]{1,48}$`)
+
+// ValidTenantID reports whether tenant is an acceptable tenant identifier
+func ValidTenantID(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// ErrTenantRequired is returned when a tenant scoped operation has no tenant in its context
+var ErrTenantRequired = NewCustomError("tenant is required", http.StatusBadRequest)
+
+// TenantConn is a pooled connection whose search_path is set to a tenant schema
+// It must be released with Release, which resets the search_path before returning it to the pool
+type TenantConn struct {
+	*pgxpool.Conn
+	Schema string
+}
+
+// Release resets the search_path and returns the connection to the pool
+// If the reset fails the connection is closed instead, so no other request can inherit the tenant schema
+func (c *TenantConn) Release() {
+	if _, err := c.Conn.Exec(context.Background(), "RESET search_path"); err != nil {
+		c.Conn.Conn().Close(context.Background())
+	}
+	c.Conn.Release()
+}
+
+// TenantDBOption configures a TenantDB
+type TenantDBOption func(*TenantDB)
+
+// WithTenantSchema sets how tenant identifiers map to schema names, the default is "tenant_<id>"
+// The mapping must give distinct tenants distinct schemas, otherwise they share data
+func WithTenantSchema(schemaFor func(tenant string) string) TenantDBOption {
+	return func(t *TenantDB) {
+		t.schemaFor = schemaFor
+	}
+}
+
+// TenantDB hands out connections scoped to a tenant schema, for schema-per-tenant databases
+type TenantDB struct {
+	pool      *pgxpool.Pool
+	schemaFor func(tenant string) string
+}
+
+// NewTenantDB creates a TenantDB, pool must be a *pgxpool.Pool such as the one returned by ConnectDBPool
+func NewTenantDB(pool PGXPool, opts ...TenantDBOption) (*TenantDB, error) {
+	pgxPool, ok := pool.(*pgxpool.Pool)
+	if !ok {
+		return nil, fmt.Errorf("tenant db requires a *pgxpool.Pool, got %T", pool)
+	}
+
+	t := &TenantDB{
+		pool: pgxPool,
+		schemaFor: func(tenant string) string {
+			return "tenant_" + tenant
+		},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t, nil
+}
+
+// Schema returns the schema name of the tenant
+func (t *TenantDB) Schema(tenant string) string {
+	return t.schemaFor(tenant)
+}
+
+// Acquire acquires a connection with search_path set to the tenant schema (then public)
+func (t *TenantDB) Acquire(ctx context.Context, tenant string) (*TenantConn, error) {
+	if !ValidTenantID(tenant) {
+		return nil, NewCustomError("invalid tenant", http.StatusBadRequest)
+	}
+
+	conn, err := t.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	schema := t.schemaFor(tenant)
+	if _, err := conn.Exec(ctx, fmt.Sprintf("SET search_path TO %s, public", pgx.Identifier{schema}.Sanitize())); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to set search_path for tenant %s: %w", tenant, err)
+	}
+
+	return &TenantConn{Conn: conn, Schema: schema}, nil
+}
+
+// Run acquires a connection for the tenant carried by ctx (see ContextWithTenant), calls fn and releases it
+// The connection satisfies repository.DBTX, so it can be passed to repository.New
+func (t *TenantDB) Run(ctx context.Context, fn func(ctx context.Context, conn *TenantConn) error) error {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+
+	conn, err := t.Acquire(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	return fn(ctx, conn)
+}
+
+// RunTx is like Run but calls fn inside a transaction on the tenant connection
+func (t *TenantDB) RunTx(ctx context.Context, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	return t.Run(ctx, func(ctx context.Context, conn *TenantConn) error {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		if err := fn(ctx, tx); err != nil {
+			if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+				return fmt.Errorf("tx error: %v, rb error: %v", err, rbErr)
+			}
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+}
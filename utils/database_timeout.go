@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WithStatementTimeout sets statement_timeout on every connection of the pool, so the server aborts
+// statements running longer than d even if the client went away
+func WithStatementTimeout(d time.Duration) PoolOption {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(d.Milliseconds(), 10)
+	}
+}
+
+// timeoutPool applies a default timeout to queries whose context has no deadline
+type timeoutPool struct {
+	PGXPool
+	timeout time.Duration
+}
+
+// NewTimeoutPool wraps a pool so Exec, Query and QueryRow run with a default timeout when the caller's
+// context has no deadline. Transactions and CopyFrom are not affected, they use the context given to them.
+// Stat and CopyFrom of the wrapped pool stay available through type assertions
+func NewTimeoutPool(pool PGXPool, timeout time.Duration) PGXPool {
+	p := &timeoutPool{PGXPool: pool, timeout: timeout}
+	if _, ok := pool.(copier); ok {
+		// CopyFrom is only exposed when the wrapped pool has it, so callers can still detect COPY support
+		return &copyTimeoutPool{timeoutPool: p}
+	}
+	return p
+}
+
+// copier is implemented by pools supporting the COPY protocol, like *pgxpool.Pool
+type copier interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// copyTimeoutPool is a timeoutPool over a pool supporting COPY
+type copyTimeoutPool struct {
+	*timeoutPool
+}
+
+func (p *copyTimeoutPool) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return p.PGXPool.(copier).CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+// Stat returns the statistics of the wrapped pool, or nil when it does not expose them
+func (p *timeoutPool) Stat() *pgxpool.Stat {
+	if stater, ok := p.PGXPool.(poolStater); ok {
+		return stater.Stat()
+	}
+	return nil
+}
+
+// withTimeout returns ctx with the default timeout unless it already has a deadline
+func (p *timeoutPool) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || p.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.timeout)
+}
+
+func (p *timeoutPool) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return p.PGXPool.Exec(ctx, sql, arguments...)
+}
+
+func (p *timeoutPool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	rows, err := p.PGXPool.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The context must outlive Query until the rows are read
+	return &cancelRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (p *timeoutPool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, cancel := p.withTimeout(ctx)
+	return &cancelRow{row: p.PGXPool.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+// BeginTx forwards to the wrapped pool, transactions are not affected by the timeout
+func (p *timeoutPool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return BeginTx(ctx, p.PGXPool, txOptions)
+}
+
+// cancelRows releases the query context when the rows are closed
+type cancelRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r *cancelRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+// Next closes the rows once exhausted, as pgx does, so the context is released even without Close
+func (r *cancelRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.cancel()
+	return false
+}
+
+// cancelRow releases the query context after Scan
+type cancelRow struct {
+	row    pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r *cancelRow) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.row.Scan(dest...)
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracerConfig configures the query tracer
+type QueryTracerConfig struct {
+	SlowThreshold time.Duration // queries slower than this are always logged, 0 disables slow query logging
+	LogAllQueries bool          // log every query, not only slow or failed ones
+	LogArgs       bool          // log argument values instead of redacting them
+	Tracer        trace.Tracer  // emits an OpenTelemetry span per query when set
+}
+
+// QueryTracer implements pgx.QueryTracer, logging queries and emitting spans
+type QueryTracer struct {
+	config QueryTracerConfig
+}
+
+type queryTraceKey struct{}
+
+// queryTrace holds the state of an in-flight query
+type queryTrace struct {
+	start time.Time
+	sql   string
+	args  []any
+	span  trace.Span
+}
+
+// NewQueryTracer creates a new query tracer, install it with WithQueryTracer
+func NewQueryTracer(config QueryTracerConfig) *QueryTracer {
+	return &QueryTracer{config: config}
+}
+
+// TraceQueryStart is called at the beginning of Query, QueryRow and Exec calls
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	qt := &queryTrace{
+		start: time.Now(),
+		sql:   data.SQL,
+		args:  data.Args,
+	}
+
+	if t.config.Tracer != nil {
+		ctx, qt.span = t.config.Tracer.Start(ctx, "db.query",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.statement", data.SQL),
+			),
+		)
+	}
+
+	return context.WithValue(ctx, queryTraceKey{}, qt)
+}
+
+// TraceQueryEnd is called at the end of Query, QueryRow and Exec calls
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	qt, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(qt.start)
+	rows := data.CommandTag.RowsAffected()
+
+	if qt.span != nil {
+		qt.span.SetAttributes(attribute.Int64("db.rows_affected", rows))
+		if data.Err != nil {
+			qt.span.RecordError(data.Err)
+			qt.span.SetStatus(codes.Error, data.Err.Error())
+		}
+		qt.span.End()
+	}
+
+	slow := t.config.SlowThreshold > 0 && duration >= t.config.SlowThreshold
+	switch {
+	case data.Err != nil:
+		log.Printf("Query failed after %s: %s args=%s error=%v", duration, compactSQL(qt.sql), t.formatArgs(qt.args), data.Err)
+	case slow:
+		log.Printf("Slow query took %s (%d rows): %s args=%s", duration, rows, compactSQL(qt.sql), t.formatArgs(qt.args))
+	case t.config.LogAllQueries:
+		log.Printf("Query took %s (%d rows): %s args=%s", duration, rows, compactSQL(qt.sql), t.formatArgs(qt.args))
+	}
+}
+
+// formatArgs renders query arguments, replacing values with their type unless LogArgs is enabled
+func (t *QueryTracer) formatArgs(args []any) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if t.config.LogArgs {
+			parts[i] = fmt.Sprintf("$%d=%v", i+1, arg)
+		} else {
+			parts[i] = fmt.Sprintf("$%d=<%T>", i+1, arg)
+		}
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// compactSQL collapses whitespace so multi-line queries fit on one log line
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/jackc/pgx/v5"
+)
+
+type txContextKey struct{}
+
+type txHooksContextKey struct{}
+
+// txHooks holds callbacks registered inside a transaction scope
+type txHooks struct {
+	mu            sync.Mutex
+	afterCommit   []func(ctx context.Context)
+	afterRollback []func(ctx context.Context)
+}
+
+// AfterCommit registers a callback that runs only after the enclosing transaction commits successfully,
+// e.g. publishing events or invalidating caches. Callbacks registered inside a savepoint are promoted to
+// the outer transaction when the savepoint is released. ctx must be the one passed to the function of
+// ExecTxContext or ExecTxContextWithOptions, without a transaction in ctx the callback is logged and discarded
+// since nothing guarantees the surrounding writes were committed
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	hooks, ok := ctx.Value(txHooksContextKey{}).(*txHooks)
+	if !ok {
+		caller := ""
+		if frames := CallerStack(1); len(frames) > 0 {
+			caller = frames[0].Function
+		}
+		logger.Default().WarnContext(ctx, "AfterCommit called without a transaction in ctx, callback discarded",
+			slog.String("caller", caller))
+		return
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.afterCommit = append(hooks.afterCommit, fn)
+}
+
+// AfterRollback registers a callback that runs after the enclosing transaction (or savepoint) is rolled back
+// Without a transaction in ctx the callback is discarded
+func AfterRollback(ctx context.Context, fn func(ctx context.Context)) {
+	hooks, ok := ctx.Value(txHooksContextKey{}).(*txHooks)
+	if !ok {
+		return
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.afterRollback = append(hooks.afterRollback, fn)
+}
+
+// promote moves the callbacks of a released savepoint to its parent scope
+func (h *txHooks) promote(parent *txHooks) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
+
+	parent.afterCommit = append(parent.afterCommit, h.afterCommit...)
+	parent.afterRollback = append(parent.afterRollback, h.afterRollback...)
+}
+
+// run runs the registered callbacks in registration order
+func (h *txHooks) run(ctx context.Context, committed bool) {
+	h.mu.Lock()
+	callbacks := h.afterRollback
+	if committed {
+		callbacks = h.afterCommit
+	}
+	h.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(ctx)
+	}
+}
+
+// ContextWithTx returns a copy of ctx carrying the transaction, so nested helpers can join it
+func ContextWithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// ExecTxContext executes a function within a database transaction and passes it a context carrying the transaction
+// Calls to ExecTxPool/ExecTxContext made with that context open a SAVEPOINT instead of a new transaction,
+// and roll back to it on failure, so service methods compose regardless of whether a parent holds a transaction
+// They are the entry points to use with AfterCommit and AfterRollback
+func ExecTxContext(ctx context.Context, pool PGXPool, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	return execTx(ctx, pool, pgx.TxOptions{}, fn)
+}
+
+// ExecTxContextWithOptions is ExecTxContext with transaction options, ignored when nested in an outer transaction
+func ExecTxContextWithOptions(ctx context.Context, pool PGXPool, txOptions pgx.TxOptions, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	return execTx(ctx, pool, txOptions, fn)
+}
+
+// execTx begins a transaction (or a savepoint when nested), runs fn and commits or rolls back
+func execTx(ctx context.Context, pool PGXPool, txOptions pgx.TxOptions, fn func(ctx context.Context, tx pgx.Tx) error) (err error) {
+	var tx pgx.Tx
+	if parent, nested := TxFromContext(ctx); nested {
+		// Begin on a pgx.Tx creates a savepoint
+		tx, err = parent.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create savepoint: %w", err)
+		}
+	} else {
+		tx, err = BeginTx(ctx, pool, txOptions)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+	}
+
+	parentHooks, _ := ctx.Value(txHooksContextKey{}).(*txHooks)
+	hooks := &txHooks{}
+	txCtx := context.WithValue(ContextWithTx(ctx, tx), txHooksContextKey{}, hooks)
+
+	// finish runs or promotes the hooks once the outcome of this scope is known
+	finish := func(committed bool) {
+		if committed && parentHooks != nil {
+			hooks.promote(parentHooks)
+			return
+		}
+		hooks.run(ctx, committed)
+	}
+
+	// Roll back if fn panics (e.g. PanicIfError) so the connection is not leaked with an open transaction
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(ctx)
+			finish(false)
+			panic(p)
+		}
+	}()
+
+	err = fn(txCtx, tx)
+	if err != nil {
+		rbErr := tx.Rollback(ctx)
+		finish(false)
+		if rbErr != nil {
+			return fmt.Errorf("tx error: %v, rb error: %v", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		finish(false)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	finish(true)
+	return nil
+}
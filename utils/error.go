@@ -1,12 +1,43 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+)
 
 // CustomError represents a custom error with HTTP status code
+// Code is a stable machine-readable identifier clients can branch on, see RegisterErrorCode
+// Details are rendered to clients as is, e.g. field errors or the violated constraint
 type CustomError struct {
+	Code       string
 	Message    string
 	StatusCode int
+	Details    interface{}
 	Err        error
+	stack      []uintptr
+}
+
+// ErrorOption configures a CustomError created by NewCustomErrorWithTrace
+type ErrorOption func(*CustomError)
+
+// WithStack captures the stack trace of the caller, reported by ErrorReporter implementations
+// It costs a few microseconds, so keep it for unexpected errors rather than validation failures
+func WithStack() ErrorOption {
+	return func(e *CustomError) {
+		// Skip runtime.Callers, this closure and NewCustomErrorWithTrace
+		pcs := make([]uintptr, 32)
+		n := runtime.Callers(3, pcs)
+		e.stack = pcs[:n]
+	}
+}
+
+// StackTrace returns the frames captured with WithStack, nil when none were captured
+func (e *CustomError) StackTrace() []runtime.Frame {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	return callersFrames(e.stack)
 }
 
 func (e *CustomError) Error() string {
@@ -16,6 +47,65 @@ func (e *CustomError) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the wrapped error so errors.Is(err, pgx.ErrNoRows) keeps working through a CustomError
+func (e *CustomError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is a CustomError with the same code, e.g. errors.Is(err, ErrNotFound)
+// Errors without a code match a sentinel with the same status
+func (e *CustomError) Is(target error) bool {
+	t, ok := target.(*CustomError)
+	if !ok || t.Code == "" {
+		return false
+	}
+	if e.Code == "" {
+		return e.StatusCode == t.StatusCode
+	}
+	return e.Code == t.Code
+}
+
+// Sentinel errors to compare against with errors.Is, create errors matching them with the constructors below
+var (
+	ErrNotFound     = &CustomError{Code: CodeNotFound, Message: "Not found", StatusCode: http.StatusNotFound}
+	ErrUnauthorized = &CustomError{Code: CodeUnauthorized, Message: "Unauthorized", StatusCode: http.StatusUnauthorized}
+	ErrConflict     = &CustomError{Code: CodeConflict, Message: "Conflict", StatusCode: http.StatusConflict}
+	ErrValidation   = &CustomError{Code: CodeValidationFailed, Message: "Validation failed", StatusCode: http.StatusUnprocessableEntity}
+)
+
+// NewNotFoundError creates an error matching ErrNotFound
+func NewNotFoundError(message string) error {
+	return WrapError(nil, ErrNotFound, message)
+}
+
+// NewUnauthorizedError creates an error matching ErrUnauthorized
+func NewUnauthorizedError(message string) error {
+	return WrapError(nil, ErrUnauthorized, message)
+}
+
+// NewConflictError creates an error matching ErrConflict
+func NewConflictError(message string) error {
+	return WrapError(nil, ErrConflict, message)
+}
+
+// NewValidationFailedError creates an error matching ErrValidation
+func NewValidationFailedError(message string) error {
+	return WrapError(nil, ErrValidation, message)
+}
+
+// WrapError wraps err in an error matching sentinel, message defaults to the message of sentinel
+func WrapError(err error, sentinel *CustomError, message string) error {
+	if message == "" {
+		message = sentinel.Message
+	}
+	return &CustomError{
+		Code:       sentinel.Code,
+		Message:    message,
+		StatusCode: sentinel.StatusCode,
+		Err:        err,
+	}
+}
+
 // NewCustomError creates a new custom error
 func NewCustomError(message string, statusCode int) error {
 	return &CustomError{
@@ -24,13 +114,17 @@ func NewCustomError(message string, statusCode int) error {
 	}
 }
 
-// NewCustomErrorWithTrace creates a new custom error with trace
-func NewCustomErrorWithTrace(err error, message string, statusCode int) error {
-	return &CustomError{
+// NewCustomErrorWithTrace creates a new custom error with trace, pass WithStack to capture the stack trace
+func NewCustomErrorWithTrace(err error, message string, statusCode int, opts ...ErrorOption) error {
+	customErr := &CustomError{
 		Message:    message,
 		StatusCode: statusCode,
 		Err:        err,
 	}
+	for _, opt := range opts {
+		opt(customErr)
+	}
+	return customErr
 }
 
 // PanicIfError panics if error is not nil
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// Error codes shared by the modules, services register their own with RegisterErrorCode
+const (
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeTokenExpired     = "TOKEN_EXPIRED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeInvalidReference = "INVALID_REFERENCE"
+	CodeMissingField     = "MISSING_FIELD"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeQuotaExceeded    = "QUOTA_EXCEEDED"
+	CodeInternal         = "INTERNAL_ERROR"
+	CodeUnavailable      = "SERVICE_UNAVAILABLE"
+)
+
+var (
+	errorCodesMu sync.RWMutex
+	errorCodes   = map[string]int{
+		CodeBadRequest:       http.StatusBadRequest,
+		CodeUnauthorized:     http.StatusUnauthorized,
+		CodeTokenExpired:     http.StatusUnauthorized,
+		CodeForbidden:        http.StatusForbidden,
+		CodeNotFound:         http.StatusNotFound,
+		CodeConflict:         http.StatusConflict,
+		CodeValidationFailed: http.StatusUnprocessableEntity,
+		CodeInvalidReference: http.StatusUnprocessableEntity,
+		CodeMissingField:     http.StatusBadRequest,
+		CodeRateLimited:      http.StatusTooManyRequests,
+		CodeQuotaExceeded:    http.StatusTooManyRequests,
+		CodeInternal:         http.StatusInternalServerError,
+		CodeUnavailable:      http.StatusServiceUnavailable,
+	}
+)
+
+// RegisterErrorCode sets the default HTTP status of an error code, e.g. RegisterErrorCode("USER_NOT_FOUND", 404)
+func RegisterErrorCode(code string, statusCode int) {
+	errorCodesMu.Lock()
+	defer errorCodesMu.Unlock()
+	errorCodes[code] = statusCode
+}
+
+// ErrorCodeStatus returns the HTTP status registered for code
+func ErrorCodeStatus(code string) (int, bool) {
+	errorCodesMu.RLock()
+	defer errorCodesMu.RUnlock()
+	statusCode, ok := errorCodes[code]
+	return statusCode, ok
+}
+
+// NewCodedError creates a custom error with a machine-readable code, the status is the one registered
+// for the code or 500 when it is unknown
+func NewCodedError(code, message string) error {
+	return NewCodedErrorWithTrace(nil, code, message)
+}
+
+// NewCodedErrorWithTrace creates a custom error with a code wrapping err
+func NewCodedErrorWithTrace(err error, code, message string) error {
+	statusCode, ok := ErrorCodeStatus(code)
+	if !ok {
+		statusCode = http.StatusInternalServerError
+	}
+	return &CustomError{
+		Code:       code,
+		Message:    message,
+		StatusCode: statusCode,
+		Err:        err,
+	}
+}
+
+// ErrorCode returns the code of the CustomError in the chain of err, empty if there is none
+func ErrorCode(err error) string {
+	var customErr *CustomError
+	if errors.As(err, &customErr) {
+		return customErr.Code
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// ErrorReport is an unexpected error with the context of the request that raised it
+type ErrorReport struct {
+	Err       error
+	Status    int
+	Method    string
+	Path      string
+	RequestID string
+	UserID    string
+	TenantID  string
+	Panic     bool
+	Stack     []runtime.Frame // where the error was created or the panic raised, may be empty
+}
+
+// ErrorReporter sends unexpected errors to an error tracker such as Sentry (see utils/sentry)
+// Report is called on the request goroutine and must not block
+type ErrorReporter interface {
+	Report(ctx context.Context, report ErrorReport)
+}
+
+// ErrorReporterFunc adapts a function to ErrorReporter
+type ErrorReporterFunc func(ctx context.Context, report ErrorReport)
+
+func (f ErrorReporterFunc) Report(ctx context.Context, report ErrorReport) {
+	f(ctx, report)
+}
+
+var globalErrorReporter ErrorReporter
+
+// SetGlobalErrorReporter sets the reporter used by the recovery middleware
+func SetGlobalErrorReporter(reporter ErrorReporter) {
+	globalErrorReporter = reporter
+}
+
+// GetGlobalErrorReporter returns the global error reporter, nil if none is set
+func GetGlobalErrorReporter() ErrorReporter {
+	return globalErrorReporter
+}
+
+// maxErrorChain bounds the CustomErrors walked by StackTraceOf, As implementations may build a new
+// CustomError on every call so a cycle is not always visible through pointers
+const maxErrorChain = 32
+
+// StackTraceOf returns the stack captured by the first CustomError of the chain of err that has one
+func StackTraceOf(err error) []runtime.Frame {
+	seen := map[*CustomError]bool{}
+	for i := 0; err != nil && i < maxErrorChain; i++ {
+		var customErr *CustomError
+		if !errors.As(err, &customErr) || seen[customErr] {
+			return nil
+		}
+		seen[customErr] = true
+		if frames := customErr.StackTrace(); frames != nil {
+			return frames
+		}
+		err = customErr.Err
+	}
+	return nil
+}
+
+// RecoverAndReport recovers a panic into *err and reports it to the global error reporter if any
+// It must be deferred directly, e.g. defer utils.RecoverAndReport(ctx, w.Name(), &err), path names the
+// component that panicked in the error and the report
+func RecoverAndReport(ctx context.Context, path string, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if recoveredErr, ok := recovered.(error); ok {
+		*err = fmt.Errorf("%s panicked: %w", path, recoveredErr)
+	} else {
+		*err = fmt.Errorf("%s panicked: %v", path, recovered)
+	}
+	if reporter := GetGlobalErrorReporter(); reporter != nil {
+		// Skip RecoverAndReport and runtime.gopanic, the stack starts where the panic was raised
+		reporter.Report(ctx, ErrorReport{Err: *err, Path: path, Panic: true, Stack: CallerStack(2)})
+	}
+}
+
+// CallerStack returns the stack of the caller of CallerStack, skipping skip more frames
+func CallerStack(skip int) []runtime.Frame {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip+2, pcs)
+	return callersFrames(pcs[:n])
+}
+
+// callersFrames resolves program counters returned by runtime.Callers
+func callersFrames(pcs []uintptr) []runtime.Frame {
+	var frames []runtime.Frame
+	iter := runtime.CallersFrames(pcs)
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, frame)
+		if !more {
+			return frames
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Backends selectable with Config.Backend
+const (
+	BackendRedis     = "redis"
+	BackendJetStream = "jetstream"
+	BackendMemory    = "memory"
+)
+
+// Config selects and configures the bus backend, zero values use the backend defaults
+type Config struct {
+	Backend           string        // redis (default), jetstream or memory
+	Prefix            string        // Redis stream key prefix or JetStream subject prefix
+	VisibilityTimeout time.Duration // redelivery delay of unacknowledged envelopes (Redis visibility timeout, JetStream ack wait)
+	MaxLen            int64         // Redis only, approximate stream length cap
+	NATSURL           string        // JetStream only
+	MaxAge            time.Duration // JetStream only, stream retention
+	Replicas          int           // JetStream only, stream replicas
+}
+
+// ConfigFromEnv reads the EVENT_BUS_* variables and NATS_URL
+func ConfigFromEnv() Config {
+	return Config{
+		Backend:           utils.GetEnv("EVENT_BUS_BACKEND", BackendRedis),
+		Prefix:            utils.GetEnv("EVENT_BUS_PREFIX", ""),
+		VisibilityTimeout: utils.GetEnvDuration("EVENT_BUS_VISIBILITY_TIMEOUT", 0),
+		MaxLen:            int64(utils.GetEnvInt("EVENT_BUS_MAX_LEN", 0)),
+		NATSURL:           utils.GetEnv("NATS_URL", nats.DefaultURL),
+		MaxAge:            utils.GetEnvDuration("EVENT_BUS_MAX_AGE", 0),
+		Replicas:          utils.GetEnvInt("EVENT_BUS_REPLICAS", 0),
+	}
+}
+
+// RunnableBus is a bus consuming in the background, run it with runner.Go(bus.Run) or a worker.Manager
+type RunnableBus interface {
+	Bus
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// NewBus creates the bus selected by cfg.Backend, redisClient is only used by the Redis backend
+// The JetStream backend connects to cfg.NATSURL and closes the connection when it stops
+func NewBus(cfg Config, redisClient redis.Cmdable) (RunnableBus, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", BackendRedis:
+		if redisClient == nil {
+			return nil, fmt.Errorf("redis event bus requires a redis client")
+		}
+		var opts []RedisOption
+		if cfg.Prefix != "" {
+			opts = append(opts, WithStreamPrefix(cfg.Prefix))
+		}
+		if cfg.VisibilityTimeout > 0 {
+			opts = append(opts, WithVisibilityTimeout(cfg.VisibilityTimeout))
+		}
+		if cfg.MaxLen > 0 {
+			opts = append(opts, WithMaxLen(cfg.MaxLen))
+		}
+		return NewRedisBus(redisClient, opts...), nil
+
+	case BackendJetStream:
+		nc, err := nats.Connect(cfg.NATSURL, nats.Name(utils.GetEnv("SERVICE_NAME", "")))
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to nats: %w", err)
+		}
+		opts := []JetStreamOption{WithStreamConfig(cfg.MaxAge, cfg.Replicas)}
+		if cfg.Prefix != "" {
+			opts = append(opts, WithSubjectPrefix(cfg.Prefix))
+		}
+		if cfg.VisibilityTimeout > 0 {
+			opts = append(opts, WithAckWait(cfg.VisibilityTimeout))
+		}
+		bus, err := NewJetStreamBus(nc, opts...)
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+		bus.conn = nc
+		return bus, nil
+
+	case BackendMemory:
+		return NewMemoryBus(), nil
+	}
+	return nil, fmt.Errorf("unsupported event bus backend %q", cfg.Backend)
+}
@@ -0,0 +1,112 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID carries the request ID of the publisher to the handlers
+const HeaderRequestID = "request_id"
+
+// Envelope is the JSON message exchanged on the bus
+type Envelope struct {
+	ID         string            `json:"id"` // unique, handlers use it to drop duplicates
+	Type       string            `json:"type"`
+	Topic      string            `json:"topic"`
+	Key        string            `json:"key,omitempty"` // partitioning key on brokers that have partitions
+	OccurredAt time.Time         `json:"occurred_at"`
+	Payload    json.RawMessage   `json:"payload"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+// NewEnvelope creates an envelope with a new ID and payload marshaled to JSON
+func NewEnvelope(eventType string, payload interface{}) (Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+	}
+	return Envelope{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Payload:    data,
+	}, nil
+}
+
+// Decode unmarshals the JSON payload into v
+func (e Envelope) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Handler processes an envelope, returning an error leaves it for redelivery
+// Handlers must be idempotent, delivery is at-least-once
+type Handler func(ctx context.Context, env Envelope) error
+
+// Publisher publishes envelopes on topics, implemented by every Bus and by broker producers like kafka.Producer
+type Publisher interface {
+	Publish(ctx context.Context, topic string, env Envelope) error
+}
+
+// Bus publishes envelopes on topics and delivers them to subscribers
+// Each group receives every envelope of the topic once, the members of a group share the envelopes
+type Bus interface {
+	Publisher
+	Subscribe(topic, group string, handler Handler) error
+}
+
+// Publish wraps payload in an envelope of eventType and publishes it on topic
+func Publish[T any](ctx context.Context, bus Publisher, topic, eventType string, payload T) error {
+	env, err := NewEnvelope(eventType, payload)
+	if err != nil {
+		return err
+	}
+	return bus.Publish(ctx, topic, env)
+}
+
+// Subscribe registers a handler receiving the payloads of topic decoded as T, wrapped in middlewares
+// Payloads that do not decode are permanent failures, DeadLetter moves them aside without retrying
+func Subscribe[T any](bus Bus, topic, group string, handler func(ctx context.Context, env Envelope, payload T) error, middlewares ...Middleware) error {
+	return bus.Subscribe(topic, group, Chain(func(ctx context.Context, env Envelope) error {
+		var payload T
+		if err := env.Decode(&payload); err != nil {
+			return retry.MarkPermanent(fmt.Errorf("failed to decode %s payload: %w", env.Type, err))
+		}
+		return handler(ctx, env, payload)
+	}, middlewares...))
+}
+
+// Prepare fills the envelope fields left empty by the publisher and the request ID header,
+// for Bus implementations and producers outside this package
+func Prepare(ctx context.Context, topic string, env Envelope) Envelope {
+	if env.ID == "" {
+		env.ID = uuid.NewString()
+	}
+	if env.OccurredAt.IsZero() {
+		env.OccurredAt = time.Now().UTC()
+	}
+	env.Topic = topic
+
+	if requestID := utils.RequestIDFromContext(ctx); requestID != "" && env.Headers[HeaderRequestID] == "" {
+		headers := make(map[string]string, len(env.Headers)+1)
+		for name, value := range env.Headers {
+			headers[name] = value
+		}
+		headers[HeaderRequestID] = requestID
+		env.Headers = headers
+	}
+	return env
+}
+
+// HandlerContext carries the request ID of the publisher into the handler context
+func HandlerContext(ctx context.Context, env Envelope) context.Context {
+	if requestID := env.Headers[HeaderRequestID]; requestID != "" {
+		return utils.ContextWithRequestID(ctx, requestID)
+	}
+	return ctx
+}
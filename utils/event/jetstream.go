@@ -0,0 +1,301 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JetStreamOption configures a JetStreamBus
+type JetStreamOption func(*JetStreamBus)
+
+// WithSubjectPrefix sets the prefix of the subjects, topic "orders" is published on "<prefix>.orders" (default "events")
+func WithSubjectPrefix(prefix string) JetStreamOption {
+	return func(b *JetStreamBus) {
+		b.prefix = prefix
+	}
+}
+
+// WithStreamConfig sets the retention of the provisioned streams, 0 values keep the server defaults
+func WithStreamConfig(maxAge time.Duration, replicas int) JetStreamOption {
+	return func(b *JetStreamBus) {
+		b.maxAge = maxAge
+		b.replicas = replicas
+	}
+}
+
+// WithAckWait sets after how long an envelope the handler did not acknowledge is delivered again (default 5m)
+func WithAckWait(d time.Duration) JetStreamOption {
+	return func(b *JetStreamBus) {
+		b.ackWait = d
+	}
+}
+
+// WithMaxDeliver caps the deliveries of an envelope, after which the server stops redelivering it
+// (default -1, unlimited, bound the attempts with the Retry and DeadLetter middlewares instead)
+func WithMaxDeliver(n int) JetStreamOption {
+	return func(b *JetStreamBus) {
+		b.maxDeliver = n
+	}
+}
+
+// WithRedeliveryBackoff sets the delay before a failed envelope is delivered again, by delivery count
+// (default 1s up to 5m)
+func WithRedeliveryBackoff(policy retry.Policy) JetStreamOption {
+	return func(b *JetStreamBus) {
+		b.backoff = policy
+	}
+}
+
+// WithDeliverAll makes new groups start with the envelopes already in the stream instead of the new ones
+func WithDeliverAll() JetStreamOption {
+	return func(b *JetStreamBus) {
+		b.deliverPolicy = jetstream.DeliverAllPolicy
+	}
+}
+
+// JetStreamBus is an at-least-once bus on NATS JetStream: one stream per topic, provisioned on first use, and
+// one durable pull consumer per group with explicit acknowledgements. Publishing is deduplicated by envelope ID
+// within the stream duplicate window. Failed envelopes are negatively acknowledged with a backoff
+type JetStreamBus struct {
+	js            jetstream.JetStream
+	conn          *nats.Conn // closed on Stop when the bus opened it
+	prefix        string
+	maxAge        time.Duration
+	replicas      int
+	ackWait       time.Duration
+	maxDeliver    int
+	backoff       retry.Policy
+	deliverPolicy jetstream.DeliverPolicy
+
+	mu            sync.Mutex
+	streams       map[string]bool
+	subscriptions []subscription
+	consumers     []jetstream.ConsumeContext
+	handlers      sync.WaitGroup
+}
+
+// NewJetStreamBus creates a bus on nc, the connection is not closed by the bus
+func NewJetStreamBus(nc *nats.Conn, opts ...JetStreamOption) (*JetStreamBus, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+
+	b := &JetStreamBus{
+		js:            js,
+		prefix:        "events",
+		ackWait:       5 * time.Minute,
+		maxDeliver:    -1,
+		backoff:       retry.Policy{Backoff: time.Second, MaxBackoff: 5 * time.Minute, Multiplier: 2, Jitter: 0.2},
+		deliverPolicy: jetstream.DeliverNewPolicy,
+		streams:       map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b, nil
+}
+
+// Subject returns the subject of a topic
+func (b *JetStreamBus) Subject(topic string) string {
+	return b.prefix + "." + topic
+}
+
+// StreamName returns the name of the stream of a topic
+func (b *JetStreamBus) StreamName(topic string) string {
+	return jetStreamName(b.prefix + "_" + topic)
+}
+
+// EnsureStream creates or updates the stream of topic, publishing and subscribing call it on first use
+func (b *JetStreamBus) EnsureStream(ctx context.Context, topic string) error {
+	b.mu.Lock()
+	ready := b.streams[topic]
+	b.mu.Unlock()
+	if ready {
+		return nil
+	}
+
+	_, err := b.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      b.StreamName(topic),
+		Subjects:  []string{b.Subject(topic)},
+		Retention: jetstream.LimitsPolicy,
+		MaxAge:    b.maxAge,
+		Replicas:  b.replicas,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to provision stream for %s: %w", topic, err)
+	}
+
+	b.mu.Lock()
+	b.streams[topic] = true
+	b.mu.Unlock()
+	return nil
+}
+
+// EnsureConsumer creates or updates the durable consumer of group on topic
+func (b *JetStreamBus) EnsureConsumer(ctx context.Context, topic, group string) (jetstream.Consumer, error) {
+	if err := b.EnsureStream(ctx, topic); err != nil {
+		return nil, err
+	}
+
+	consumer, err := b.js.CreateOrUpdateConsumer(ctx, b.StreamName(topic), jetstream.ConsumerConfig{
+		Durable:       jetStreamName(group),
+		FilterSubject: b.Subject(topic),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       b.ackWait,
+		MaxDeliver:    b.maxDeliver,
+		DeliverPolicy: b.deliverPolicy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision consumer %s on %s: %w", group, topic, err)
+	}
+	return consumer, nil
+}
+
+// Publish publishes the envelope on the subject of topic and waits for the stream acknowledgement
+func (b *JetStreamBus) Publish(ctx context.Context, topic string, env Envelope) error {
+	env = Prepare(ctx, topic, env)
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", env.ID, err)
+	}
+	if err := b.EnsureStream(ctx, topic); err != nil {
+		return err
+	}
+
+	if _, err := b.js.Publish(ctx, b.Subject(topic), data, jetstream.WithMsgID(env.ID)); err != nil {
+		return fmt.Errorf("failed to publish event on %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe registers the handler of group for topic, it must be called before Start
+func (b *JetStreamBus) Subscribe(topic, group string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, sub := range b.subscriptions {
+		if sub.topic == topic && sub.group == group {
+			return fmt.Errorf("group %s already subscribed to %s", group, topic)
+		}
+	}
+	b.subscriptions = append(b.subscriptions, subscription{topic: topic, group: group, handler: handler})
+	return nil
+}
+
+// Name implements worker.Worker
+func (b *JetStreamBus) Name() string {
+	return "event-bus"
+}
+
+// Run consumes until ctx is canceled and waits for the handlers in progress, for runner.Go or a worker.Manager
+func (b *JetStreamBus) Run(ctx context.Context) error {
+	if err := b.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	b.Stop()
+	return nil
+}
+
+// Start provisions the streams and consumers and consumes in the background until Stop is called
+func (b *JetStreamBus) Start(ctx context.Context) error {
+	b.mu.Lock()
+	subscriptions := append([]subscription(nil), b.subscriptions...)
+	b.mu.Unlock()
+
+	// Handlers in progress finish on a context that is not canceled by Stop
+	handlerCtx := context.WithoutCancel(ctx)
+	for _, sub := range subscriptions {
+		consumer, err := b.EnsureConsumer(ctx, sub.topic, sub.group)
+		if err != nil {
+			b.Stop()
+			return err
+		}
+
+		sub := sub
+		consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+			b.handlers.Add(1)
+			defer b.handlers.Done()
+			b.process(handlerCtx, sub, msg)
+		})
+		if err != nil {
+			b.Stop()
+			return fmt.Errorf("failed to consume %s as %s: %w", sub.topic, sub.group, err)
+		}
+
+		b.mu.Lock()
+		b.consumers = append(b.consumers, consumeCtx)
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop stops consuming and waits for the handlers in progress
+func (b *JetStreamBus) Stop() {
+	b.mu.Lock()
+	consumers := b.consumers
+	b.consumers = nil
+	b.mu.Unlock()
+
+	for _, consumer := range consumers {
+		consumer.Stop()
+	}
+	for _, consumer := range consumers {
+		<-consumer.Closed()
+	}
+	b.handlers.Wait()
+
+	if b.conn != nil {
+		b.conn.Close()
+	}
+}
+
+// process runs the handler and acknowledges the envelope, or asks for a redelivery after a backoff
+func (b *JetStreamBus) process(ctx context.Context, sub subscription, msg jetstream.Msg) {
+	log := logger.Named("event").With("topic", sub.topic, "group", sub.group)
+
+	var env Envelope
+	if err := json.Unmarshal(msg.Data(), &env); err != nil {
+		// Nothing can ever handle it, terminate it rather than redelivering it forever
+		log.Error("dropping malformed event", "error", err)
+		msg.Term()
+		return
+	}
+
+	if err := Chain(sub.handler, Recover())(HandlerContext(ctx, env), env); err != nil {
+		delivered := 1
+		if meta, metaErr := msg.Metadata(); metaErr == nil {
+			delivered = int(meta.NumDelivered)
+		}
+		delay := b.backoff.Delay(delivered - 1)
+		log.ErrorContext(ctx, "event handler failed", "event_id", env.ID, "type", env.Type,
+			"deliveries", delivered, "redeliver_in", delay, "error", err)
+		if nakErr := msg.NakWithDelay(delay); nakErr != nil {
+			log.ErrorContext(ctx, "failed to nak event", "event_id", env.ID, "error", nakErr)
+		}
+		return
+	}
+	if err := msg.Ack(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+		log.ErrorContext(ctx, "failed to acknowledge event", "event_id", env.ID, "error", err)
+	}
+}
+
+// invalidJetStreamName matches the characters stream and consumer names cannot contain
+var invalidJetStreamName = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// jetStreamName converts a topic or group into a valid stream or durable consumer name
+func jetStreamName(name string) string {
+	return strings.ToUpper(invalidJetStreamName.ReplaceAllString(name, "_"))
+}
@@ -0,0 +1,66 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MemoryBus delivers envelopes synchronously to the handlers of every group, in the publisher goroutine
+// Publish returns the handler errors, which makes it convenient in tests and single process tools
+type MemoryBus struct {
+	mu       sync.RWMutex
+	handlers map[string]map[string]Handler // topic -> group -> handler
+}
+
+// NewMemoryBus creates an in-process bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{handlers: map[string]map[string]Handler{}}
+}
+
+// Publish calls the handler of each group subscribed to topic and joins their errors
+func (b *MemoryBus) Publish(ctx context.Context, topic string, env Envelope) error {
+	env = Prepare(ctx, topic, env)
+
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers[topic]))
+	for _, handler := range b.handlers[topic] {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(HandlerContext(ctx, env), env); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribe registers the handler of group for topic
+func (b *MemoryBus) Subscribe(topic, group string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.handlers[topic] == nil {
+		b.handlers[topic] = map[string]Handler{}
+	}
+	if _, ok := b.handlers[topic][group]; ok {
+		return fmt.Errorf("group %s already subscribed to %s", group, topic)
+	}
+	b.handlers[topic][group] = handler
+	return nil
+}
+
+// Name implements worker.Worker
+func (b *MemoryBus) Name() string {
+	return "event-bus"
+}
+
+// Run blocks until ctx is canceled, handlers run in the publisher goroutine
+func (b *MemoryBus) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,82 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+)
+
+// Dead-letter headers added by DeadLetter
+const (
+	HeaderDeadLetterError = "dead_letter_error"
+	HeaderDeadLetterTopic = "dead_letter_topic"
+	HeaderDeadLetterAt    = "dead_letter_at"
+)
+
+// Middleware wraps a handler
+type Middleware func(next Handler) Handler
+
+// Chain wraps handler in middlewares, the first one is the outermost
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Retry retries the handler in process according to policy, except errors marked with retry.MarkPermanent
+func Retry(policy retry.Policy) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, env Envelope) error {
+			return retry.Do(ctx, policy, func(ctx context.Context) error {
+				return next(ctx, env)
+			}, retry.OnRetry(func(attempt int, err error, delay time.Duration) {
+				logger.Named("event").WarnContext(ctx, "event handler failed, retrying",
+					"event_id", env.ID, "type", env.Type, "attempt", attempt+1, "delay", delay, "error", err)
+			}))
+		}
+	}
+}
+
+// DeadLetter publishes envelopes the handler failed on to topic with publisher and reports them handled,
+// so one poison message does not block or loop forever. Place it outside Retry
+func DeadLetter(publisher Publisher, topic string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, env Envelope) error {
+			err := next(ctx, env)
+			if err == nil {
+				return nil
+			}
+
+			dead := env
+			dead.Headers = make(map[string]string, len(env.Headers)+3)
+			for name, value := range env.Headers {
+				dead.Headers[name] = value
+			}
+			dead.Headers[HeaderDeadLetterError] = err.Error()
+			dead.Headers[HeaderDeadLetterTopic] = env.Topic
+			dead.Headers[HeaderDeadLetterAt] = time.Now().UTC().Format(time.RFC3339)
+
+			if dlqErr := publisher.Publish(context.WithoutCancel(ctx), topic, dead); dlqErr != nil {
+				return fmt.Errorf("failed to dead-letter event %s: %w (handler error: %v)", env.ID, dlqErr, err)
+			}
+			logger.Named("event").ErrorContext(ctx, "event dead-lettered",
+				"event_id", env.ID, "type", env.Type, "topic", env.Topic, "dead_letter_topic", topic, "error", err)
+			return nil
+		}
+	}
+}
+
+// Recover converts handler panics into errors reported to the global error reporter if any
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, env Envelope) (err error) {
+			defer utils.RecoverAndReport(ctx, "event handler for "+env.Type, &err)
+			return next(ctx, env)
+		}
+	}
+}
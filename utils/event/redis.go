@@ -0,0 +1,273 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOption configures a RedisBus
+type RedisOption func(*RedisBus)
+
+// WithStreamPrefix sets the prefix of the stream keys (default "event:")
+func WithStreamPrefix(prefix string) RedisOption {
+	return func(b *RedisBus) {
+		b.prefix = prefix
+	}
+}
+
+// WithMaxLen approximately caps every stream to n entries (default 0, unbounded)
+func WithMaxLen(n int64) RedisOption {
+	return func(b *RedisBus) {
+		b.maxLen = n
+	}
+}
+
+// WithVisibilityTimeout sets after how long an envelope left unacknowledged by a failed handler or a crashed
+// consumer is delivered again (default 5m), it must be longer than the slowest handler including its retries
+func WithVisibilityTimeout(d time.Duration) RedisOption {
+	return func(b *RedisBus) {
+		b.visibilityTimeout = d
+	}
+}
+
+// WithConsumerName sets the consumer name in the groups (default hostname and a random suffix)
+func WithConsumerName(name string) RedisOption {
+	return func(b *RedisBus) {
+		b.consumer = name
+	}
+}
+
+// subscription is a handler registered on a RedisBus
+type subscription struct {
+	topic   string
+	group   string
+	handler Handler
+}
+
+// RedisBus is an at-least-once bus on Redis Streams, one stream per topic and one consumer group per group
+// Envelopes are acknowledged once the handler returns nil, failed ones are delivered again after the
+// visibility timeout, use the Retry and DeadLetter middlewares to bound the attempts.
+// New groups start with the envelopes published after their creation
+type RedisBus struct {
+	client            redis.Cmdable
+	prefix            string
+	maxLen            int64
+	visibilityTimeout time.Duration
+	consumer          string
+	block             time.Duration
+
+	mu            sync.Mutex
+	subscriptions []subscription
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+}
+
+// NewRedisBus creates a bus on client
+func NewRedisBus(client redis.Cmdable, opts ...RedisOption) *RedisBus {
+	hostname, _ := os.Hostname()
+	b := &RedisBus{
+		client:            client,
+		prefix:            "event:",
+		visibilityTimeout: 5 * time.Minute,
+		consumer:          hostname + "-" + uuid.NewString()[:8],
+		block:             2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Stream returns the stream key of a topic
+func (b *RedisBus) Stream(topic string) string {
+	return b.prefix + topic
+}
+
+// Publish appends the envelope to the stream of topic
+func (b *RedisBus) Publish(ctx context.Context, topic string, env Envelope) error {
+	env = Prepare(ctx, topic, env)
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", env.ID, err)
+	}
+
+	args := &redis.XAddArgs{
+		Stream: b.Stream(topic),
+		Values: map[string]interface{}{"envelope": data},
+	}
+	if b.maxLen > 0 {
+		args.MaxLen = b.maxLen
+		args.Approx = true
+	}
+	if err := b.client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("failed to publish event on %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe registers the handler of group for topic, it must be called before Start
+func (b *RedisBus) Subscribe(topic, group string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, sub := range b.subscriptions {
+		if sub.topic == topic && sub.group == group {
+			return fmt.Errorf("group %s already subscribed to %s", group, topic)
+		}
+	}
+	b.subscriptions = append(b.subscriptions, subscription{topic: topic, group: group, handler: handler})
+	return nil
+}
+
+// Name implements worker.Worker
+func (b *RedisBus) Name() string {
+	return "event-bus"
+}
+
+// Run consumes until ctx is canceled and waits for the handlers in progress, for runner.Go or a worker.Manager
+func (b *RedisBus) Run(ctx context.Context) error {
+	if err := b.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	b.Stop()
+	return nil
+}
+
+// Start creates the consumer groups if needed and consumes in the background until Stop is called or ctx is done
+func (b *RedisBus) Start(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, sub := range b.subscriptions {
+		err := b.client.XGroupCreateMkStream(ctx, b.Stream(sub.topic), sub.group, "$").Err()
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group %s on %s: %w", sub.group, sub.topic, err)
+		}
+	}
+
+	// Handlers in progress finish on a context that is not canceled by Stop
+	handlerCtx := context.WithoutCancel(ctx)
+	ctx, b.cancel = context.WithCancel(ctx)
+	for _, sub := range b.subscriptions {
+		b.wg.Add(1)
+		go func(sub subscription) {
+			defer b.wg.Done()
+			b.consume(ctx, handlerCtx, sub)
+		}(sub)
+	}
+	return nil
+}
+
+// Stop stops consuming and waits for the handlers in progress
+func (b *RedisBus) Stop() {
+	b.mu.Lock()
+	cancel := b.cancel
+	b.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	b.wg.Wait()
+}
+
+// consume reads the abandoned and new envelopes of a subscription
+func (b *RedisBus) consume(ctx, handlerCtx context.Context, sub subscription) {
+	log := logger.Named("event").With("topic", sub.topic, "group", sub.group)
+	stream := b.Stream(sub.topic)
+	backoff := retry.Policy{Backoff: 500 * time.Millisecond, MaxBackoff: 30 * time.Second, Jitter: 0.2}
+
+	attempt := 0
+	nextClaim := time.Now()
+	for ctx.Err() == nil {
+		var messages []redis.XMessage
+		var err error
+
+		if time.Now().After(nextClaim) {
+			messages, _, err = b.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   stream,
+				Group:    sub.group,
+				Consumer: b.consumer,
+				MinIdle:  b.visibilityTimeout,
+				Start:    "0-0",
+				Count:    10,
+			}).Result()
+			nextClaim = time.Now().Add(b.visibilityTimeout / 2)
+		}
+
+		if err == nil && len(messages) == 0 {
+			var streams []redis.XStream
+			streams, err = b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    sub.group,
+				Consumer: b.consumer,
+				Streams:  []string{stream, ">"},
+				Count:    10,
+				Block:    b.block,
+			}).Result()
+			if errors.Is(err, redis.Nil) {
+				err = nil
+			}
+			for _, s := range streams {
+				messages = append(messages, s.Messages...)
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn("failed to read events", "attempt", attempt+1, "error", err)
+			timer := time.NewTimer(backoff.Delay(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		for _, msg := range messages {
+			if ctx.Err() != nil {
+				// Unprocessed envelopes stay pending and are claimed again after the visibility timeout
+				return
+			}
+			b.process(handlerCtx, sub, stream, msg)
+		}
+	}
+}
+
+// process runs the handler and acknowledges the envelope when it succeeds
+func (b *RedisBus) process(ctx context.Context, sub subscription, stream string, msg redis.XMessage) {
+	log := logger.Named("event").With("topic", sub.topic, "group", sub.group, "message_id", msg.ID)
+
+	var env Envelope
+	data, _ := msg.Values["envelope"].(string)
+	if err := json.Unmarshal([]byte(data), &env); err != nil {
+		// Nothing can ever handle it, drop it rather than redelivering it forever
+		log.Error("dropping malformed event", "error", err)
+		b.client.XAck(ctx, stream, sub.group, msg.ID)
+		return
+	}
+
+	if err := Chain(sub.handler, Recover())(HandlerContext(ctx, env), env); err != nil {
+		log.ErrorContext(ctx, "event handler failed", "event_id", env.ID, "type", env.Type, "error", err)
+		return
+	}
+	if err := b.client.XAck(ctx, stream, sub.group, msg.ID).Err(); err != nil {
+		log.ErrorContext(ctx, "failed to acknowledge event", "event_id", env.ID, "error", err)
+	}
+}
@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// PostgresChecker runs SELECT 1 on the pool and reports the pool usage as details
+// An exhausted pool is busy rather than down: the query is skipped, since it would wait for a connection until
+// the timeout, and the check passes with pool_exhausted set so a saturated instance is not restarted or drained
+func PostgresChecker(name string, pool utils.PGXPool) Checker {
+	checker := utils.NewDBHealthChecker(pool)
+	return NewDetailedChecker(name, func(ctx context.Context) (map[string]interface{}, error) {
+		if stater, ok := pool.(interface{ Stat() *pgxpool.Stat }); ok {
+			if stat := stater.Stat(); stat != nil && stat.MaxConns() > 0 && stat.AcquiredConns() >= stat.MaxConns() {
+				return map[string]interface{}{
+					"acquired_conns": stat.AcquiredConns(),
+					"max_conns":      stat.MaxConns(),
+					"pool_exhausted": true,
+				}, nil
+			}
+		}
+
+		status := checker.Check(ctx)
+		var details map[string]interface{}
+		if status.Pool != nil {
+			details = map[string]interface{}{
+				"acquired_conns": status.Pool.AcquiredConns,
+				"idle_conns":     status.Pool.IdleConns,
+				"max_conns":      status.Pool.MaxConns,
+			}
+		}
+		if !status.Healthy {
+			return details, fmt.Errorf("database unreachable: %s", status.Error)
+		}
+		return details, nil
+	})
+}
+
+// RedisChecker pings Redis
+func RedisChecker(name string, client redis.Cmdable) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		return utils.RedisHealthCheck(ctx, client)
+	})
+}
+
+// StorageChecker looks up a probe object, which succeeds whether or not it exists as long as the bucket is reachable
+func StorageChecker(name string, client utils.StorageClient) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		if _, _, err := client.Exists(ctx, ".healthcheck"); err != nil {
+			return fmt.Errorf("storage unreachable: %w", err)
+		}
+		return nil
+	})
+}
+
+// MigrationChecker fails while the schema is dirty or older than minVersion, e.g. the version the build expects
+// It reads schema_migrations with a plain query so probes hold no extra connection and respect the check timeout
+func MigrationChecker(name string, db *sql.DB, minVersion uint) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		var version int64
+		var dirty bool
+		err := db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+		// No row means no migration has been applied yet
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to read migration version: %w", err)
+		}
+		if dirty {
+			return fmt.Errorf("migration %d is dirty", version)
+		}
+		if version < int64(minVersion) {
+			return fmt.Errorf("schema version %d is older than %d", version, minVersion)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,37 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LivenessHandler answers 200 while the liveness checkers pass and 503 otherwise
+func (r *Registry) LivenessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeReport(c, r.Liveness(c.Request.Context()))
+	}
+}
+
+// ReadinessHandler answers 200 while the readiness checkers pass and 503 otherwise
+func (r *Registry) ReadinessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeReport(c, r.Readiness(c.Request.Context()))
+	}
+}
+
+// Mount registers /livez and /healthz (liveness) and /readyz (readiness) for Kubernetes probes
+func (r *Registry) Mount(router gin.IRouter) {
+	router.GET("/livez", r.LivenessHandler())
+	router.GET("/healthz", r.LivenessHandler())
+	router.GET("/readyz", r.ReadinessHandler())
+}
+
+func writeReport(c *gin.Context, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
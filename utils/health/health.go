@@ -0,0 +1,230 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Statuses of checks and reports
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Checker checks one dependency, returning an error when it is unavailable
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// checkerFunc adapts a function to Checker
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c checkerFunc) Name() string {
+	return c.name
+}
+
+func (c checkerFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// NewChecker creates a checker from a function
+func NewChecker(name string, fn func(ctx context.Context) error) Checker {
+	return checkerFunc{name: name, fn: fn}
+}
+
+// DetailedChecker is a Checker also reporting details of the dependency, e.g. the usage of a pool
+type DetailedChecker interface {
+	Checker
+	CheckDetails(ctx context.Context) (map[string]interface{}, error)
+}
+
+// detailedCheckerFunc adapts a function to DetailedChecker
+type detailedCheckerFunc struct {
+	name string
+	fn   func(ctx context.Context) (map[string]interface{}, error)
+}
+
+func (c detailedCheckerFunc) Name() string {
+	return c.name
+}
+
+func (c detailedCheckerFunc) Check(ctx context.Context) error {
+	_, err := c.fn(ctx)
+	return err
+}
+
+func (c detailedCheckerFunc) CheckDetails(ctx context.Context) (map[string]interface{}, error) {
+	return c.fn(ctx)
+}
+
+// NewDetailedChecker creates a checker reporting details from a function
+func NewDetailedChecker(name string, fn func(ctx context.Context) (map[string]interface{}, error)) DetailedChecker {
+	return detailedCheckerFunc{name: name, fn: fn}
+}
+
+// CheckResult is the outcome of one checker
+type CheckResult struct {
+	Status    string                 `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	LatencyMs int64                  `json:"latency_ms"`
+}
+
+// Report is the outcome of every checker, Status is down when any of them is down
+type Report struct {
+	Status    string                 `json:"status"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+	CheckedAt time.Time              `json:"checked_at"`
+}
+
+// Registry runs the liveness and readiness checkers of a service
+type Registry struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu        sync.Mutex
+	liveness  []Checker
+	readiness []Checker
+	cached    map[string]Report
+	draining  bool
+}
+
+// Option configures a Registry
+type Option func(*Registry)
+
+// WithTimeout bounds each checker (default 2s)
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *Registry) {
+		r.timeout = timeout
+	}
+}
+
+// WithCacheTTL reuses reports for ttl (default 1s) so frequent probes do not hammer the dependencies
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(r *Registry) {
+		r.cacheTTL = ttl
+	}
+}
+
+// NewRegistry creates a registry without checkers
+func NewRegistry(opts ...Option) *Registry {
+	r := &Registry{
+		timeout:  2 * time.Second,
+		cacheTTL: time.Second,
+		cached:   map[string]Report{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// AddLiveness adds checkers whose failure means the process must be restarted, keep them cheap and local
+func (r *Registry) AddLiveness(checkers ...Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.liveness = append(r.liveness, checkers...)
+}
+
+// AddReadiness adds checkers whose failure means the instance must not receive traffic, e.g. Postgres and Redis
+func (r *Registry) AddReadiness(checkers ...Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readiness = append(r.readiness, checkers...)
+}
+
+// Drain makes readiness fail so the load balancer stops routing to the instance before it shuts down
+func (r *Registry) Drain() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.draining = true
+}
+
+// Liveness runs the liveness checkers
+func (r *Registry) Liveness(ctx context.Context) Report {
+	r.mu.Lock()
+	checkers := r.liveness
+	r.mu.Unlock()
+	return r.run(ctx, "liveness", checkers)
+}
+
+// Readiness runs the readiness checkers, it is down while draining
+func (r *Registry) Readiness(ctx context.Context) Report {
+	r.mu.Lock()
+	checkers, draining := r.readiness, r.draining
+	r.mu.Unlock()
+
+	if draining {
+		return Report{Status: StatusDown, CheckedAt: time.Now()}
+	}
+	return r.run(ctx, "readiness", checkers)
+}
+
+// run runs checkers in parallel, reusing the cached report of kind while it is fresh
+// The checks only get the timeout and not the cancelation of ctx, so a probe that gives up early does not
+// cache a report failed by its own cancelation
+func (r *Registry) run(ctx context.Context, kind string, checkers []Checker) Report {
+	r.mu.Lock()
+	if report, ok := r.cached[kind]; ok && time.Since(report.CheckedAt) < r.cacheTTL {
+		r.mu.Unlock()
+		return report
+	}
+	r.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(checkers)), CheckedAt: time.Now()}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, checker := range checkers {
+		wg.Add(1)
+		go func(checker Checker) {
+			defer wg.Done()
+			result := r.check(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[checker.Name()] = result
+			if result.Status == StatusDown {
+				report.Status = StatusDown
+			}
+		}(checker)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	r.cached[kind] = report
+	r.mu.Unlock()
+	return report
+}
+
+// check runs one checker with the timeout, turning panics into failures
+func (r *Registry) check(ctx context.Context, checker Checker) (result CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = CheckResult{Status: StatusDown, Error: fmt.Sprintf("panic: %v", recovered)}
+		}
+		result.LatencyMs = time.Since(start).Milliseconds()
+	}()
+
+	var details map[string]interface{}
+	var err error
+	if detailed, ok := checker.(DetailedChecker); ok {
+		details, err = detailed.CheckDetails(ctx)
+	} else {
+		err = checker.Check(ctx)
+	}
+	if err != nil {
+		return CheckResult{Status: StatusDown, Error: err.Error(), Details: details}
+	}
+	return CheckResult{Status: StatusUp, Details: details}
+}
@@ -0,0 +1,244 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/breaker"
+	"github.com/gadhittana01/go-modules-v3/utils/otel"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Config configures a Client, zero values use the defaults
+type Config struct {
+	BaseURL   string        // prefixed to relative URLs of the JSON helpers
+	Timeout   time.Duration // per attempt, including reading the body, default 10s
+	UserAgent string
+
+	DialTimeout           time.Duration // default 5s
+	TLSHandshakeTimeout   time.Duration // default 5s
+	ResponseHeaderTimeout time.Duration // default 0, bounded by Timeout
+	MaxIdleConns          int           // default 100
+	MaxIdleConnsPerHost   int           // default 10, Go defaults to 2 which churns connections under load
+	MaxConnsPerHost       int           // default 0, unlimited
+	IdleConnTimeout       time.Duration // default 90s
+
+	// Retry applies to idempotent methods and requests with an Idempotency-Key header
+	// on network errors, 429, 502, 503 and 504. The zero value uses utils.DefaultRetryPolicy
+	Retry utils.RetryPolicy
+
+	// Breaker configures the circuit breaker of each host, named after the host. Network errors
+	// and 5xx responses count as failures
+	Breaker        breaker.Config
+	DisableBreaker bool
+}
+
+// withDefaults fills the unset fields of cfg
+func (cfg Config) withDefaults() Config {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	if cfg.TLSHandshakeTimeout <= 0 {
+		cfg.TLSHandshakeTimeout = 5 * time.Second
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = 100
+	}
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = 10
+	}
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = 90 * time.Second
+	}
+	if cfg.Retry.MaxAttempts == 0 {
+		cfg.Retry = utils.DefaultRetryPolicy()
+		cfg.Retry.AttemptTimeout = 0 // Timeout bounds each attempt
+	}
+	return cfg
+}
+
+// Client is an HTTP client for outbound API calls with pooled connections, retries, a circuit breaker
+// per host and request ID and trace context propagation
+type Client struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	breakers  map[string]*breaker.Breaker
+	collector *breaker.Collector
+}
+
+// New creates a client
+func New(cfg Config) *Client {
+	cfg = cfg.withDefaults()
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &Client{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: utils.NewRequestIDTransport(otel.NewTransport(transport)),
+			Timeout:   cfg.Timeout,
+		},
+		breakers:  map[string]*breaker.Breaker{},
+		collector: breaker.NewCollector(),
+	}
+}
+
+// HTTPClient returns the underlying client, without retries and circuit breaking
+func (c *Client) HTTPClient() *http.Client {
+	return c.client
+}
+
+// BreakerCollector exports the circuit breakers of the hosts called so far as Prometheus metrics
+func (c *Client) BreakerCollector() prometheus.Collector {
+	return c.collector
+}
+
+// Do sends req, retrying it when it is safe to and failing fast with breaker.ErrOpen while the host is down
+// Like http.Client.Do, non-2xx responses are not errors; the caller must close the body
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.cfg.UserAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.cfg.UserAgent)
+	}
+	hostBreaker := c.breaker(req.URL.Host)
+
+	attempts := c.cfg.Retry.MaxAttempts
+	if attempts < 1 || !retryableRequest(req) {
+		attempts = 1
+	}
+
+	for attempt := 0; ; attempt++ {
+		done := func(error) {}
+		if hostBreaker != nil {
+			var err error
+			if done, err = hostBreaker.Allow(); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Redacted(), err)
+			}
+		}
+
+		resp, err := c.attempt(req, attempt)
+		if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+			done(fmt.Errorf("unexpected status %d", resp.StatusCode))
+		} else {
+			done(err)
+		}
+
+		retry := attempt < attempts-1 && req.Context().Err() == nil &&
+			((err != nil && utils.IsRetryable(err)) || (err == nil && retryableStatus(resp.StatusCode)))
+		if !retry {
+			return resp, err
+		}
+
+		delay := c.cfg.Retry.Delay(attempt)
+		if resp != nil {
+			if after := retryAfter(resp); after > delay && (c.cfg.Retry.MaxBackoff <= 0 || after <= c.cfg.Retry.MaxBackoff) {
+				delay = after
+			}
+			// Drain so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends req, rewinding its body for retries
+func (c *Client) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return c.client.Do(req)
+}
+
+// breaker returns the circuit breaker of host, nil when disabled
+func (c *Client) breaker(host string) *breaker.Breaker {
+	if c.cfg.DisableBreaker {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		cfg := c.cfg.Breaker
+		cfg.Name = host
+		b = breaker.New(cfg)
+		c.breakers[host] = b
+		c.collector.Add(b)
+	}
+	return b
+}
+
+// retryableRequest reports whether req may be sent twice: idempotent methods or an Idempotency-Key,
+// and a body that can be replayed
+func retryableRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryableStatus reports whether a response status is transient
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay requested by the Retry-After header in seconds or as a date
+func retryAfter(resp *http.Response) time.Duration {
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
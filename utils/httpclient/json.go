@@ -0,0 +1,93 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody bounds how much of a failed response body is kept in a StatusError
+const maxErrorBody = 4 << 10
+
+// StatusError is returned by the JSON helpers for non-2xx responses
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       []byte // first 4 KiB of the response body
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d", e.Method, e.URL, e.StatusCode)
+}
+
+// Retryable marks 429 and 5xx responses as transient for utils.IsRetryable
+func (e *StatusError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// GetJSON sends a GET request and decodes the JSON response into T
+func GetJSON[T any](ctx context.Context, c *Client, url string) (T, error) {
+	return DoJSON[T](ctx, c, http.MethodGet, url, nil)
+}
+
+// PostJSON sends body encoded as JSON and decodes the JSON response into T
+// POST is not retried, use Client.Do with an Idempotency-Key header for retryable writes
+func PostJSON[T any](ctx context.Context, c *Client, url string, body interface{}) (T, error) {
+	return DoJSON[T](ctx, c, http.MethodPost, url, body)
+}
+
+// DoJSON sends body (nil for none) encoded as JSON and decodes the JSON response into T
+// Relative URLs are resolved against Config.BaseURL, 204 responses leave T at its zero value
+func DoJSON[T any](ctx context.Context, c *Client, method, url string, body interface{}) (T, error) {
+	var result T
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return result, fmt.Errorf("failed to encode request body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.resolve(url), reader)
+	if err != nil {
+		return result, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return result, &StatusError{Method: method, URL: req.URL.Redacted(), StatusCode: resp.StatusCode, Body: data}
+	}
+	if resp.StatusCode == http.StatusNoContent || method == http.MethodHead {
+		return result, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		return result, fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return result, nil
+}
+
+// resolve prefixes relative URLs with the base URL
+func (c *Client) resolve(url string) string {
+	if c.cfg.BaseURL == "" || strings.Contains(url, "://") {
+		return url
+	}
+	return strings.TrimSuffix(c.cfg.BaseURL, "/") + "/" + strings.TrimPrefix(url, "/")
+}
@@ -0,0 +1,190 @@
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/text/language"
+)
+
+// Bundle holds the translated messages of every language
+// Messages are looked up in the requested language, then its base language (pt-BR falls back to pt),
+// then the default language, and finally the key itself is returned
+type Bundle struct {
+	mu          sync.RWMutex
+	defaultLang language.Tag
+	messages    map[language.Tag]map[string]string
+	tags        []language.Tag
+	matcher     language.Matcher
+}
+
+// NewBundle creates an empty bundle falling back to defaultLang, e.g. "en"
+func NewBundle(defaultLang string) *Bundle {
+	tag := language.Make(defaultLang)
+	b := &Bundle{
+		defaultLang: tag,
+		messages:    map[language.Tag]map[string]string{tag: {}},
+	}
+	b.tags = []language.Tag{tag}
+	b.matcher = language.NewMatcher(b.tags)
+	return b
+}
+
+// AddMessages adds messages of a language, overriding existing keys
+func (b *Bundle) AddMessages(lang string, messages map[string]string) {
+	tag := language.Make(lang)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.messages[tag] == nil {
+		b.messages[tag] = map[string]string{}
+	}
+	for key, message := range messages {
+		b.messages[tag][key] = message
+	}
+
+	// The default language goes first so it wins when nothing matches
+	tags := []language.Tag{b.defaultLang}
+	for t := range b.messages {
+		if t != b.defaultLang {
+			tags = append(tags, t)
+		}
+	}
+	sort.Slice(tags[1:], func(i, j int) bool { return tags[i+1].String() < tags[j+1].String() })
+	b.tags = tags
+	b.matcher = language.NewMatcher(tags)
+}
+
+// LoadFS loads every <lang>.toml and <lang>.json file of dir, typically from an embed.FS
+// Nested tables and objects are flattened into dotted keys, e.g. errors.not_found
+func (b *Bundle) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read translations: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".toml" && ext != ".json") {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		var raw map[string]interface{}
+		if ext == ".toml" {
+			err = toml.Unmarshal(data, &raw)
+		} else {
+			err = json.Unmarshal(data, &raw)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+
+		messages := map[string]string{}
+		flatten("", raw, messages)
+		b.AddMessages(strings.TrimSuffix(entry.Name(), ext), messages)
+	}
+	return nil
+}
+
+// flatten copies nested maps into dotted keys
+func flatten(prefix string, raw map[string]interface{}, messages map[string]string) {
+	for key, value := range raw {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(key, nested, messages)
+			continue
+		}
+		messages[key] = fmt.Sprint(value)
+	}
+}
+
+// Match returns the best supported language for an Accept-Language header value, the default one when none matches
+func (b *Bundle) Match(acceptLanguage string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, index, _ := b.matcher.Match(tags...)
+	return b.tags[index].String()
+}
+
+// Languages returns the languages of the bundle, the default one first
+func (b *Bundle) Languages() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	languages := make([]string, len(b.tags))
+	for i, tag := range b.tags {
+		languages[i] = tag.String()
+	}
+	return languages
+}
+
+// Translate returns the message of key in lang, formatted with args when given
+func (b *Bundle) Translate(lang, key string, args ...interface{}) string {
+	tag := language.Make(lang)
+	candidates := []language.Tag{tag}
+	if base, confidence := tag.Base(); confidence != language.No {
+		candidates = append(candidates, language.Make(base.String()))
+	}
+	candidates = append(candidates, b.defaultLang)
+
+	message := key
+	b.mu.RLock()
+	for _, candidate := range candidates {
+		if translated, ok := b.messages[candidate][key]; ok {
+			message = translated
+			break
+		}
+	}
+	b.mu.RUnlock()
+
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+type localeContextKey struct{}
+
+// ContextWithLocale returns a copy of ctx carrying the language negotiated for the request
+func ContextWithLocale(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, localeContextKey{}, lang)
+}
+
+// LocaleFromContext returns the language carried by ctx, empty if there is none
+func LocaleFromContext(ctx context.Context) string {
+	lang, _ := ctx.Value(localeContextKey{}).(string)
+	return lang
+}
+
+var defaultBundle = NewBundle("en")
+
+// SetDefaultBundle sets the bundle used by T
+func SetDefaultBundle(b *Bundle) {
+	defaultBundle = b
+}
+
+// DefaultBundle returns the bundle used by T
+func DefaultBundle() *Bundle {
+	return defaultBundle
+}
+
+// T translates key into the language of ctx with the default bundle, keys without translation are returned as is
+func T(ctx context.Context, key string, args ...interface{}) string {
+	return defaultBundle.Translate(LocaleFromContext(ctx), key, args...)
+}
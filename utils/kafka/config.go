@@ -0,0 +1,116 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Config is the Kafka client configuration shared by producers and consumers
+type Config struct {
+	Brokers  []string
+	ClientID string
+
+	SASLMechanism string // PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512, empty disables SASL
+	Username      string
+	Password      string
+	TLS           bool
+
+	// Acks is "all" (default, with idempotent writes so retries never duplicate or reorder records),
+	// "leader" or "none"
+	Acks        string
+	Compression string        // snappy (default), lz4, zstd, gzip or none
+	Linger      time.Duration // how long the producer waits to fill a batch, default 0
+}
+
+// ConfigFromEnv reads the KAFKA_* variables
+func ConfigFromEnv() Config {
+	return Config{
+		Brokers:       utils.GetEnvStringSlice("KAFKA_BROKERS", []string{"localhost:9092"}),
+		ClientID:      utils.GetEnv("KAFKA_CLIENT_ID", utils.GetEnv("SERVICE_NAME", "")),
+		SASLMechanism: utils.GetEnv("KAFKA_SASL_MECHANISM", ""),
+		Username:      utils.GetEnv("KAFKA_USERNAME", ""),
+		Password:      utils.GetEnv("KAFKA_PASSWORD", ""),
+		TLS:           utils.GetEnvBool("KAFKA_TLS", false),
+		Acks:          utils.GetEnv("KAFKA_ACKS", "all"),
+		Compression:   utils.GetEnv("KAFKA_COMPRESSION", "snappy"),
+		Linger:        utils.GetEnvDuration("KAFKA_LINGER", 0),
+	}
+}
+
+// options converts the configuration to franz-go options
+func (cfg Config) options() ([]kgo.Opt, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka brokers are required")
+	}
+	opts := []kgo.Opt{kgo.SeedBrokers(cfg.Brokers...)}
+	if cfg.ClientID != "" {
+		opts = append(opts, kgo.ClientID(cfg.ClientID))
+	}
+	if cfg.TLS {
+		opts = append(opts, kgo.DialTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}))
+	}
+
+	switch strings.ToUpper(cfg.SASLMechanism) {
+	case "":
+	case "PLAIN":
+		opts = append(opts, kgo.SASL(plain.Auth{User: cfg.Username, Pass: cfg.Password}.AsMechanism()))
+	case "SCRAM-SHA-256":
+		opts = append(opts, kgo.SASL(scram.Auth{User: cfg.Username, Pass: cfg.Password}.AsSha256Mechanism()))
+	case "SCRAM-SHA-512":
+		opts = append(opts, kgo.SASL(scram.Auth{User: cfg.Username, Pass: cfg.Password}.AsSha512Mechanism()))
+	default:
+		return nil, fmt.Errorf("unsupported kafka SASL mechanism %q", cfg.SASLMechanism)
+	}
+	return opts, nil
+}
+
+// producerOptions converts the producer settings to franz-go options
+func (cfg Config) producerOptions() ([]kgo.Opt, error) {
+	var opts []kgo.Opt
+	switch strings.ToLower(cfg.Acks) {
+	case "", "all":
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case "leader":
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	case "none":
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite())
+	default:
+		return nil, fmt.Errorf("unsupported kafka acks %q", cfg.Acks)
+	}
+
+	switch strings.ToLower(cfg.Compression) {
+	case "", "snappy":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.SnappyCompression()))
+	case "lz4":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.Lz4Compression()))
+	case "zstd":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.ZstdCompression()))
+	case "gzip":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.GzipCompression()))
+	case "none":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.NoCompression()))
+	default:
+		return nil, fmt.Errorf("unsupported kafka compression %q", cfg.Compression)
+	}
+
+	if cfg.Linger > 0 {
+		opts = append(opts, kgo.ProducerLinger(cfg.Linger))
+	}
+	return opts, nil
+}
+
+// Ping checks that a broker is reachable through client, e.g. in a health.Checker
+func Ping(ctx context.Context, client *kgo.Client) error {
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("kafka unreachable: %w", err)
+	}
+	return nil
+}
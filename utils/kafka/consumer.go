@@ -0,0 +1,258 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/event"
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Message is a record delivered to a Handler
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Timestamp time.Time
+}
+
+// Envelope decodes the value as an event bus envelope
+func (m *Message) Envelope() (event.Envelope, error) {
+	var env event.Envelope
+	if err := json.Unmarshal(m.Value, &env); err != nil {
+		return env, fmt.Errorf("failed to decode event envelope: %w", err)
+	}
+	if env.Topic == "" {
+		env.Topic = m.Topic
+	}
+	return env, nil
+}
+
+// Handler processes a message, returning an error retries it with backoff before moving past it
+type Handler func(ctx context.Context, msg *Message) error
+
+// EnvelopeHandler adapts an event bus handler, so event.Retry and event.DeadLetter can bound the attempts
+// Values that are not envelopes are logged and skipped
+func EnvelopeHandler(handler event.Handler) Handler {
+	return func(ctx context.Context, msg *Message) error {
+		env, err := msg.Envelope()
+		if err != nil {
+			logger.Named("kafka").ErrorContext(ctx, "skipping malformed event",
+				"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "error", err)
+			return nil
+		}
+		return handler(event.HandlerContext(ctx, env), env)
+	}
+}
+
+// ConsumerOption configures a Consumer
+type ConsumerOption func(*Consumer)
+
+// WithStartFromBeginning makes a new group read the topics from the earliest offset instead of the latest
+func WithStartFromBeginning() ConsumerOption {
+	return func(c *Consumer) {
+		c.fromBeginning = true
+	}
+}
+
+// WithRetryPolicy sets the attempts and backoff of a failing message (default 5 attempts from 1s up to 1m)
+// Rebalances wait for the retries, so the delays must stay well below the rebalance timeout of the group
+func WithRetryPolicy(policy retry.Policy) ConsumerOption {
+	return func(c *Consumer) {
+		c.policy = policy
+	}
+}
+
+// WithDeadLetterTopic produces the records that exhausted their attempts or failed permanently to topic,
+// otherwise they are logged and skipped
+func WithDeadLetterTopic(topic string) ConsumerOption {
+	return func(c *Consumer) {
+		c.deadLetterTopic = topic
+	}
+}
+
+// Consumer runs a handler for the records of topics as a member of a consumer group
+// Records of a partition are handled in order and their offsets committed only after the handler succeeded
+// or the record was dead-lettered, so delivery is at-least-once. Rebalances wait for the records being handled, and offsets are committed
+// before partitions are revoked, so a partition moving to another member is not processed twice
+type Consumer struct {
+	cfg     Config
+	group   string
+	topics  []string
+	handler Handler
+
+	fromBeginning   bool
+	policy          retry.Policy
+	deadLetterTopic string
+	client          *kgo.Client
+}
+
+// NewConsumer creates a consumer of topics in group
+func NewConsumer(cfg Config, group string, topics []string, handler Handler, opts ...ConsumerOption) (*Consumer, error) {
+	c := &Consumer{
+		cfg:     cfg,
+		group:   group,
+		topics:  topics,
+		handler: handler,
+		policy:  retry.Policy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: time.Minute, Multiplier: 2, Jitter: 0.2},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	clientOpts, err := cfg.options()
+	if err != nil {
+		return nil, err
+	}
+	reset := kgo.NewOffset().AtEnd()
+	if c.fromBeginning {
+		reset = kgo.NewOffset().AtStart()
+	}
+	clientOpts = append(clientOpts,
+		kgo.ConsumerGroup(group),
+		kgo.ConsumeTopics(topics...),
+		kgo.ConsumeResetOffset(reset),
+		kgo.AutoCommitMarks(),
+		kgo.BlockRebalanceOnPoll(),
+		kgo.OnPartitionsRevoked(c.onRevoked),
+	)
+
+	c.client, err = kgo.NewClient(clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
+	}
+	return c, nil
+}
+
+// Name implements worker.Worker
+func (c *Consumer) Name() string {
+	return "kafka-consumer-" + c.group
+}
+
+// Client returns the underlying client
+func (c *Consumer) Client() *kgo.Client {
+	return c.client
+}
+
+// Run consumes until ctx is canceled, then commits the handled offsets and leaves the group
+// The client is closed when Run returns, a Consumer cannot be run twice
+func (c *Consumer) Run(ctx context.Context) error {
+	log := logger.Named("kafka").With("group", c.group)
+	defer func() {
+		commitCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := c.client.CommitMarkedOffsets(commitCtx); err != nil {
+			log.Error("failed to commit offsets on shutdown", "error", err)
+		}
+		c.client.Close()
+	}()
+
+	for {
+		fetches := c.client.PollFetches(ctx)
+		if ctx.Err() != nil || fetches.IsClientClosed() {
+			return nil
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			if !errors.Is(err, context.Canceled) {
+				log.Error("failed to fetch", "topic", topic, "partition", partition, "error", err)
+			}
+		})
+
+		fetches.EachRecord(func(record *kgo.Record) {
+			if ctx.Err() != nil {
+				return
+			}
+			if c.handle(ctx, record) {
+				c.client.MarkCommitRecords(record)
+			}
+		})
+		c.client.AllowRebalance()
+	}
+}
+
+// handle runs the handler until it succeeds or exhausts its attempts, then dead-letters or skips the record
+// It returns false when ctx is canceled before the record was handled
+func (c *Consumer) handle(ctx context.Context, record *kgo.Record) bool {
+	msg := &Message{
+		Topic:     record.Topic,
+		Partition: record.Partition,
+		Offset:    record.Offset,
+		Key:       record.Key,
+		Value:     record.Value,
+		Headers:   make(map[string]string, len(record.Headers)),
+		Timestamp: record.Timestamp,
+	}
+	for _, header := range record.Headers {
+		msg.Headers[header.Key] = string(header.Value)
+	}
+
+	log := logger.Named("kafka")
+	attempts := c.policy.Attempts()
+	for attempt := 0; ; attempt++ {
+		err := c.handler(ctx, msg)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+
+		if retry.IsPermanent(err) || attempt+1 >= attempts {
+			if c.deadLetterTopic == "" {
+				log.ErrorContext(ctx, "message handler failed, skipping",
+					"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "attempt", attempt+1, "error", err)
+				return true
+			}
+			log.ErrorContext(ctx, "message handler failed, dead-lettering",
+				"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "attempt", attempt+1, "error", err)
+			if err := c.deadLetter(ctx, record, err); err != nil {
+				log.ErrorContext(ctx, "failed to dead-letter message, skipping",
+					"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "error", err)
+			}
+			return ctx.Err() == nil
+		}
+
+		delay := c.policy.Delay(attempt)
+		log.ErrorContext(ctx, "message handler failed, retrying",
+			"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// deadLetter produces a copy of record to the dead-letter topic with the error and its origin as headers
+func (c *Consumer) deadLetter(ctx context.Context, record *kgo.Record, cause error) error {
+	headers := append(make([]kgo.RecordHeader, 0, len(record.Headers)+4), record.Headers...)
+	headers = append(headers,
+		kgo.RecordHeader{Key: "dead_letter_error", Value: []byte(cause.Error())},
+		kgo.RecordHeader{Key: "dead_letter_topic", Value: []byte(record.Topic)},
+		kgo.RecordHeader{Key: "dead_letter_partition", Value: []byte(strconv.Itoa(int(record.Partition)))},
+		kgo.RecordHeader{Key: "dead_letter_offset", Value: []byte(strconv.FormatInt(record.Offset, 10))},
+	)
+	dead := &kgo.Record{Topic: c.deadLetterTopic, Key: record.Key, Value: record.Value, Headers: headers}
+	if err := c.client.ProduceSync(ctx, dead).FirstErr(); err != nil {
+		return fmt.Errorf("failed to produce to dead-letter topic: %w", err)
+	}
+	return nil
+}
+
+// onRevoked commits the handled offsets before the partitions move to another member
+func (c *Consumer) onRevoked(ctx context.Context, client *kgo.Client, _ map[string][]int32) {
+	if err := client.CommitMarkedOffsets(ctx); err != nil {
+		logger.Named("kafka").Error("failed to commit offsets on rebalance", "group", c.group, "error", err)
+	}
+}
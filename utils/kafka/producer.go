@@ -0,0 +1,84 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gadhittana01/go-modules-v3/utils/event"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Envelope headers set on records published with Publish, consumers can filter on them without decoding
+const (
+	HeaderEventID   = "event_id"
+	HeaderEventType = "event_type"
+)
+
+// Producer writes records, records with a key always land on the same partition of a topic
+type Producer struct {
+	client *kgo.Client
+}
+
+// NewProducer creates a producer
+func NewProducer(cfg Config) (*Producer, error) {
+	opts, err := cfg.options()
+	if err != nil {
+		return nil, err
+	}
+	producerOpts, err := cfg.producerOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kgo.NewClient(append(opts, producerOpts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+	return &Producer{client: client}, nil
+}
+
+// Client returns the underlying client
+func (p *Producer) Client() *kgo.Client {
+	return p.client
+}
+
+// Produce writes a record and waits until the brokers acknowledged it according to Config.Acks
+func (p *Producer) Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	record := &kgo.Record{Topic: topic, Key: key, Value: value}
+	for name, value := range headers {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: name, Value: []byte(value)})
+	}
+
+	if err := p.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		return fmt.Errorf("failed to produce to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Publish writes an event bus envelope as JSON, keyed by env.Key
+// Producer implements event.Publisher, e.g. to publish with event.Publish or dead-letter with event.DeadLetter
+func (p *Producer) Publish(ctx context.Context, topic string, env event.Envelope) error {
+	env = event.Prepare(ctx, topic, env)
+	value, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", env.ID, err)
+	}
+
+	headers := map[string]string{HeaderEventID: env.ID, HeaderEventType: env.Type}
+	var key []byte
+	if env.Key != "" {
+		key = []byte(env.Key)
+	}
+	return p.Produce(ctx, topic, key, value, headers)
+}
+
+// Close flushes the buffered records within ctx and closes the client
+func (p *Producer) Close(ctx context.Context) error {
+	err := p.client.Flush(ctx)
+	p.client.Close()
+	if err != nil {
+		return fmt.Errorf("failed to flush kafka producer: %w", err)
+	}
+	return nil
+}
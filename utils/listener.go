@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Notification is a Postgres NOTIFY message
+type Notification struct {
+	Channel string
+	Payload string
+	PID     uint32
+}
+
+// NotificationHandler handles notifications delivered by a PGListener
+type NotificationHandler func(ctx context.Context, n Notification)
+
+// PGListener maintains a dedicated connection that LISTENs on channels and dispatches notifications
+// The connection is re-established with backoff when lost and all channels are listened again
+type PGListener struct {
+	databaseURL string
+	policy      RetryPolicy
+
+	mu       sync.RWMutex
+	handlers map[string][]NotificationHandler
+	wake     context.CancelFunc // interrupts the current wait so new channels get listened
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPGListener creates a new listener, the policy controls reconnect backoff (MaxAttempts is ignored)
+func NewPGListener(databaseURL string, policy RetryPolicy) *PGListener {
+	return &PGListener{
+		databaseURL: databaseURL,
+		policy:      policy,
+		handlers:    map[string][]NotificationHandler{},
+	}
+}
+
+// Subscribe registers a handler for a channel, it can be called before or after Start
+func (l *PGListener) Subscribe(channel string, handler NotificationHandler) {
+	l.mu.Lock()
+	l.handlers[channel] = append(l.handlers[channel], handler)
+	wake := l.wake
+	l.mu.Unlock()
+
+	if wake != nil {
+		wake()
+	}
+}
+
+// SubscribeChan registers a channel subscription delivering notifications to a Go channel
+// Delivery blocks while the buffer is full, so consumers must keep up
+func (l *PGListener) SubscribeChan(channel string, buffer int) <-chan Notification {
+	ch := make(chan Notification, buffer)
+	l.Subscribe(channel, func(ctx context.Context, n Notification) {
+		select {
+		case ch <- n:
+		case <-ctx.Done():
+		}
+	})
+	return ch
+}
+
+// Start connects and dispatches notifications in the background until Stop is called or ctx is done
+func (l *PGListener) Start(ctx context.Context) {
+	ctx, l.cancel = context.WithCancel(ctx)
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.run(ctx)
+	}()
+}
+
+// Stop stops the listener and closes its connection
+func (l *PGListener) Stop() {
+	if l.cancel != nil {
+		l.cancel()
+	}
+	l.wg.Wait()
+}
+
+// run keeps a connection alive and reconnects with backoff whenever it fails
+func (l *PGListener) run(ctx context.Context) {
+	attempt := 0
+	for ctx.Err() == nil {
+		err := l.listen(ctx, func() { attempt = 0 })
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("Postgres listener disconnected (attempt %d): %v", attempt+1, err)
+		if !sleepContext(ctx, l.policy.Delay(attempt)) {
+			return
+		}
+		attempt++
+	}
+}
+
+// listen runs a single connection until it fails, connected is called once LISTEN succeeded
+func (l *PGListener) listen(ctx context.Context, connected func()) error {
+	conn, err := pgx.Connect(ctx, l.databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	listening := map[string]bool{}
+	for {
+		// Install the wake function before listening so subscriptions added meanwhile interrupt the wait
+		waitCtx, wake := context.WithCancel(ctx)
+		l.mu.Lock()
+		l.wake = wake
+		l.mu.Unlock()
+
+		if err := l.listenChannels(ctx, conn, listening); err != nil {
+			wake()
+			return err
+		}
+		connected()
+
+		notification, err := conn.WaitForNotification(waitCtx)
+		wake()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Woken up by a new subscription, keep the connection unless pgx closed it
+			if errors.Is(err, context.Canceled) && !conn.IsClosed() {
+				continue
+			}
+			return err
+		}
+
+		l.dispatch(ctx, Notification{
+			Channel: notification.Channel,
+			Payload: notification.Payload,
+			PID:     notification.PID,
+		})
+	}
+}
+
+// listenChannels issues LISTEN for every subscribed channel not yet listened on this connection
+func (l *PGListener) listenChannels(ctx context.Context, conn *pgx.Conn, listening map[string]bool) error {
+	l.mu.RLock()
+	var pending []string
+	for channel := range l.handlers {
+		if !listening[channel] {
+			pending = append(pending, channel)
+		}
+	}
+	l.mu.RUnlock()
+
+	for _, channel := range pending {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", channel, err)
+		}
+		listening[channel] = true
+	}
+	return nil
+}
+
+// dispatch calls the handlers of the notification channel
+func (l *PGListener) dispatch(ctx context.Context, n Notification) {
+	l.mu.RLock()
+	handlers := l.handlers[n.Channel]
+	l.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, n)
+	}
+}
+
+// execer is implemented by pools, connections and transactions
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Notify sends a notification on a channel through any pool or transaction
+// Inside a transaction the notification is only delivered after commit
+func Notify(ctx context.Context, db execer, channel, payload string) error {
+	if _, err := db.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload); err != nil {
+		return fmt.Errorf("failed to notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done, returning false if ctx is done
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,179 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Formats supported by New
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Config configures the loggers created by New
+type Config struct {
+	Level     string    // debug, info (default), warn or error
+	Format    string    // FormatJSON (default) or FormatConsole
+	AddSource bool      // add the file and line of the call
+	Output    io.Writer // default os.Stderr
+
+	RedactKeys       []string               // attribute names redacted on top of DefaultRedactKeys
+	DisableRedaction bool                   // log values as is, e.g. for local debugging
+	SampleRates      map[slog.Level]float64 // fraction of the records of a level that are logged, see NewSamplingHandler
+}
+
+// ConfigFromEnv reads LOG_LEVEL, LOG_FORMAT, LOG_ADD_SOURCE, LOG_REDACT_KEYS (comma separated),
+// LOG_SAMPLE_DEBUG and LOG_SAMPLE_INFO
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Level:     os.Getenv("LOG_LEVEL"),
+		Format:    os.Getenv("LOG_FORMAT"),
+		AddSource: os.Getenv("LOG_ADD_SOURCE") == "true",
+	}
+	if keys := os.Getenv("LOG_REDACT_KEYS"); keys != "" {
+		cfg.RedactKeys = strings.Split(keys, ",")
+	}
+	cfg.SampleRates = SampleRatesFromEnv()
+	return cfg
+}
+
+// SampleRatesFromEnv reads the LOG_SAMPLE_DEBUG and LOG_SAMPLE_INFO fractions, e.g. LOG_SAMPLE_DEBUG=0.05
+func SampleRatesFromEnv() map[slog.Level]float64 {
+	rates := map[slog.Level]float64{}
+	for key, level := range map[string]slog.Level{"LOG_SAMPLE_DEBUG": slog.LevelDebug, "LOG_SAMPLE_INFO": slog.LevelInfo} {
+		if rate, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+			rates[level] = rate
+		}
+	}
+	return rates
+}
+
+// ParseLevel parses a level name, unknown names are info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// New creates a logger writing records with the fields of their context (see AddContextExtractor),
+// redacting secrets (see NewRedactor) and sampling levels as configured
+func New(cfg Config) *slog.Logger {
+	if cfg.Output == nil {
+		cfg.Output = os.Stderr
+	}
+	opts := &slog.HandlerOptions{Level: ParseLevel(cfg.Level), AddSource: cfg.AddSource}
+	if !cfg.DisableRedaction {
+		opts.ReplaceAttr = NewRedactor(cfg.RedactKeys...)
+	}
+
+	var handler slog.Handler
+	if cfg.Format == FormatConsole {
+		handler = slog.NewTextHandler(cfg.Output, opts)
+	} else {
+		handler = slog.NewJSONHandler(cfg.Output, opts)
+	}
+	return slog.New(NewContextHandler(NewSamplingHandler(handler, cfg.SampleRates)))
+}
+
+// ContextExtractor returns the fields carried by a context, e.g. the request ID
+type ContextExtractor func(ctx context.Context) []slog.Attr
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   []ContextExtractor
+)
+
+// AddContextExtractor registers fields added to every record logged with a context
+// utils registers the request ID, user ID and tenant of the request
+func AddContextExtractor(extractor ContextExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors = append(extractors, extractor)
+}
+
+// contextAttrs returns the fields of ctx from every registered extractor
+func contextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	var attrs []slog.Attr
+	for _, extractor := range extractors {
+		attrs = append(attrs, extractor(ctx)...)
+	}
+	return attrs
+}
+
+// contextHandler adds the fields of the record context
+type contextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler wraps h so records logged with a context carry its fields
+func NewContextHandler(h slog.Handler) slog.Handler {
+	return contextHandler{Handler: h}
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(contextAttrs(ctx)...)
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// defaultLogger is read by every logging call and replaced by SetDefault, possibly from another goroutine
+var defaultLogger atomic.Pointer[slog.Logger]
+
+func init() {
+	defaultLogger.Store(New(Config{}))
+}
+
+// SetDefault sets the logger returned by Default and installs it as the slog and log default
+func SetDefault(l *slog.Logger) {
+	defaultLogger.Store(l)
+	slog.SetDefault(l)
+}
+
+// Default returns the default logger
+func Default() *slog.Logger {
+	return defaultLogger.Load()
+}
+
+// With returns the default logger with the fields of ctx, for code logging without the context
+func With(ctx context.Context) *slog.Logger {
+	attrs := contextAttrs(ctx)
+	if len(attrs) == 0 {
+		return Default()
+	}
+	args := make([]interface{}, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	return Default().With(args...)
+}
+
+// Named returns a child of the default logger tagged with component, e.g. Named("outbox")
+func Named(component string) *slog.Logger {
+	return Default().With(slog.String("component", component))
+}
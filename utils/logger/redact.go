@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces redacted values
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactKeys are name fragments of attributes whose value is always redacted
+var DefaultRedactKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "cookie",
+	"apikey", "privatekey", "credential", "cardnumber", "cvv",
+}
+
+var (
+	// bearerPattern matches the credentials of Authorization header values
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`)
+	// jwtPattern matches JSON web tokens
+	jwtPattern = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// cardPattern matches 13 to 19 digits, optionally grouped with spaces or dashes
+	cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// NewRedactor returns a slog ReplaceAttr function redacting the values of attributes named like a secret
+// (DefaultRedactKeys plus extraKeys) and masking bearer tokens, JWTs and card numbers inside any string,
+// including the messages of errors and fmt.Stringer values, which are logged as strings once masked
+func NewRedactor(extraKeys ...string) func(groups []string, a slog.Attr) slog.Attr {
+	keys := append([]string{}, DefaultRedactKeys...)
+	for _, key := range extraKeys {
+		keys = append(keys, normalizeKey(key))
+	}
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if a.Value.Kind() == slog.KindGroup {
+			return a
+		}
+		name := normalizeKey(a.Key)
+		for _, key := range keys {
+			if strings.Contains(name, key) {
+				return slog.String(a.Key, RedactedValue)
+			}
+		}
+		switch a.Value.Kind() {
+		case slog.KindString:
+			return slog.String(a.Key, RedactString(a.Value.String()))
+		case slog.KindAny:
+			var text string
+			switch v := a.Value.Any().(type) {
+			case error:
+				text = v.Error()
+			case fmt.Stringer:
+				text = v.String()
+			default:
+				return a
+			}
+			// Values without anything to mask keep their kind, so handlers still render them natively
+			if redacted := RedactString(text); redacted != text {
+				return slog.String(a.Key, redacted)
+			}
+		}
+		return a
+	}
+}
+
+// RedactString masks bearer and basic credentials, JWTs and card numbers (keeping the last 4 digits) in s
+func RedactString(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "$1 "+RedactedValue)
+	s = jwtPattern.ReplaceAllString(s, RedactedValue)
+	return cardPattern.ReplaceAllStringFunc(s, func(match string) string {
+		digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+		if !luhnValid(digits) {
+			return match
+		}
+		return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	})
+}
+
+// normalizeKey lowercases key and drops separators so api_key, apiKey and API-Key compare equal
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", ".", "").Replace(key))
+}
+
+// luhnValid reports whether digits pass the Luhn checksum of card numbers, avoiding masking IDs and timestamps
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+)
+
+// samplingHandler drops a fraction of the records of the sampled levels
+type samplingHandler struct {
+	slog.Handler
+	rates map[slog.Level]float64
+}
+
+// NewSamplingHandler wraps h so only the given fraction of the records of each level is logged,
+// e.g. {slog.LevelDebug: 0.01} keeps 1% of hot path debug logs. Levels without a rate are all logged
+func NewSamplingHandler(h slog.Handler, rates map[slog.Level]float64) slog.Handler {
+	if len(rates) == 0 {
+		return h
+	}
+	return samplingHandler{Handler: h, rates: rates}
+}
+
+func (h samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if rate, ok := h.rates[record.Level]; ok && rate < 1 && rand.Float64() >= rate {
+		return nil
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return samplingHandler{Handler: h.Handler.WithAttrs(attrs), rates: h.rates}
+}
+
+func (h samplingHandler) WithGroup(name string) slog.Handler {
+	return samplingHandler{Handler: h.Handler.WithGroup(name), rates: h.rates}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+)
+
+// Records logged with a request context carry its request ID, user and tenant
+func init() {
+	logger.AddContextExtractor(func(ctx context.Context) []slog.Attr {
+		var attrs []slog.Attr
+		if requestID := RequestIDFromContext(ctx); requestID != "" {
+			attrs = append(attrs, slog.String("request_id", requestID))
+		}
+		if claims, ok := ClaimsFromContext(ctx); ok {
+			attrs = append(attrs, slog.String("user_id", claims.UserID))
+		}
+		if tenant, ok := TenantFromContext(ctx); ok {
+			attrs = append(attrs, slog.String("tenant_id", tenant))
+		}
+		return attrs
+	})
+}
@@ -1,18 +1,151 @@
 package utils
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/lib/pq"
 )
 
-// RunMigrationPool runs database migrations using sql.DB
+// MigrationStatus reports the current migration state of a database
+type MigrationStatus struct {
+	Version    uint // currently applied version, 0 when no migration has been applied
+	Dirty      bool // a migration failed halfway and the database needs to be repaired with ForceMigrationVersion
+	HasVersion bool // false when no migration has ever been applied
+}
+
+// RunMigrationPool runs database migrations using sql.DB, config.Dialect selects Postgres or MySQL
+// With config.AdvisoryLock, replicas starting simultaneously wait for each other instead of racing
 func RunMigrationPool(db *sql.DB, config *BaseConfig) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	run := func() error {
+		if err := ensurePgcrypto(db, config); err != nil {
+			return err
+		}
+
+		m, release, err := newMigrate(db, config)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		err = m.Up()
+		if err != nil && err != migrate.ErrNoChange {
+			return err
+		}
+
+		return nil
+	}
+
+	if !config.AdvisoryLock {
+		return run()
+	}
+	return withMigrationLock(db, config, run)
+}
+
+// withMigrationLock runs fn while holding a Postgres advisory lock derived from the database name
+// It polls for the lock until config.LockTimeout (default 15s) elapses
+func withMigrationLock(db *sql.DB, config *BaseConfig, fn func() error) error {
+	timeout := config.LockTimeout
+	if timeout <= 0 {
+		timeout = migrate.DefaultLockTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Advisory locks are held by a session, so the lock and unlock must use the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection for migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	if migrationDialect(config) == DialectMySQL {
+		return withMySQLMigrationLock(ctx, conn, config, timeout, fn)
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte("migrations:" + config.DBName))
+	key := int64(hash.Sum64())
+
+	for {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if locked {
+			break
+		}
+
+		logger.Default().InfoContext(ctx, "waiting for migration lock held by another instance")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s waiting for migration lock", timeout)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+	return fn()
+}
+
+// withMySQLMigrationLock runs fn while holding a MySQL named lock, GET_LOCK waits up to timeout by itself
+func withMySQLMigrationLock(ctx context.Context, conn *sql.Conn, config *BaseConfig, timeout time.Duration, fn func() error) error {
+	name := "migrations:" + config.DBName
+
+	var locked sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, int(timeout.Seconds())).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("timed out after %s waiting for migration lock", timeout)
+	}
+
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+	return fn()
+}
+
+// RunMigrationFS runs migrations from a filesystem such as an embed.FS, so migrations can be shipped inside the binary:
+//
+//	//go:embed db/migration/*.sql
+//	var migrations embed.FS
+//
+//	err := utils.RunMigrationFS(databaseURL, "schema_name", migrations, "db/migration")
+//
+// When schema is not empty it is created if missing and used as search_path and migrations table location
+func RunMigrationFS(databaseURL, schema string, fsys fs.FS, dir string) error {
+	source, err := iofs.New(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to open migration source: %w", err)
+	}
+
+	db, err := connectSchema(databaseURL, schema)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{SchemaName: schema})
 	if err != nil {
 		return err
 	}
@@ -22,15 +155,375 @@ func RunMigrationPool(db *sql.DB, config *BaseConfig) error {
 		return err
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(config.MigrationURL, config.DBName, driver)
+	m, err := migrate.NewWithInstance("iofs", source, schema, driver)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return nil
+}
+
+// SchemaMigrationProgress reports the outcome of migrating one schema in RunMigrationsForSchemas
+type SchemaMigrationProgress struct {
+	Schema  string
+	Index   int   // zero based position of the schema
+	Total   int   // number of schemas to migrate
+	Version uint  // version of the schema after migrating
+	Err     error // nil when the schema was migrated successfully
+}
+
+// RunMigrationsForSchemas applies the migrations at path (e.g. "file://db/migration") to every schema,
+// creating missing schemas. Each schema tracks its own version in its own schema_migrations table, which
+// suits schema-per-tenant databases. progress, when not nil, is called after each schema.
+// It stops at the first failing schema
+func RunMigrationsForSchemas(databaseURL string, schemas []string, path string, progress func(SchemaMigrationProgress)) error {
+	for i, schema := range schemas {
+		version, err := migrateSchema(databaseURL, schema, path)
+		if progress != nil {
+			progress(SchemaMigrationProgress{
+				Schema:  schema,
+				Index:   i,
+				Total:   len(schemas),
+				Version: version,
+				Err:     err,
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate schema %s: %w", schema, err)
+		}
+	}
+	return nil
+}
+
+// migrateSchema runs the migrations at path in a single schema and returns the resulting version
+func migrateSchema(databaseURL, schema, path string) (uint, error) {
+	db, err := connectSchema(databaseURL, schema)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{SchemaName: schema})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = db.Exec("CREATE EXTENSION IF NOT EXISTS pgcrypto")
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return 0, err
+	}
+
+	m, err := migrate.NewWithDatabaseInstance(path, schema, driver)
+	if err != nil {
+		return 0, err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return 0, err
+	}
+
+	version, _, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return version, nil
+}
+
+// connectSchema connects with search_path set to schema, creating the schema if needed
+func connectSchema(databaseURL, schema string) (*sql.DB, error) {
+	if schema == "" {
+		return ConnectDB(databaseURL)
+	}
+
+	db, err := ConnectDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pq.QuoteIdentifier(schema)))
+	db.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema %s: %w", schema, err)
+	}
+
+	schemaURL, err := withSearchPath(databaseURL, schema)
+	if err != nil {
+		return nil, err
+	}
+	return ConnectDB(schemaURL)
+}
+
+// withSearchPath sets the search_path runtime parameter on a postgres:// connection URL
+func withSearchPath(databaseURL, schema string) (string, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse database url: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// MigrateDown rolls back all applied migrations
+func MigrateDown(db *sql.DB, config *BaseConfig) error {
+	m, release, err := newMigrate(db, config)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := m.Down(); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to migrate down: %w", err)
+	}
+	return nil
+}
+
+// MigrateSteps applies n migrations up (n > 0) or rolls back n migrations (n < 0)
+func MigrateSteps(db *sql.DB, config *BaseConfig, n int) error {
+	if n > 0 {
+		if err := ensurePgcrypto(db, config); err != nil {
+			return err
+		}
+	}
+
+	m, release, err := newMigrate(db, config)
 	if err != nil {
 		return err
 	}
+	defer release()
+
+	if err := m.Steps(n); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to migrate %d steps: %w", n, err)
+	}
+	return nil
+}
+
+// MigrateTo migrates up or down to the given version
+func MigrateTo(db *sql.DB, config *BaseConfig, version uint) error {
+	if err := ensurePgcrypto(db, config); err != nil {
+		return err
+	}
+
+	m, release, err := newMigrate(db, config)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := m.Migrate(version); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+	}
+	return nil
+}
+
+// MigrationVersion returns the current migration version and dirty state
+func MigrationVersion(db *sql.DB, config *BaseConfig) (MigrationStatus, error) {
+	m, release, err := newMigrate(db, config)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	defer release()
 
-	err = m.Up()
-	if err != nil && err != migrate.ErrNoChange {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return MigrationStatus{}, nil
+	}
+	if err != nil {
+		return MigrationStatus{}, fmt.Errorf("failed to read migration version: %w", err)
+	}
+
+	return MigrationStatus{
+		Version:    version,
+		Dirty:      dirty,
+		HasVersion: true,
+	}, nil
+}
+
+// ForceMigrationVersion sets the migration version without running migrations and clears the dirty flag
+// Use it after manually repairing a database left dirty by a failed migration, -1 resets to no version
+func ForceMigrationVersion(db *sql.DB, config *BaseConfig, version int) error {
+	m, release, err := newMigrate(db, config)
+	if err != nil {
 		return err
 	}
+	defer release()
+
+	if err := m.Force(version); err != nil {
+		return fmt.Errorf("failed to force migration version %d: %w", version, err)
+	}
+	return nil
+}
+
+// Migration dialects supported by BaseConfig.Dialect
+const (
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+)
+
+// migrationDriverFunc creates a golang-migrate database driver on db, release frees what the driver holds
+// without closing db itself
+type migrationDriverFunc func(ctx context.Context, db *sql.DB) (driver database.Driver, release func(), err error)
+
+// migrationDrivers creates the golang-migrate database driver of each dialect
+var migrationDrivers = map[string]migrationDriverFunc{
+	DialectPostgres: func(ctx context.Context, db *sql.DB) (database.Driver, func(), error) {
+		return withMigrationConn(ctx, db, func(conn *sql.Conn) (database.Driver, error) {
+			return postgres.WithConnection(ctx, conn, &postgres.Config{})
+		})
+	},
+	DialectMySQL: func(ctx context.Context, db *sql.DB) (database.Driver, func(), error) {
+		return withMigrationConn(ctx, db, func(conn *sql.Conn) (database.Driver, error) {
+			return mysql.WithConnection(ctx, conn, &mysql.Config{})
+		})
+	},
+}
+
+// withMigrationConn builds a driver on a dedicated connection of db, released by closing only that connection
+// WithInstance would take a connection too, but closing its driver also closes the caller's db
+func withMigrationConn(ctx context.Context, db *sql.DB, newDriver func(conn *sql.Conn) (database.Driver, error)) (database.Driver, func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to acquire connection for migrations: %w", err)
+	}
+
+	driver, err := newDriver(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return driver, func() { conn.Close() }, nil
+}
+
+// migrationDialect returns the configured dialect, defaulting to Postgres
+func migrationDialect(config *BaseConfig) string {
+	if config.Dialect == "" {
+		return DialectPostgres
+	}
+	return config.Dialect
+}
+
+// migrationDriver creates the migration driver matching the configured dialect
+func migrationDriver(db *sql.DB, config *BaseConfig) (database.Driver, func(), error) {
+	newDriver, ok := migrationDrivers[migrationDialect(config)]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported migration dialect %q", config.Dialect)
+	}
+	return newDriver(context.Background(), db)
+}
+
+// PlannedMigration is a pending migration that would be applied by the next run
+type PlannedMigration struct {
+	Version     uint
+	Name        string // identifier part of the file name, e.g. "create_users" for 000001_create_users.up.sql
+	Checksum    string // hex encoded SHA-256 of the up migration
+	Destructive bool   // the migration contains DROP or TRUNCATE statements
+}
+
+// PlanMigrations returns the pending up migrations in order without executing them
+// Deploy pipelines can print the plan and require approval when a migration is Destructive
+func PlanMigrations(db *sql.DB, config *BaseConfig) ([]PlannedMigration, error) {
+	driver, release, err := migrationDriver(db, config)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	current, dirty, err := driver.Version()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	if dirty {
+		return nil, fmt.Errorf("database is dirty at version %d, repair it with ForceMigrationVersion first", current)
+	}
+
+	src, err := source.Open(config.MigrationURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migration source: %w", err)
+	}
+	defer src.Close()
+
+	var plan []PlannedMigration
+	version, err := src.First()
+	for err == nil {
+		if int(version) > current {
+			migration, ok, err := planMigration(src, version)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				plan = append(plan, migration)
+			}
+		}
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read migration source: %w", err)
+	}
+	return plan, nil
+}
+
+// destructiveStatement matches statements that drop data
+var destructiveStatement = regexp.MustCompile(`(?i)\b(DROP|TRUNCATE)\b`)
+
+// planMigration reads the up migration of version and describes it, ok is false when there is no up migration
+func planMigration(src source.Driver, version uint) (migration PlannedMigration, ok bool, err error) {
+	body, identifier, err := src.ReadUp(version)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Versions with only a down migration are skipped by migrate as well
+		return PlannedMigration{}, false, nil
+	}
+	if err != nil {
+		return PlannedMigration{}, false, fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return PlannedMigration{}, false, fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+
+	sum := sha256.Sum256(content)
+	return PlannedMigration{
+		Version:     version,
+		Name:        identifier,
+		Checksum:    hex.EncodeToString(sum[:]),
+		Destructive: destructiveStatement.Match(content),
+	}, true, nil
+}
+
+// newMigrate creates a migrate instance for the database and the configured migration source
+// Callers must call release when done, it returns the connection the driver holds to the pool
+func newMigrate(db *sql.DB, config *BaseConfig) (*migrate.Migrate, func(), error) {
+	driver, release, err := migrationDriver(db, config)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	m, err := migrate.NewWithDatabaseInstance(config.MigrationURL, config.DBName, driver)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	if config.LockTimeout > 0 {
+		m.LockTimeout = config.LockTimeout
+	}
+	return m, release, nil
+}
+
+// ensurePgcrypto creates the pgcrypto extension migrations rely on, only needed before applying up migrations
+func ensurePgcrypto(db *sql.DB, config *BaseConfig) error {
+	if migrationDialect(config) != DialectPostgres {
+		return nil
+	}
+
+	_, err := db.Exec("CREATE EXTENSION IF NOT EXISTS pgcrypto")
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return err
+	}
 	return nil
 }
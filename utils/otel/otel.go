@@ -0,0 +1,98 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Exporters supported by Setup
+const (
+	ExporterOTLP = "otlp"
+	ExporterNone = "none"
+)
+
+// Config configures the tracer provider installed by Setup
+type Config struct {
+	ServiceName    string
+	ServiceVersion string
+	Environment    string  // exported as deployment.environment
+	Exporter       string  // ExporterOTLP (default) or ExporterNone to only propagate context
+	Endpoint       string  // OTLP/HTTP collector host:port, empty uses OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318
+	Insecure       bool    // send spans over plain HTTP
+	SampleRatio    float64 // fraction of new traces sampled, child spans follow their parent
+}
+
+// ConfigFromEnv reads the standard OTEL_* variables, OTEL_SERVICE_NAME defaults to serviceName
+func ConfigFromEnv(serviceName string) Config {
+	ratio, err := strconv.ParseFloat(utils.GetEnv("OTEL_TRACES_SAMPLER_ARG", "1"), 64)
+	if err != nil {
+		ratio = 1
+	}
+
+	return Config{
+		ServiceName:    utils.GetEnv("OTEL_SERVICE_NAME", serviceName),
+		ServiceVersion: utils.GetEnv("SERVICE_VERSION", ""),
+		Environment:    utils.CurrentAppEnv(),
+		Exporter:       utils.GetEnv("OTEL_TRACES_EXPORTER", ExporterOTLP),
+		Endpoint:       utils.GetEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", ""),
+		Insecure:       utils.GetEnvBool("OTEL_EXPORTER_OTLP_INSECURE", false),
+		SampleRatio:    ratio,
+	}
+}
+
+// Setup installs a global tracer provider and the W3C trace context and baggage propagators
+// The returned function flushes pending spans and must be called on shutdown
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(
+			attribute.String("service.name", cfg.ServiceName),
+			attribute.String("service.version", cfg.ServiceVersion),
+			attribute.String("deployment.environment", cfg.Environment),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otel resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+
+	switch cfg.Exporter {
+	case "", ExporterOTLP:
+		var exporterOpts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	case ExporterNone:
+	default:
+		return nil, fmt.Errorf("unknown otel exporter %q", cfg.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
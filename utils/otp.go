@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Errors returned by OTPManager, they carry a code so clients can tell them apart
+var (
+	ErrOTPInvalid         = &CustomError{Code: "OTP_INVALID", Message: "Invalid verification code", StatusCode: http.StatusUnauthorized}
+	ErrOTPExpired         = &CustomError{Code: "OTP_EXPIRED", Message: "Verification code expired", StatusCode: http.StatusUnauthorized}
+	ErrOTPTooManyAttempts = &CustomError{Code: "OTP_TOO_MANY_ATTEMPTS", Message: "Too many verification attempts", StatusCode: http.StatusTooManyRequests}
+	ErrOTPResendTooSoon   = &CustomError{Code: "OTP_RESEND_TOO_SOON", Message: "Verification code already sent", StatusCode: http.StatusTooManyRequests}
+)
+
+// OTPConfig configures one-time passwords
+type OTPConfig struct {
+	Secret             string        // HMAC key of the stored codes, required
+	Length             int           // digits, default 6
+	TTL                time.Duration // default 5m
+	MaxAttempts        int           // verifications before the code is discarded, default 5
+	ResendInterval     time.Duration // minimum delay between two codes sent to a number, default 1m
+	DefaultCountryCode string        // for local numbers, see NormalizePhoneNumber
+	Message            func(code string) string
+}
+
+// OTPManager sends one-time passwords by SMS and verifies them
+// Codes are stored hashed in Redis, so a leaked Redis snapshot does not reveal valid codes
+type OTPManager struct {
+	client redis.Cmdable
+	sender SMSSender
+	cfg    OTPConfig
+	prefix string
+}
+
+// NewOTPManager creates an OTP manager using keys "otp:<phone>"
+func NewOTPManager(client redis.Cmdable, sender SMSSender, cfg OTPConfig) (*OTPManager, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("otp secret is required")
+	}
+	if cfg.Length <= 0 {
+		cfg.Length = 6
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.ResendInterval <= 0 {
+		cfg.ResendInterval = time.Minute
+	}
+	if cfg.Message == nil {
+		cfg.Message = func(code string) string {
+			return fmt.Sprintf("Your verification code is %s", code)
+		}
+	}
+	return &OTPManager{client: client, sender: sender, cfg: cfg, prefix: "otp:"}, nil
+}
+
+// Send generates a code, stores it and sends it to phone, replacing the previous code of the number
+// It returns the normalized number, or ErrOTPResendTooSoon within the resend interval
+func (m *OTPManager) Send(ctx context.Context, phone string) (string, error) {
+	phone, err := NormalizePhoneNumber(phone, m.cfg.DefaultCountryCode)
+	if err != nil {
+		return "", err
+	}
+
+	ok, err := m.client.SetNX(ctx, m.prefix+phone+":resend", 1, m.cfg.ResendInterval).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to check otp resend interval: %w", err)
+	}
+	if !ok {
+		return "", WrapError(nil, ErrOTPResendTooSoon, "verification code already sent, retry later")
+	}
+
+	code, err := generateOTP(m.cfg.Length)
+	if err != nil {
+		return "", err
+	}
+
+	key := m.prefix + phone
+	_, err = m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, "hash", m.hash(phone, code), "attempts", 0)
+		pipe.PExpire(ctx, key, m.cfg.TTL)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store otp: %w", err)
+	}
+
+	if err := m.sender.SendSMS(ctx, phone, m.cfg.Message(code)); err != nil {
+		// Let the caller retry right away, the code never reached the user
+		m.client.Del(ctx, m.prefix+phone+":resend")
+		return "", fmt.Errorf("failed to send otp: %w", err)
+	}
+	return phone, nil
+}
+
+// otpVerifyScript counts the attempt and consumes the code when the hash matches
+// It returns 1 on success, 0 on mismatch, -1 without code and -2 once the attempts are exhausted
+var otpVerifyScript = redis.NewScript(`
+local hash = redis.call('HGET', KEYS[1], 'hash')
+if not hash then
+	return -1
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts > tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+	return -2
+end
+if hash == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 1
+end
+return 0
+`)
+
+// Verify checks code against the code sent to phone, a matching code can only be used once
+// It returns ErrOTPInvalid, ErrOTPExpired when there is no code, or ErrOTPTooManyAttempts
+func (m *OTPManager) Verify(ctx context.Context, phone, code string) error {
+	phone, err := NormalizePhoneNumber(phone, m.cfg.DefaultCountryCode)
+	if err != nil {
+		return err
+	}
+
+	result, err := otpVerifyScript.Run(ctx, m.client, []string{m.prefix + phone}, m.hash(phone, code), m.cfg.MaxAttempts).Int()
+	if err != nil {
+		return fmt.Errorf("failed to verify otp: %w", err)
+	}
+	switch result {
+	case 1:
+		return nil
+	case -1:
+		return WrapError(nil, ErrOTPExpired, "verification code expired or not requested")
+	case -2:
+		return WrapError(nil, ErrOTPTooManyAttempts, "too many verification attempts, request a new code")
+	default:
+		return WrapError(nil, ErrOTPInvalid, "invalid verification code")
+	}
+}
+
+// hash returns the HMAC of the code bound to the phone number
+func (m *OTPManager) hash(phone, code string) string {
+	mac := hmac.New(sha256.New, []byte(m.cfg.Secret))
+	mac.Write([]byte(phone + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateOTP returns a uniformly random numeric code of length digits
+func generateOTP(length int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate otp: %w", err)
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Table is the name of the outbox table
+const Table = "outbox_events"
+
+// Migration creates the outbox table, copy it into a service migration or run it with EnsureTable
+const Migration = `CREATE TABLE IF NOT EXISTS outbox_events (
+	id BIGSERIAL PRIMARY KEY,
+	event_id UUID NOT NULL UNIQUE,
+	topic TEXT NOT NULL,
+	key TEXT NOT NULL DEFAULT '',
+	payload JSONB NOT NULL,
+	headers JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	published_at TIMESTAMPTZ,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT
+);
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events (id) WHERE published_at IS NULL;`
+
+// Event is a message recorded in the outbox and published by the Relay after the transaction commits
+type Event struct {
+	ID        string            // unique event id, consumers use it to drop duplicates
+	Topic     string            // stream, topic or routing key
+	Key       string            // partitioning key, e.g. the aggregate id
+	Payload   json.RawMessage   // JSON payload
+	Headers   map[string]string // optional metadata
+	CreatedAt time.Time
+}
+
+// NewEvent creates an event with a new id and payload marshaled to JSON
+func NewEvent(topic, key string, payload interface{}) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+	return Event{
+		ID:      uuid.NewString(),
+		Topic:   topic,
+		Key:     key,
+		Payload: data,
+	}, nil
+}
+
+// EnsureTable creates the outbox table if it does not exist
+func EnsureTable(ctx context.Context, db utils.PGXPool) error {
+	if _, err := db.Exec(ctx, Migration); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", Table, err)
+	}
+	return nil
+}
+
+// WriteOutboxEvent records the event in the transaction, typically inside utils.ExecTxContext next to the
+// business changes, so the event is published if and only if the transaction commits
+func WriteOutboxEvent(ctx context.Context, tx pgx.Tx, event Event) error {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.Topic == "" {
+		return fmt.Errorf("outbox event requires a topic")
+	}
+
+	headers, err := json.Marshal(event.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox headers: %w", err)
+	}
+	if event.Headers == nil {
+		headers = []byte("{}")
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO "+Table+" (event_id, topic, key, payload, headers) VALUES ($1, $2, $3, $4, $5)",
+		event.ID, event.Topic, event.Key, []byte(event.Payload), headers)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Publisher delivers outbox events to a broker, returning an error leaves the event pending for a retry
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface, e.g. to wrap a Kafka producer
+type PublisherFunc func(ctx context.Context, event Event) error
+
+// Publish calls f
+func (f PublisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// RedisStreamPublisher appends events to the Redis stream named after the event topic
+type RedisStreamPublisher struct {
+	client redis.Cmdable
+	maxLen int64
+}
+
+// NewRedisStreamPublisher creates a publisher appending to Redis streams, maxLen > 0 caps stream length approximately
+func NewRedisStreamPublisher(client redis.Cmdable, maxLen int64) *RedisStreamPublisher {
+	return &RedisStreamPublisher{client: client, maxLen: maxLen}
+}
+
+// Publish adds the event to its stream
+func (p *RedisStreamPublisher) Publish(ctx context.Context, event Event) error {
+	values := map[string]interface{}{
+		"event_id": event.ID,
+		"key":      event.Key,
+		"payload":  string(event.Payload),
+	}
+	for name, value := range event.Headers {
+		values["header:"+name] = value
+	}
+
+	args := &redis.XAddArgs{
+		Stream: event.Topic,
+		Values: values,
+	}
+	if p.maxLen > 0 {
+		args.MaxLen = p.maxLen
+		args.Approx = true
+	}
+
+	if err := p.client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("failed to add event to stream %s: %w", event.Topic, err)
+	}
+	return nil
+}
+
+// WebhookPublisher POSTs the event payload to a URL, with the event metadata in headers
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookPublisher creates a publisher posting to url, a nil client uses a client with a 10 seconds timeout
+func NewWebhookPublisher(url string, client *http.Client) *WebhookPublisher {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &WebhookPublisher{url: url, client: client}
+}
+
+// Publish posts the event, any non 2xx response is an error
+func (p *WebhookPublisher) Publish(ctx context.Context, event Event) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(event.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID)
+	req.Header.Set("X-Event-Topic", event.Topic)
+	if event.Key != "" {
+		req.Header.Set("X-Event-Key", event.Key)
+	}
+	for name, value := range event.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
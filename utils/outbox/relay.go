@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// RelayOption configures a Relay
+type RelayOption func(*Relay)
+
+// WithBatchSize sets how many events are locked and published per run (default 100)
+func WithBatchSize(n int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// WithInterval sets the polling interval (default 1s)
+func WithInterval(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = d
+	}
+}
+
+// WithMaxAttempts sets after how many failed publish attempts an event is left aside (default 10)
+func WithMaxAttempts(n int) RelayOption {
+	return func(r *Relay) {
+		r.maxAttempts = n
+	}
+}
+
+// Relay polls the outbox table and publishes pending events
+// Rows are locked with FOR UPDATE SKIP LOCKED so several instances can run the relay concurrently.
+// Delivery is at-least-once: an event may be published again if marking it fails, consumers dedupe by Event.ID
+type Relay struct {
+	db          utils.PGXPool
+	publisher   Publisher
+	batchSize   int
+	interval    time.Duration
+	maxAttempts int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRelay creates a relay publishing events of db to publisher
+func NewRelay(db utils.PGXPool, publisher Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{
+		db:          db,
+		publisher:   publisher,
+		batchSize:   100,
+		interval:    time.Second,
+		maxAttempts: 10,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.batchSize < 1 {
+		r.batchSize = 1
+	}
+	if r.interval <= 0 {
+		r.interval = time.Second
+	}
+	return r
+}
+
+// Start runs the relay loop in the background until Stop is called or ctx is done
+func (r *Relay) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			published, err := r.RunOnce(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Outbox relay failed: %v", err)
+			}
+
+			// Keep draining without waiting while full batches are published
+			if published == r.batchSize {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the relay loop and waits for the current run to finish
+func (r *Relay) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// RunOnce publishes one batch of pending events and returns how many were published
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	published := 0
+	err := utils.ExecTxContext(ctx, r.db, func(ctx context.Context, tx pgx.Tx) error {
+		events, err := r.lockPending(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, pending := range events {
+			if err := r.publisher.Publish(ctx, pending.event); err != nil {
+				log.Printf("Failed to publish outbox event %s: %v", pending.event.ID, err)
+				_, err = tx.Exec(ctx, "UPDATE "+Table+" SET attempts = attempts + 1, last_error = $2 WHERE id = $1", pending.id, err.Error())
+				if err != nil {
+					return fmt.Errorf("failed to record outbox failure: %w", err)
+				}
+				continue
+			}
+
+			if _, err := tx.Exec(ctx, "UPDATE "+Table+" SET published_at = NOW(), attempts = attempts + 1 WHERE id = $1", pending.id); err != nil {
+				return fmt.Errorf("failed to mark outbox event published: %w", err)
+			}
+			published++
+		}
+		return nil
+	})
+	return published, err
+}
+
+// pendingEvent is an event locked by the current run
+type pendingEvent struct {
+	id    int64
+	event Event
+}
+
+// lockPending locks the next batch of unpublished events, skipping rows locked by other relays
+func (r *Relay) lockPending(ctx context.Context, tx pgx.Tx) ([]pendingEvent, error) {
+	rows, err := tx.Query(ctx, `SELECT id, event_id, topic, key, payload, headers, created_at FROM `+Table+`
+		WHERE published_at IS NULL AND attempts < $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, r.maxAttempts, r.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []pendingEvent
+	for rows.Next() {
+		var pending pendingEvent
+		var payload, headers []byte
+		e := &pending.event
+		if err := rows.Scan(&pending.id, &e.ID, &e.Topic, &e.Key, &payload, &headers, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		e.Payload = payload
+		if err := json.Unmarshal(headers, &e.Headers); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox headers: %w", err)
+		}
+		events = append(events, pending)
+	}
+	return events, rows.Err()
+}
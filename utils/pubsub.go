@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PubSubMessage is a message received on a Redis channel
+type PubSubMessage struct {
+	Channel string
+	Payload []byte
+}
+
+// Decode unmarshals the JSON payload into v
+func (m PubSubMessage) Decode(v interface{}) error {
+	return json.Unmarshal(m.Payload, v)
+}
+
+// PubSubHandler handles messages delivered by a RedisPubSub, errors are logged
+type PubSubHandler func(ctx context.Context, msg PubSubMessage) error
+
+// RedisPubSub publishes JSON messages and dispatches received messages to handlers
+// The subscription is re-established with backoff when the connection is lost and every channel is subscribed again
+type RedisPubSub struct {
+	client redis.UniversalClient
+	policy RetryPolicy
+
+	mu       sync.RWMutex
+	handlers map[string][]PubSubHandler
+	pubsub   *redis.PubSub
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRedisPubSub creates a new pub/sub, the policy controls reconnect backoff (MaxAttempts is ignored)
+func NewRedisPubSub(client redis.UniversalClient, policy RetryPolicy) *RedisPubSub {
+	return &RedisPubSub{
+		client:   client,
+		policy:   policy,
+		handlers: map[string][]PubSubHandler{},
+	}
+}
+
+// Publish JSON encodes payload and publishes it on channel, []byte and string payloads are sent as is
+func (p *RedisPubSub) Publish(ctx context.Context, channel string, payload interface{}) error {
+	var data interface{}
+	switch v := payload.(type) {
+	case []byte, string:
+		data = v
+	default:
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode message for %s: %w", channel, err)
+		}
+		data = encoded
+	}
+
+	if err := p.client.Publish(ctx, channel, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Subscribe registers a handler for a channel, it can be called before or after Start
+func (p *RedisPubSub) Subscribe(channel string, handler PubSubHandler) error {
+	p.mu.Lock()
+	_, subscribed := p.handlers[channel]
+	p.handlers[channel] = append(p.handlers[channel], handler)
+	pubsub := p.pubsub
+	p.mu.Unlock()
+
+	if pubsub == nil || subscribed {
+		return nil
+	}
+	if err := pubsub.Subscribe(context.Background(), channel); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+	return nil
+}
+
+// SubscribeJSON registers a handler receiving the payloads of channel decoded as T
+func SubscribeJSON[T any](p *RedisPubSub, channel string, handler func(ctx context.Context, payload T) error) error {
+	return p.Subscribe(channel, func(ctx context.Context, msg PubSubMessage) error {
+		var payload T
+		if err := msg.Decode(&payload); err != nil {
+			return fmt.Errorf("failed to decode message: %w", err)
+		}
+		return handler(ctx, payload)
+	})
+}
+
+// Start subscribes to the registered channels and dispatches messages until Stop is called or ctx is done
+func (p *RedisPubSub) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	p.mu.Lock()
+	channels := make([]string, 0, len(p.handlers))
+	for channel := range p.handlers {
+		channels = append(channels, channel)
+	}
+	p.pubsub = p.client.Subscribe(ctx, channels...)
+	pubsub := p.pubsub
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.run(ctx, pubsub)
+	}()
+}
+
+// Stop closes the subscription and waits for the handler in progress to return
+func (p *RedisPubSub) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	p.mu.Lock()
+	pubsub := p.pubsub
+	p.pubsub = nil
+	p.mu.Unlock()
+	if pubsub != nil {
+		// Closing unblocks the pending receive
+		pubsub.Close()
+	}
+	p.wg.Wait()
+}
+
+// run receives messages, go-redis reconnects and resubscribes on the next receive after a failure
+func (p *RedisPubSub) run(ctx context.Context, pubsub *redis.PubSub) {
+	attempt := 0
+	for ctx.Err() == nil {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || err == redis.ErrClosed {
+				return
+			}
+
+			log.Printf("Redis pub/sub disconnected (attempt %d): %v", attempt+1, err)
+			if !sleepContext(ctx, p.policy.Delay(attempt)) {
+				return
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		p.dispatch(ctx, PubSubMessage{Channel: msg.Channel, Payload: []byte(msg.Payload)})
+	}
+}
+
+// dispatch calls the handlers of the message channel
+func (p *RedisPubSub) dispatch(ctx context.Context, msg PubSubMessage) {
+	p.mu.RLock()
+	handlers := p.handlers[msg.Channel]
+	p.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, msg); err != nil {
+			log.Printf("Failed to handle message on %s: %v", msg.Channel, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Job is a message delivered to a Worker handler
+type Job struct {
+	ID         string
+	Queue      string
+	Payload    json.RawMessage
+	Attempt    int // 1 on the first delivery
+	EnqueuedAt time.Time
+}
+
+// Decode unmarshals the JSON payload into v
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// QueueOption configures a Queue
+type QueueOption func(*Queue)
+
+// WithPrefix sets the prefix of the stream keys (default "queue:")
+func WithPrefix(prefix string) QueueOption {
+	return func(q *Queue) {
+		q.prefix = prefix
+	}
+}
+
+// WithMaxLen approximately caps every stream to n entries, acknowledged or not (default 0, unbounded)
+func WithMaxLen(n int64) QueueOption {
+	return func(q *Queue) {
+		q.maxLen = n
+	}
+}
+
+// Queue enqueues jobs on Redis Streams, one stream per queue name
+type Queue struct {
+	client redis.Cmdable
+	prefix string
+	maxLen int64
+}
+
+// NewQueue creates a queue client
+func NewQueue(client redis.Cmdable, opts ...QueueOption) *Queue {
+	q := &Queue{
+		client: client,
+		prefix: "queue:",
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Stream returns the stream key of a queue
+// The name is a hash tag so the stream and its retry and dead-letter keys share a Redis Cluster slot
+func (q *Queue) Stream(name string) string {
+	return q.prefix + "{" + name + "}"
+}
+
+// DeadLetterStream returns the stream key receiving the jobs of a queue that exhausted their attempts
+func (q *Queue) DeadLetterStream(name string) string {
+	return q.Stream(name) + ":dead"
+}
+
+// Enqueue JSON encodes payload and appends it to the queue, returning the job ID
+func (q *Queue) Enqueue(ctx context.Context, name string, payload interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode job for %s: %w", name, err)
+	}
+
+	args := &redis.XAddArgs{
+		Stream: q.Stream(name),
+		Values: map[string]interface{}{
+			"payload":     data,
+			"enqueued_at": time.Now().UnixMilli(),
+		},
+	}
+	if q.maxLen > 0 {
+		args.MaxLen = q.maxLen
+		args.Approx = true
+	}
+
+	id, err := q.client.XAdd(ctx, args).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue job on %s: %w", name, err)
+	}
+	return id, nil
+}
+
+// newJob builds a Job from a stream entry
+func newJob(name string, msg redis.XMessage, attempt int) *Job {
+	job := &Job{
+		ID:      msg.ID,
+		Queue:   name,
+		Attempt: attempt,
+	}
+	if payload, ok := msg.Values["payload"].(string); ok {
+		job.Payload = json.RawMessage(payload)
+	}
+	if enqueuedAt, ok := msg.Values["enqueued_at"].(string); ok {
+		if ms, err := strconv.ParseInt(enqueuedAt, 10, 64); err == nil {
+			job.EnqueuedAt = time.UnixMilli(ms)
+		}
+	}
+	return job
+}
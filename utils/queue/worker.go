@@ -0,0 +1,335 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Handler processes a job, returning an error schedules a retry unless it is marked with utils.MarkPermanent
+// or, with WithRetryIf, classified as not worth retrying
+type Handler func(ctx context.Context, job *Job) error
+
+// WorkerOption configures a Worker
+type WorkerOption func(*Worker)
+
+// WithConcurrency sets how many jobs are processed at the same time (default 1)
+func WithConcurrency(n int) WorkerOption {
+	return func(w *Worker) {
+		w.concurrency = n
+	}
+}
+
+// WithRetryPolicy sets the attempts and the backoff between them (default 5 attempts from 1s up to 1m)
+func WithRetryPolicy(policy utils.RetryPolicy) WorkerOption {
+	return func(w *Worker) {
+		w.policy = policy
+	}
+}
+
+// WithRetryIf sets the classification of failed jobs worth retrying, e.g. utils.IsRetryable
+// The default retries every error except those marked with utils.MarkPermanent, since handler errors are
+// application errors that utils.IsRetryable would mostly report as permanent and dead-letter on the first failure
+func WithRetryIf(fn func(err error) bool) WorkerOption {
+	return func(w *Worker) {
+		if fn != nil {
+			w.retryIf = fn
+		}
+	}
+}
+
+// WithVisibilityTimeout sets after how long the job of a crashed worker is claimed by another one (default 5m)
+// It must be longer than the slowest job and than the maximum retry backoff
+func WithVisibilityTimeout(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.visibilityTimeout = d
+	}
+}
+
+// WithJobTimeout bounds the duration of a single handler call (default 0, unbounded)
+func WithJobTimeout(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.jobTimeout = d
+	}
+}
+
+// WithConsumer sets the consumer name in the group (default hostname and a random suffix)
+func WithConsumer(name string) WorkerOption {
+	return func(w *Worker) {
+		w.consumer = name
+	}
+}
+
+// promoteScript pops the job IDs whose retry is due
+var promoteScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #ids > 0 then
+	redis.call('ZREM', KEYS[1], unpack(ids))
+end
+return ids
+`)
+
+// Worker consumes a queue as a member of a consumer group
+// Failed jobs stay pending in the group and are claimed again once their backoff elapsed, jobs that exhaust
+// their attempts are moved to the dead-letter stream. Delivery is at-least-once, handlers must be idempotent
+type Worker struct {
+	queue   *Queue
+	name    string
+	group   string
+	handler Handler
+
+	consumer          string
+	concurrency       int
+	policy            utils.RetryPolicy
+	retryIf           func(err error) bool
+	visibilityTimeout time.Duration
+	jobTimeout        time.Duration
+	block             time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorker creates a worker processing the jobs of queue name in group
+func NewWorker(queue *Queue, name, group string, handler Handler, opts ...WorkerOption) *Worker {
+	hostname, _ := os.Hostname()
+	w := &Worker{
+		queue:       queue,
+		name:        name,
+		group:       group,
+		handler:     handler,
+		consumer:    hostname + "-" + uuid.NewString()[:8],
+		concurrency: 1,
+		policy: utils.RetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Minute,
+			Multiplier:  2,
+			Jitter:      0.2,
+		},
+		visibilityTimeout: 5 * time.Minute,
+		block:             2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.concurrency < 1 {
+		w.concurrency = 1
+	}
+	if w.retryIf == nil {
+		w.retryIf = func(err error) bool { return !utils.IsPermanent(err) }
+	}
+	return w
+}
+
+func (w *Worker) retryKey() string {
+	return w.queue.Stream(w.name) + ":" + w.group + ":retry"
+}
+
+func (w *Worker) attemptsKey() string {
+	return w.queue.Stream(w.name) + ":" + w.group + ":attempts"
+}
+
+// Start creates the consumer group if needed and processes jobs in the background until Stop is called or ctx is done
+func (w *Worker) Start(ctx context.Context) error {
+	err := w.queue.client.XGroupCreateMkStream(ctx, w.queue.Stream(w.name), w.group, "0").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s: %w", w.group, err)
+	}
+
+	// Jobs in progress finish on a context that is not canceled by Stop
+	jobCtx := context.WithoutCancel(ctx)
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	jobs := make(chan redis.XMessage)
+	for i := 0; i < w.concurrency; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for msg := range jobs {
+				w.process(jobCtx, msg)
+			}
+		}()
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer close(jobs)
+		w.fetch(ctx, jobs)
+	}()
+	return nil
+}
+
+// Stop stops fetching jobs and waits for the jobs in progress
+func (w *Worker) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+// fetch feeds jobs with due retries, abandoned jobs and new jobs
+func (w *Worker) fetch(ctx context.Context, jobs chan<- redis.XMessage) {
+	attempt := 0
+	nextClaim := time.Now()
+	for ctx.Err() == nil {
+		var messages []redis.XMessage
+		retries, err := w.dueRetries(ctx)
+		if err == nil {
+			messages = append(messages, retries...)
+		}
+
+		if err == nil && time.Now().After(nextClaim) {
+			var abandoned []redis.XMessage
+			abandoned, _, err = w.queue.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   w.queue.Stream(w.name),
+				Group:    w.group,
+				Consumer: w.consumer,
+				MinIdle:  w.visibilityTimeout,
+				Start:    "0-0",
+				Count:    int64(w.concurrency),
+			}).Result()
+			messages = append(messages, abandoned...)
+			nextClaim = time.Now().Add(w.visibilityTimeout / 2)
+		}
+
+		if err == nil && len(messages) == 0 {
+			var streams []redis.XStream
+			streams, err = w.queue.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    w.group,
+				Consumer: w.consumer,
+				Streams:  []string{w.queue.Stream(w.name), ">"},
+				Count:    int64(w.concurrency),
+				Block:    w.block,
+			}).Result()
+			if errors.Is(err, redis.Nil) {
+				err = nil
+			}
+			for _, stream := range streams {
+				messages = append(messages, stream.Messages...)
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to fetch jobs from %s (attempt %d): %v", w.name, attempt+1, err)
+			if !sleepContext(ctx, w.policy.Delay(attempt)) {
+				return
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		for _, msg := range messages {
+			select {
+			case jobs <- msg:
+			case <-ctx.Done():
+				// Undelivered messages stay pending and are claimed again after the visibility timeout
+				return
+			}
+		}
+	}
+}
+
+// dueRetries claims the failed jobs whose backoff elapsed
+func (w *Worker) dueRetries(ctx context.Context) ([]redis.XMessage, error) {
+	ids, err := promoteScript.Run(ctx, w.queue.client, []string{w.retryKey()},
+		time.Now().UnixMilli(), w.concurrency).StringSlice()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	messages, err := w.queue.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   w.queue.Stream(w.name),
+		Group:    w.group,
+		Consumer: w.consumer,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim retries: %w", err)
+	}
+	return messages, nil
+}
+
+// process runs the handler and acknowledges, retries or dead-letters the job
+func (w *Worker) process(ctx context.Context, msg redis.XMessage) {
+	failures, err := w.queue.client.HGet(ctx, w.attemptsKey(), msg.ID).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("Failed to read attempts of job %s: %v", msg.ID, err)
+	}
+	job := newJob(w.name, msg, failures+1)
+
+	handlerErr := w.handle(ctx, job)
+
+	stream := w.queue.Stream(w.name)
+	_, err = w.queue.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		switch {
+		case handlerErr == nil:
+			pipe.XAck(ctx, stream, w.group, msg.ID)
+			pipe.HDel(ctx, w.attemptsKey(), msg.ID)
+		case job.Attempt < w.policy.MaxAttempts && w.retryIf(handlerErr):
+			// Leave the job pending, dueRetries claims it again once the backoff elapsed
+			retryAt := time.Now().Add(w.policy.Delay(job.Attempt - 1))
+			pipe.HIncrBy(ctx, w.attemptsKey(), msg.ID, 1)
+			pipe.ZAdd(ctx, w.retryKey(), redis.Z{Score: float64(retryAt.UnixMilli()), Member: msg.ID})
+		default:
+			values := map[string]interface{}{
+				"id":        msg.ID,
+				"group":     w.group,
+				"attempts":  job.Attempt,
+				"error":     handlerErr.Error(),
+				"failed_at": time.Now().UnixMilli(),
+			}
+			for key, value := range msg.Values {
+				values[key] = value
+			}
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: w.queue.DeadLetterStream(w.name), Values: values})
+			pipe.XAck(ctx, stream, w.group, msg.ID)
+			pipe.HDel(ctx, w.attemptsKey(), msg.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to settle job %s of %s: %v", msg.ID, w.name, err)
+	}
+	if handlerErr != nil {
+		log.Printf("Job %s of %s failed (attempt %d/%d): %v", msg.ID, w.name, job.Attempt, w.policy.MaxAttempts, handlerErr)
+	}
+}
+
+// handle calls the handler with the job timeout, reporting panics as errors
+func (w *Worker) handle(ctx context.Context, job *Job) (err error) {
+	if w.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.jobTimeout)
+		defer cancel()
+	}
+
+	defer utils.RecoverAndReport(ctx, "queue "+w.name, &err)
+	return w.handler(ctx, job)
+}
+
+// sleepContext waits for d or until ctx is done, returning false if ctx is done
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// QuotaPeriod is the window a quota counter covers, counters roll over at the start of each UTC period
+type QuotaPeriod string
+
+const (
+	QuotaDaily   QuotaPeriod = "daily"
+	QuotaMonthly QuotaPeriod = "monthly"
+)
+
+// bounds returns the start of the period containing t and the start of the next one
+func (p QuotaPeriod) bounds(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	if p == QuotaMonthly {
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// QuotaLimit limits the usage of a period, Soft only flags the usage while Hard rejects it (0 means no limit)
+type QuotaLimit struct {
+	Period QuotaPeriod
+	Soft   int64
+	Hard   int64
+}
+
+// QuotaPlan is the set of limits of an API tier, e.g. free: 1000 a day and 20000 a month
+// Each period may appear once, since limits of the same period would share a counter
+type QuotaPlan struct {
+	Name   string
+	Limits []QuotaLimit
+}
+
+// validate rejects plans with several limits for the same period
+func (p QuotaPlan) validate() error {
+	seen := make(map[QuotaPeriod]bool, len(p.Limits))
+	for _, limit := range p.Limits {
+		if seen[limit.Period] {
+			return fmt.Errorf("quota plan %q has more than one %s limit", p.Name, limit.Period)
+		}
+		seen[limit.Period] = true
+	}
+	return nil
+}
+
+// QuotaUsage is the usage of a subject for one limit of its plan
+type QuotaUsage struct {
+	Period       QuotaPeriod `json:"period"`
+	Used         int64       `json:"used"`
+	Soft         int64       `json:"soft_limit,omitempty"`
+	Hard         int64       `json:"hard_limit,omitempty"`
+	ResetAt      time.Time   `json:"reset_at"`
+	SoftExceeded bool        `json:"soft_exceeded"`
+	Exceeded     bool        `json:"exceeded"`
+}
+
+// Remaining returns the usage left before the hard limit, -1 when there is none
+func (u QuotaUsage) Remaining() int64 {
+	if u.Hard <= 0 {
+		return -1
+	}
+	if u.Used >= u.Hard {
+		return 0
+	}
+	return u.Hard - u.Used
+}
+
+// quotaConsumeScript increments every counter by ARGV[1] unless one of them would exceed its hard limit,
+// so a rejected request does not count against any period. ARGV holds a hard limit and expiry per key
+var quotaConsumeScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local used = {}
+local allowed = 1
+for i, key in ipairs(KEYS) do
+	local hard = tonumber(ARGV[i * 2])
+	used[i] = tonumber(redis.call('GET', key) or '0')
+	if hard > 0 and used[i] + n > hard then
+		allowed = 0
+	end
+end
+if allowed == 1 then
+	for i, key in ipairs(KEYS) do
+		used[i] = redis.call('INCRBY', key, n)
+		redis.call('EXPIREAT', key, ARGV[i * 2 + 1])
+	end
+end
+table.insert(used, 1, allowed)
+return used
+`)
+
+// QuotaManager enforces plan quotas with counters shared by all instances through Redis
+type QuotaManager struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewQuotaManager creates a quota manager
+func NewQuotaManager(client redis.Cmdable) *QuotaManager {
+	return &QuotaManager{
+		client: client,
+		prefix: "quota:",
+	}
+}
+
+// Consume records n units of usage for subject (a user ID or API key) if no hard limit of plan is exceeded
+// It returns whether the usage was accepted and the usage of every limit after the call
+func (m *QuotaManager) Consume(ctx context.Context, subject string, plan QuotaPlan, n int64) (bool, []QuotaUsage, error) {
+	if err := plan.validate(); err != nil {
+		return false, nil, err
+	}
+
+	now := time.Now()
+	keys := make([]string, len(plan.Limits))
+	args := []interface{}{n}
+	for i, limit := range plan.Limits {
+		start, end := limit.Period.bounds(now)
+		keys[i] = m.key(subject, limit.Period, start)
+		// Keep counters a day past the period so usage of the previous period can still be reported
+		args = append(args, limit.Hard, end.Add(24*time.Hour).Unix())
+	}
+	if len(keys) == 0 {
+		return true, nil, nil
+	}
+
+	values, err := quotaConsumeScript.Run(ctx, m.client, keys, args...).Int64Slice()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to consume quota: %w", err)
+	}
+
+	allowed := values[0] == 1
+	usage := make([]QuotaUsage, len(plan.Limits))
+	for i, limit := range plan.Limits {
+		usage[i] = newQuotaUsage(limit, values[i+1], now)
+		if !allowed && limit.Hard > 0 && values[i+1]+n > limit.Hard {
+			usage[i].Exceeded = true
+		}
+	}
+	return allowed, usage, nil
+}
+
+// Usage returns the usage of subject in the current periods of plan, for usage reporting endpoints
+func (m *QuotaManager) Usage(ctx context.Context, subject string, plan QuotaPlan) ([]QuotaUsage, error) {
+	if err := plan.validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	usage := make([]QuotaUsage, len(plan.Limits))
+	for i, limit := range plan.Limits {
+		start, _ := limit.Period.bounds(now)
+		used, err := m.client.Get(ctx, m.key(subject, limit.Period, start)).Int64()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to get quota usage: %w", err)
+		}
+		usage[i] = newQuotaUsage(limit, used, now)
+	}
+	return usage, nil
+}
+
+// Reset clears the usage of subject in the current period, e.g. after an upgrade to a higher plan
+func (m *QuotaManager) Reset(ctx context.Context, subject string, period QuotaPeriod) error {
+	start, _ := period.bounds(time.Now())
+	if err := m.client.Del(ctx, m.key(subject, period, start)).Err(); err != nil {
+		return fmt.Errorf("failed to reset quota: %w", err)
+	}
+	return nil
+}
+
+// key returns the counter key of subject for the period starting at start, e.g. quota:{user:42}:daily:20261015
+// The subject is a hash tag so the counters consumed together by one script share a Redis Cluster slot
+func (m *QuotaManager) key(subject string, period QuotaPeriod, start time.Time) string {
+	layout := "20060102"
+	if period == QuotaMonthly {
+		layout = "200601"
+	}
+	return m.prefix + "{" + subject + "}:" + string(period) + ":" + start.Format(layout)
+}
+
+func newQuotaUsage(limit QuotaLimit, used int64, now time.Time) QuotaUsage {
+	_, end := limit.Period.bounds(now)
+	return QuotaUsage{
+		Period:       limit.Period,
+		Used:         used,
+		Soft:         limit.Soft,
+		Hard:         limit.Hard,
+		ResetAt:      end,
+		SoftExceeded: limit.Soft > 0 && used > limit.Soft,
+		Exceeded:     limit.Hard > 0 && used >= limit.Hard,
+	}
+}
@@ -0,0 +1,190 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrClosed is returned once the connection was closed with Close
+var ErrClosed = errors.New("rabbitmq connection closed")
+
+// Connection is an AMQP connection that reconnects with backoff when the broker drops it and declares
+// the configured topology after every connection. Publishers and consumers open their channels through it
+type Connection struct {
+	cfg Config
+
+	mu    sync.Mutex
+	conn  *amqp.Connection
+	ready chan struct{} // closed while conn is usable
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// Dial connects, retrying according to cfg.Reconnect, and declares the topology
+func Dial(ctx context.Context, cfg Config) (*Connection, error) {
+	if cfg.Reconnect.Backoff == 0 {
+		cfg.Reconnect = retry.Policy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 30 * time.Second, Multiplier: 2, Jitter: 0.2}
+	}
+	c := &Connection{cfg: cfg, ready: make(chan struct{}), done: make(chan struct{})}
+
+	conn, err := retry.DoValue(ctx, cfg.Reconnect, c.connect, retry.OnRetry(func(attempt int, err error, delay time.Duration) {
+		logger.Named("rabbitmq").WarnContext(ctx, "failed to connect to rabbitmq", "attempt", attempt+1, "error", err)
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to rabbitmq: %w", err)
+	}
+	c.setConn(conn)
+
+	c.wg.Add(1)
+	go c.watch(conn)
+	return c, nil
+}
+
+// connect opens a connection and declares the topology, topology errors are not retried
+func (c *Connection) connect(context.Context) (*amqp.Connection, error) {
+	conn, err := amqp.Dial(c.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err == nil {
+		err = c.cfg.declare(ch)
+		ch.Close()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, retry.MarkPermanent(err)
+	}
+	return conn, nil
+}
+
+// setConn publishes a usable connection, returning false if Close was called meanwhile
+func (c *Connection) setConn(conn *amqp.Connection) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isClosed() {
+		return false
+	}
+	c.conn = conn
+	close(c.ready)
+	return true
+}
+
+// watch reconnects whenever the connection is lost until Close
+func (c *Connection) watch(conn *amqp.Connection) {
+	defer c.wg.Done()
+	log := logger.Named("rabbitmq")
+
+	for {
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		select {
+		case <-c.done:
+			return
+		case err := <-closed:
+			if c.isClosed() {
+				return
+			}
+			log.Warn("rabbitmq connection lost, reconnecting", "error", err)
+		}
+
+		c.mu.Lock()
+		c.conn = nil
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+
+		for attempt := 0; ; attempt++ {
+			var err error
+			conn, err = c.connect(context.Background())
+			if err == nil {
+				break
+			}
+			delay := c.cfg.Reconnect.Delay(attempt)
+			log.Warn("failed to reconnect to rabbitmq", "attempt", attempt+1, "delay", delay, "error", err)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-c.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		if !c.setConn(conn) {
+			conn.Close()
+			return
+		}
+		log.Info("reconnected to rabbitmq")
+	}
+}
+
+// isClosed reports whether Close was called
+func (c *Connection) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Channel opens a channel, waiting for the connection to be reestablished if it is down
+func (c *Connection) Channel(ctx context.Context) (*amqp.Channel, error) {
+	for {
+		c.mu.Lock()
+		conn, ready := c.conn, c.ready
+		c.mu.Unlock()
+
+		var poll <-chan time.Time
+		if conn != nil {
+			ch, err := conn.Channel()
+			if err == nil {
+				return ch, nil
+			}
+			if !errors.Is(err, amqp.ErrClosed) {
+				return nil, fmt.Errorf("failed to open channel: %w", err)
+			}
+			// The connection just dropped and watch has not noticed it yet
+			ready = nil
+			poll = time.After(100 * time.Millisecond)
+		}
+
+		select {
+		case <-ready:
+		case <-poll:
+		case <-c.done:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close closes the connection and stops reconnecting
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	if c.isClosed() {
+		c.mu.Unlock()
+		return nil
+	}
+	close(c.done)
+	conn := c.conn
+	c.mu.Unlock()
+
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	c.wg.Wait()
+	if err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return fmt.Errorf("failed to close rabbitmq connection: %w", err)
+	}
+	return nil
+}
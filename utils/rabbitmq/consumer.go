@@ -0,0 +1,222 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/event"
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Handler processes a delivery, returning an error rejects it according to the consumer RequeuePolicy
+type Handler func(ctx context.Context, d *amqp.Delivery) error
+
+// EnvelopeHandler adapts an event bus handler, so event.Retry and event.DeadLetter can bound the attempts
+// Bodies that are not envelopes are logged and acknowledged
+func EnvelopeHandler(handler event.Handler) Handler {
+	return func(ctx context.Context, d *amqp.Delivery) error {
+		var env event.Envelope
+		if err := json.Unmarshal(d.Body, &env); err != nil {
+			logger.Named("rabbitmq").ErrorContext(ctx, "skipping malformed event",
+				"exchange", d.Exchange, "routing_key", d.RoutingKey, "message_id", d.MessageId, "error", err)
+			return nil
+		}
+		if env.Topic == "" {
+			env.Topic = d.RoutingKey
+		}
+		return handler(event.HandlerContext(ctx, env), env)
+	}
+}
+
+// RequeuePolicy decides what happens to a delivery whose handler failed
+type RequeuePolicy int
+
+const (
+	// RequeueOnce requeues a failed delivery the first time and rejects it if it fails again after redelivery
+	RequeueOnce RequeuePolicy = iota
+	// RequeueAlways requeues failed deliveries until they succeed
+	RequeueAlways
+	// RequeueNever rejects failed deliveries, which go to the queue dead-letter exchange if it has one
+	RequeueNever
+)
+
+// requeue reports whether a failed delivery goes back to the queue
+func (p RequeuePolicy) requeue(d *amqp.Delivery) bool {
+	switch p {
+	case RequeueAlways:
+		return true
+	case RequeueNever:
+		return false
+	default:
+		return !d.Redelivered
+	}
+}
+
+// ConsumerOption configures a Consumer
+type ConsumerOption func(*Consumer)
+
+// WithPrefetch sets how many unacknowledged deliveries the broker sends to the consumer (default 10)
+func WithPrefetch(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.prefetch = n
+	}
+}
+
+// WithConcurrency sets how many deliveries are handled at the same time (default 1)
+// Deliveries are handled out of order when it is greater than 1
+func WithConcurrency(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.concurrency = n
+	}
+}
+
+// WithRequeue sets what happens to failed deliveries (default RequeueOnce)
+func WithRequeue(policy RequeuePolicy) ConsumerOption {
+	return func(c *Consumer) {
+		c.requeue = policy
+	}
+}
+
+// WithHandlerTimeout bounds the duration of a single handler call (default 0, unbounded)
+func WithHandlerTimeout(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.timeout = d
+	}
+}
+
+// Consumer runs a handler for the deliveries of a queue, consuming again on a new channel after connection losses
+// Deliveries are acknowledged after the handler succeeded, so delivery is at-least-once
+type Consumer struct {
+	conn    *Connection
+	queue   string
+	handler Handler
+
+	prefetch    int
+	concurrency int
+	requeue     RequeuePolicy
+	timeout     time.Duration
+}
+
+// NewConsumer creates a consumer of queue
+func NewConsumer(conn *Connection, queue string, handler Handler, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		conn:        conn,
+		queue:       queue,
+		handler:     handler,
+		prefetch:    10,
+		concurrency: 1,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.concurrency < 1 {
+		c.concurrency = 1
+	}
+	if c.prefetch < c.concurrency {
+		c.prefetch = c.concurrency
+	}
+	return c
+}
+
+// Name implements worker.Worker
+func (c *Consumer) Name() string {
+	return "rabbitmq-consumer-" + c.queue
+}
+
+// Run consumes until ctx is canceled, then waits for the deliveries being handled
+func (c *Consumer) Run(ctx context.Context) error {
+	log := logger.Named("rabbitmq").With("queue", c.queue)
+	for ctx.Err() == nil {
+		err := c.consume(ctx)
+		if errors.Is(err, ErrClosed) {
+			return err
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Error("consumer stopped, consuming again", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+	return nil
+}
+
+// consume handles deliveries on a new channel until it is closed or ctx is canceled
+func (c *Consumer) consume(ctx context.Context) error {
+	ch, err := c.conn.Channel(ctx)
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err := ch.Qos(c.prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	tag := c.Name() + "-" + uuid.NewString()[:8]
+	deliveries, err := ch.ConsumeWithContext(ctx, c.queue, tag, false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume %s: %w", c.queue, err)
+	}
+
+	// Deliveries in progress finish on a context that is not canceled with ctx and are acknowledged
+	// before the channel is closed, undelivered prefetched messages are requeued by the broker
+	handlerCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				c.process(handlerCtx, &d)
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return errors.New("delivery channel closed")
+}
+
+// process runs the handler and acknowledges or rejects the delivery
+func (c *Consumer) process(ctx context.Context, d *amqp.Delivery) {
+	err := c.handle(ctx, d)
+
+	var settleErr error
+	if err == nil {
+		settleErr = d.Ack(false)
+	} else {
+		requeue := c.requeue.requeue(d)
+		logger.Named("rabbitmq").ErrorContext(ctx, "message handler failed",
+			"queue", c.queue, "message_id", d.MessageId, "redelivered", d.Redelivered, "requeue", requeue, "error", err)
+		settleErr = d.Nack(false, requeue)
+	}
+	if settleErr != nil {
+		// The channel is gone, the broker redelivers the message to another consumer
+		logger.Named("rabbitmq").WarnContext(ctx, "failed to settle delivery", "queue", c.queue, "message_id", d.MessageId, "error", settleErr)
+	}
+}
+
+// handle calls the handler with the handler timeout, reporting panics as errors
+func (c *Consumer) handle(ctx context.Context, d *amqp.Delivery) (err error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	defer utils.RecoverAndReport(ctx, c.Name(), &err)
+	return c.handler(ctx, d)
+}
@@ -0,0 +1,126 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/event"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrNacked is returned when the broker refuses to take responsibility for a published message
+var ErrNacked = errors.New("rabbitmq publish nacked by broker")
+
+// Publisher publishes persistent messages on a confirm mode channel, every publish waits for the broker confirm
+// The channel is reopened after connection or channel errors and the publish attempted once more
+type Publisher struct {
+	conn     *Connection
+	exchange string
+
+	mu sync.Mutex
+	ch *amqp.Channel
+}
+
+// NewPublisher creates a publisher whose Publish sends to exchange, routed by the topic
+func NewPublisher(conn *Connection, exchange string) *Publisher {
+	return &Publisher{conn: conn, exchange: exchange}
+}
+
+// channel returns the confirm mode channel, opening it if needed
+func (p *Publisher) channel(ctx context.Context) (*amqp.Channel, error) {
+	if p.ch != nil && !p.ch.IsClosed() {
+		return p.ch, nil
+	}
+
+	ch, err := p.conn.Channel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	p.ch = ch
+	return ch, nil
+}
+
+// PublishMessage publishes msg on exchange with routing key and waits for the broker confirm
+// Messages are persistent unless msg.DeliveryMode is set
+func (p *Publisher) PublishMessage(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	if msg.DeliveryMode == 0 {
+		msg.DeliveryMode = amqp.Persistent
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var ch *amqp.Channel
+		ch, err = p.channel(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", exchange, err)
+		}
+
+		var confirm *amqp.DeferredConfirmation
+		confirm, err = ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+		if err == nil {
+			var acked bool
+			acked, err = confirm.WaitContext(ctx)
+			if err == nil && !acked {
+				return fmt.Errorf("failed to publish to %s: %w", exchange, ErrNacked)
+			}
+		}
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+
+		// The channel may be unusable after an error, the message is published again on a new one
+		ch.Close()
+		p.ch = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", exchange, err)
+	}
+	return nil
+}
+
+// Publish publishes an event bus envelope as JSON on the publisher exchange with topic as routing key
+// Publisher implements event.Publisher, e.g. to publish with event.Publish or dead-letter with event.DeadLetter
+func (p *Publisher) Publish(ctx context.Context, topic string, env event.Envelope) error {
+	env = event.Prepare(ctx, topic, env)
+	body, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", env.ID, err)
+	}
+
+	return p.PublishMessage(ctx, p.exchange, topic, amqp.Publishing{
+		ContentType: "application/json",
+		MessageId:   env.ID,
+		Type:        env.Type,
+		Timestamp:   env.OccurredAt,
+		Body:        body,
+	})
+}
+
+// Close closes the publisher channel
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ch == nil {
+		return nil
+	}
+	err := p.ch.Close()
+	p.ch = nil
+	if err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return fmt.Errorf("failed to close publisher channel: %w", err)
+	}
+	return nil
+}
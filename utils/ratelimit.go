@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitResult is the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // until the oldest request in the window expires
+}
+
+// RateLimiter decides whether a request identified by key may proceed
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+// slidingWindowScript keeps the timestamps of the accepted requests of the window in a sorted set
+// It uses the Redis clock so instances with skewed clocks share the same window
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+// RedisRateLimiter is a sliding window rate limiter shared by all instances through Redis
+type RedisRateLimiter struct {
+	client redis.Cmdable
+	limit  int
+	window time.Duration
+	prefix string
+}
+
+// NewRedisRateLimiter allows limit requests per key in any window of the given duration
+func NewRedisRateLimiter(client redis.Cmdable, limit int, window time.Duration) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		limit:  limit,
+		window: window,
+		prefix: "ratelimit:",
+	}
+}
+
+// Allow records the request if it is within the limit
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	values, err := slidingWindowScript.Run(ctx, l.client, []string{l.prefix + key},
+		l.window.Milliseconds(), l.limit, uuid.NewString()).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	remaining := l.limit - int(values[1])
+	if remaining < 0 {
+		remaining = 0
+	}
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      l.limit,
+		Remaining:  remaining,
+		ResetAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
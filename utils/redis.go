@@ -2,33 +2,167 @@ package utils
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
 	"github.com/redis/go-redis/v9"
 )
 
+// Redis deployment modes supported by InitRedisUniversal
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+// RedisConfig holds the Redis connection settings, zero values use the go-redis defaults
 type RedisConfig struct {
 	Host     string
 	Port     string
+	Username string // ACL user, empty uses the default user
 	Password string
 	DB       int
+
+	TLS                   bool
+	TLSServerName         string // defaults to Host, or to the host of each node when Addrs is set
+	TLSInsecureSkipVerify bool   // only for development against self-signed certificates
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolSize     int // maximum connections, defaults to 10 per CPU
+	MinIdleConns int
+
+	Mode             string   // RedisModeStandalone (default), RedisModeSentinel or RedisModeCluster
+	Addrs            []string // sentinel or cluster node addresses (host:port), defaults to Host:Port
+	MasterName       string   // name of the master monitored by the sentinels
+	SentinelPassword string
+}
+
+// addrs returns the node addresses of cfg
+func (cfg RedisConfig) addrs() []string {
+	if len(cfg.Addrs) > 0 {
+		return cfg.Addrs
+	}
+	return []string{fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)}
+}
+
+// tlsConfig returns the TLS settings of cfg, nil when TLS is disabled
+func (cfg RedisConfig) tlsConfig() *tls.Config {
+	if !cfg.TLS {
+		return nil
+	}
+
+	// Without a server name each node is verified against the host of its address, which sentinel and cluster
+	// nodes need since they do not share the certificate name of Host
+	serverName := cfg.TLSServerName
+	if serverName == "" && len(cfg.Addrs) == 0 {
+		serverName = cfg.Host
+	}
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
 }
 
-// InitRedis initializes a Redis client
-func InitRedis(cfg RedisConfig) *redis.Client {
+// InitRedis initializes a Redis client and pings it, the client is returned along with the error
+// so callers may keep running and rely on go-redis reconnecting once Redis is reachable
+func InitRedis(cfg RedisConfig) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
+		Addr:         fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		TLSConfig:    cfg.tlsConfig(),
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
 	})
 
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := RedisHealthCheck(ctx, client); err != nil {
+		return client, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	logger.Default().InfoContext(ctx, "connected to redis")
+	return client, nil
+}
+
+// InitRedisUniversal initializes a standalone, Sentinel or Cluster client depending on cfg.Mode and pings it
+// Like InitRedis the client is returned along with the error
+func InitRedisUniversal(cfg RedisConfig) (redis.UniversalClient, error) {
+	var client redis.UniversalClient
+	switch cfg.Mode {
+	case "", RedisModeStandalone:
+		return InitRedis(cfg)
+	case RedisModeSentinel:
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("redis master name is required in %s mode", cfg.Mode)
+		}
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.addrs(),
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			TLSConfig:        cfg.tlsConfig(),
+			DialTimeout:      cfg.DialTimeout,
+			ReadTimeout:      cfg.ReadTimeout,
+			WriteTimeout:     cfg.WriteTimeout,
+			PoolSize:         cfg.PoolSize,
+			MinIdleConns:     cfg.MinIdleConns,
+		})
+	case RedisModeCluster:
+		// Cluster mode has a single database, cfg.DB is ignored
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.addrs(),
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			TLSConfig:    cfg.tlsConfig(),
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+		})
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := RedisHealthCheck(ctx, client); err != nil {
+		return client, fmt.Errorf("failed to connect to redis %s: %w", cfg.Mode, err)
+	}
+
+	logger.Default().InfoContext(ctx, "connected to redis", slog.String("mode", cfg.Mode))
+	return client, nil
+}
+
+// RedisHealthCheck pings Redis, for readiness probes
+func RedisHealthCheck(ctx context.Context, client redis.Cmdable) error {
 	if err := client.Ping(ctx).Err(); err != nil {
-		log.Printf("Warning: Failed to connect to Redis: %v", err)
-		return client
+		return fmt.Errorf("redis health check failed: %w", err)
 	}
+	return nil
+}
+
+var globalRedisClient redis.UniversalClient
+
+// SetGlobalRedisClient sets the Redis client used by helpers such as middleware.RateLimit
+func SetGlobalRedisClient(client redis.UniversalClient) {
+	globalRedisClient = client
+}
 
-	log.Println("Redis connected successfully")
-	return client
+// GetGlobalRedisClient returns the global Redis client, nil if not set
+func GetGlobalRedisClient() redis.UniversalClient {
+	return globalRedisClient
 }
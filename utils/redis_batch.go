@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisBatchSize bounds the number of commands sent in a single pipeline
+const redisBatchSize = 500
+
+// MGetJSON returns the JSON decoded values of the existing keys, missing keys are absent from the map
+// Keys are fetched with pipelined GETs rather than MGET so keys of different cluster slots can be mixed
+func MGetJSON[T any](ctx context.Context, client redis.UniversalClient, keys ...string) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	for start := 0; start < len(keys); start += redisBatchSize {
+		batch := keys[start:min(start+redisBatchSize, len(keys))]
+
+		cmds := make([]*redis.StringCmd, len(batch))
+		_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range batch {
+				cmds[i] = pipe.Get(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("failed to get keys: %w", err)
+		}
+
+		for i, cmd := range cmds {
+			data, err := cmd.Bytes()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get key %s: %w", batch[i], err)
+			}
+
+			var value T
+			if err := json.Unmarshal(data, &value); err != nil {
+				return nil, fmt.Errorf("failed to decode key %s: %w", batch[i], err)
+			}
+			values[batch[i]] = value
+		}
+	}
+	return values, nil
+}
+
+// MSetJSON JSON encodes and stores every value for ttl with pipelined SETs, a zero ttl keeps them until deleted
+func MSetJSON[T any](ctx context.Context, client redis.UniversalClient, values map[string]T, ttl time.Duration) error {
+	encoded := make(map[string][]byte, len(values))
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode key %s: %w", key, err)
+		}
+		encoded[key] = data
+		keys = append(keys, key)
+	}
+
+	for start := 0; start < len(keys); start += redisBatchSize {
+		batch := keys[start:min(start+redisBatchSize, len(keys))]
+		_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range batch {
+				pipe.Set(ctx, key, encoded[key], ttl)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set keys: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteByPattern deletes the keys matching a glob pattern and returns how many were deleted
+// Keys are found with SCAN and unlinked in pipelined batches of batchSize (500 when <= 0), so Redis is never
+// blocked like with KEYS. On a cluster every master is scanned. Keys created during the scan may be missed
+func DeleteByPattern(ctx context.Context, client redis.UniversalClient, pattern string, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = redisBatchSize
+	}
+
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		var deleted atomic.Int64
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, shard *redis.Client) error {
+			n, err := scanAndDelete(ctx, shard, pattern, batchSize)
+			deleted.Add(n)
+			return err
+		})
+		return deleted.Load(), err
+	}
+	return scanAndDelete(ctx, client, pattern, batchSize)
+}
+
+// scanAndDelete runs DeleteByPattern on a single node
+func scanAndDelete(ctx context.Context, client redis.Cmdable, pattern string, batchSize int) (int64, error) {
+	var deleted int64
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, pattern, int64(batchSize)).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to scan %s: %w", pattern, err)
+		}
+
+		if len(keys) > 0 {
+			cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, key := range keys {
+					pipe.Unlink(ctx, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return deleted, fmt.Errorf("failed to delete keys matching %s: %w", pattern, err)
+			}
+			for _, cmd := range cmds {
+				deleted += cmd.(*redis.IntCmd).Val()
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// RequestIDHeader is the header carrying the request ID between services
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDContextKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, empty if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// RequestIDTransport forwards the request ID of the request context as the X-Request-ID header
+type RequestIDTransport struct {
+	Base http.RoundTripper // http.DefaultTransport when nil
+}
+
+// NewRequestIDTransport wraps base so outgoing calls carry the request ID of their context
+func NewRequestIDTransport(base http.RoundTripper) *RequestIDTransport {
+	return &RequestIDTransport{Base: base}
+}
+
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	requestID := RequestIDFromContext(req.Context())
+	if requestID == "" || req.Header.Get(RequestIDHeader) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, requestID)
+	return base.RoundTrip(req)
+}
+
+// requestIDHandler adds the request ID of the context to every record
+type requestIDHandler struct {
+	slog.Handler
+}
+
+// NewRequestIDHandler wraps h so records logged with a context (InfoContext, ...) carry its request_id
+func NewRequestIDHandler(h slog.Handler) slog.Handler {
+	return requestIDHandler{Handler: h}
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,70 @@
+package response
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem documents
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem document, code, request_id and errors are extension members
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      string      `json:"code"`
+	RequestID string      `json:"request_id,omitempty"`
+	Errors    interface{} `json:"errors,omitempty"`
+}
+
+// problemTypeBase prefixes the code of an error to build its problem type URI
+var problemTypeBase string
+
+// SetProblemTypeBase makes problem types resolvable URIs, e.g. https://docs.example.com/errors/ gives
+// https://docs.example.com/errors/not_found. Without a base the type is about:blank
+func SetProblemTypeBase(base string) {
+	problemTypeBase = base
+}
+
+// ProblemResponse returns the status and problem document of err, built like ErrorResponse
+// instance identifies the occurrence, usually the request path
+func ProblemResponse(ctx context.Context, err error, instance string) (int, Problem) {
+	status, body := ErrorResponse(ctx, err)
+
+	problemType := "about:blank"
+	if problemTypeBase != "" {
+		problemType = problemTypeBase + strings.ToLower(body.Code)
+	}
+	return status, Problem{
+		Type:      problemType,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    body.Error,
+		Instance:  instance,
+		Code:      body.Code,
+		RequestID: body.RequestID,
+		Errors:    body.Details,
+	}
+}
+
+// WantsProblem reports whether the Accept header of r lists application/problem+json before plain JSON
+func WantsProblem(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case ProblemContentType:
+			return true
+		case "application/json", "*/*":
+			return false
+		}
+	}
+	return false
+}
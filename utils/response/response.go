@@ -0,0 +1,136 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gadhittana01/go-modules-v3/repository/pagination"
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// Envelope is the shape of successful responses
+type Envelope struct {
+	Data interface{} `json:"data"`
+	Meta interface{} `json:"meta,omitempty"`
+}
+
+// ErrorBody is the shape of error responses, "error" holds the message for clients reading it as a string
+type ErrorBody struct {
+	Error     string      `json:"error"`
+	Code      string      `json:"code"`
+	RequestID string      `json:"request_id,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// PageMeta is the meta of paginated responses
+type PageMeta struct {
+	Total      *int64 `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// JSON writes data in the envelope with status
+func JSON(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, Envelope{Data: data})
+}
+
+// OK writes data in the envelope with 200
+func OK(c *gin.Context, data interface{}) {
+	JSON(c, http.StatusOK, data)
+}
+
+// Created writes the created resource in the envelope with 201
+func Created(c *gin.Context, data interface{}) {
+	JSON(c, http.StatusCreated, data)
+}
+
+// NoContent writes an empty 204 response
+func NoContent(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// Paginated writes the items of a page as data and the pagination state as meta
+func Paginated[T any](c *gin.Context, result pagination.PagedResult[T]) {
+	c.JSON(http.StatusOK, Envelope{
+		Data: result.Items,
+		Meta: PageMeta{
+			Total:      result.Total,
+			Page:       result.Page,
+			Limit:      result.Limit,
+			NextCursor: result.NextCursor,
+			HasMore:    result.HasMore,
+		},
+	})
+}
+
+// Error aborts the request with the error envelope of err (see ErrorResponse), or with a problem
+// document when the client accepts application/problem+json
+func Error(c *gin.Context, err error) {
+	var status int
+	var body interface{}
+	if WantsProblem(c.Request) {
+		status, body = ProblemResponse(c.Request.Context(), err, c.Request.URL.Path)
+		c.Header("Content-Type", ProblemContentType)
+	} else {
+		status, body = ErrorResponse(c.Request.Context(), err)
+	}
+
+	if status >= http.StatusInternalServerError && err != nil {
+		c.Error(err) // reported by the recovery middleware
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// WriteError is Error for net/http handlers
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var status int
+	var body interface{}
+	if WantsProblem(r) {
+		status, body = ProblemResponse(r.Context(), err, r.URL.Path)
+		w.Header().Set("Content-Type", ProblemContentType)
+	} else {
+		status, body = ErrorResponse(r.Context(), err)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// ErrorResponse returns the status and body of err: the status, message and code of a CustomError,
+// or 500 with a generic message so internal details never reach clients. The message is translated
+// with i18n.T into the locale of ctx and the request ID of ctx is attached. Server errors are logged
+func ErrorResponse(ctx context.Context, err error) (int, ErrorBody) {
+	status, body := http.StatusInternalServerError, ErrorBody{Error: "Internal server error"}
+	var customErr *utils.CustomError
+	if errors.As(err, &customErr) {
+		status, body.Error, body.Code = customErr.StatusCode, customErr.Message, customErr.Code
+		body.Details = customErr.Details
+	}
+	body.Error = i18n.T(ctx, body.Error)
+	if body.Code == "" {
+		body.Code = statusCode(status)
+	}
+	body.RequestID = utils.RequestIDFromContext(ctx)
+
+	if status >= http.StatusInternalServerError {
+		log.Printf("Request %s failed: %v", body.RequestID, err)
+	}
+	return status, body
+}
+
+// statusCode derives a code from the status text, e.g. 404 becomes NOT_FOUND
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "ERROR"
+	}
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"context"
+
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+)
+
+// RetryPolicy configures retries with exponential backoff and jitter, see retry.Policy
+type RetryPolicy = retry.Policy
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return retry.DefaultPolicy()
+}
+
+// NoRetryPolicy returns a policy that performs a single attempt without delay
+func NoRetryPolicy() RetryPolicy {
+	return retry.NoRetry()
+}
+
+// Retry is retry.Do classifying errors with IsRetryable, so only transient failures are retried
+// Options passed after the default override it, e.g. retry.RetryIf for a custom classification
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error, opts ...retry.Option) error {
+	return retry.Do(ctx, policy, fn, append([]retry.Option{retry.RetryIf(IsRetryable)}, opts...)...)
+}
@@ -0,0 +1,205 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy configures retries with exponential backoff and jitter
+type Policy struct {
+	MaxAttempts    int           // total attempts including the first one, values < 1 mean a single attempt
+	Backoff        time.Duration // delay before the second attempt
+	MaxBackoff     time.Duration // upper bound for the delay between attempts, 0 means unbounded
+	Multiplier     float64       // growth factor applied to the delay after each attempt, defaults to 2
+	Jitter         float64       // fraction of the delay randomized in both directions (0.2 = ±20%)
+	AttemptTimeout time.Duration // timeout applied to each attempt, 0 means no per-attempt timeout
+	MaxElapsedTime time.Duration // no attempt starts later than this after the first one, 0 means unbounded
+}
+
+// DefaultPolicy returns the policy used when none is configured
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:    3,
+		Backoff:        200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		AttemptTimeout: 30 * time.Second,
+	}
+}
+
+// NoRetry returns a policy that performs a single attempt without delay
+func NoRetry() Policy {
+	return Policy{MaxAttempts: 1}
+}
+
+// Attempts returns the number of attempts allowed by the policy
+func (p Policy) Attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// Delay returns the delay to wait after the given (zero-based) attempt failed
+func (p Policy) Delay(attempt int) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	delay := float64(p.Backoff) * math.Pow(multiplier, float64(attempt))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if delay < 0 {
+		return 0
+	}
+	return time.Duration(delay)
+}
+
+// Option customizes a Do call
+type Option func(*options)
+
+type options struct {
+	retryIf func(err error) bool
+	onRetry func(attempt int, err error, delay time.Duration)
+}
+
+// RetryIf sets the classification of errors worth retrying
+// The default retries every error except those marked with MarkPermanent: this package sits below the drivers
+// and cannot tell transient errors apart, utils.Retry classifies them with utils.IsRetryable instead
+func RetryIf(fn func(err error) bool) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.retryIf = fn
+		}
+	}
+}
+
+// OnRetry sets a hook called before waiting to retry, with the zero-based attempt that failed
+func OnRetry(fn func(attempt int, err error, delay time.Duration)) Option {
+	return func(o *options) {
+		o.onRetry = fn
+	}
+}
+
+// Do runs fn until it succeeds, the error is not retryable, attempts or the elapsed time are exhausted
+// or ctx is done, and returns the last error of fn
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error, opts ...Option) error {
+	o := options{retryIf: func(err error) bool { return !IsPermanent(err) }}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var err error
+	start := time.Now()
+	attempts := policy.Attempts()
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		err = runAttempt(ctx, policy.AttemptTimeout, fn)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil || !o.retryIf(err) || attempt == attempts-1 {
+			return err
+		}
+
+		delay := policy.Delay(attempt)
+		if policy.MaxElapsedTime > 0 && time.Since(start)+delay > policy.MaxElapsedTime {
+			return err
+		}
+		if o.onRetry != nil {
+			o.onRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+
+	return err
+}
+
+// DoValue is Do for functions returning a value
+func DoValue[T any](ctx context.Context, policy Policy, fn func(ctx context.Context) (T, error), opts ...Option) (T, error) {
+	var result T
+	err := Do(ctx, policy, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	}, opts...)
+	return result, err
+}
+
+// runAttempt runs a single attempt, applying the per-attempt timeout if configured
+func runAttempt(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(attemptCtx)
+}
+
+// Retryable is implemented by errors that know whether the failed operation may be retried
+type Retryable interface {
+	Retryable() bool
+}
+
+// markedError marks a wrapped error as retryable or permanent
+type markedError struct {
+	err   error
+	retry bool
+}
+
+func (e *markedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *markedError) Unwrap() error {
+	return e.err
+}
+
+func (e *markedError) Retryable() bool {
+	return e.retry
+}
+
+// MarkRetryable wraps err so it reports itself as retryable, e.g. for a transient failure of a remote API
+func MarkRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &markedError{err: err, retry: true}
+}
+
+// MarkPermanent wraps err so it is never retried, e.g. a queue job with an invalid payload
+func MarkPermanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &markedError{err: err, retry: false}
+}
+
+// IsPermanent reports whether err was marked as not retryable with MarkPermanent or a Retryable error
+func IsPermanent(err error) bool {
+	var marker Retryable
+	return errors.As(err, &marker) && !marker.Retryable()
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+)
+
+// Retryable is implemented by errors that know whether the failed operation may be retried
+type Retryable = retry.Retryable
+
+// MarkRetryable wraps err so IsRetryable reports true, e.g. for a transient failure of a remote API
+func MarkRetryable(err error) error {
+	return retry.MarkRetryable(err)
+}
+
+// MarkPermanent wraps err so it is never retried, e.g. a queue job with an invalid payload
+func MarkPermanent(err error) error {
+	return retry.MarkPermanent(err)
+}
+
+// IsPermanent reports whether err was marked as not retryable with MarkPermanent or a Retryable error
+func IsPermanent(err error) bool {
+	return retry.IsPermanent(err)
+}
+
+// IsRetryable reports whether err is transient: the outermost Retryable marker decides when there is one,
+// otherwise deadlines, network errors, Redis timeouts and failovers, Postgres serialization failures,
+// deadlocks and connection errors, and CustomErrors with 408, 429 or 5xx statuses are retryable
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var marker Retryable
+	if errors.As(err, &marker) {
+		return marker.Retryable()
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, redis.Nil):
+		return false
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return isRetryablePgCode(pgErr.Code)
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		for _, prefix := range []string{"LOADING", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN", "READONLY", "BUSY "} {
+			if redis.HasErrorPrefix(err, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var customErr *CustomError
+	if errors.As(err, &customErr) {
+		status := customErr.StatusCode
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || status == http.StatusRequestTimeout
+	}
+	return false
+}
+
+// isRetryablePgCode reports whether a SQLSTATE is transient: serialization failures, deadlocks,
+// lock timeouts, connection exceptions (class 08), too many connections and server shutdowns
+func isRetryablePgCode(code string) bool {
+	switch code {
+	case "40001", "40P01", "55P03", "53300", "57P01", "57P02", "57P03":
+		return true
+	}
+	return len(code) == 5 && code[:2] == "08"
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+)
+
+// Runner runs HTTP servers and background tasks until SIGINT or SIGTERM, then shuts everything down gracefully:
+// drain hooks run first (e.g. failing readiness), then servers stop accepting requests and finish in-flight
+// ones, then background tasks are canceled and the shutdown hooks run in reverse registration order
+type Runner struct {
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+
+	servers  []*http.Server
+	tasks    []func(ctx context.Context) error
+	drains   []func()
+	shutdown []func(ctx context.Context) error
+}
+
+// RunnerOption configures a Runner
+type RunnerOption func(*Runner)
+
+// WithShutdownTimeout bounds the whole shutdown (default 20s)
+func WithShutdownTimeout(timeout time.Duration) RunnerOption {
+	return func(r *Runner) {
+		r.shutdownTimeout = timeout
+	}
+}
+
+// WithDrainDelay waits after the drain hooks so load balancers notice the failing readiness before
+// the servers stop accepting connections (default 0, a few seconds on Kubernetes)
+func WithDrainDelay(delay time.Duration) RunnerOption {
+	return func(r *Runner) {
+		r.drainDelay = delay
+	}
+}
+
+// NewRunner creates an empty runner
+func NewRunner(opts ...RunnerOption) *Runner {
+	r := &Runner{shutdownTimeout: 20 * time.Second}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// AddServer serves srv, see NewHTTPServer
+func (r *Runner) AddServer(srv *http.Server) {
+	r.servers = append(r.servers, srv)
+}
+
+// Go runs a background task until its context is canceled at shutdown, e.g. a queue worker
+// A task returning an error other than context.Canceled stops the runner
+func (r *Runner) Go(task func(ctx context.Context) error) {
+	r.tasks = append(r.tasks, task)
+}
+
+// OnDrain registers a hook called as soon as shutdown starts, e.g. health.Registry.Drain
+func (r *Runner) OnDrain(hook func()) {
+	r.drains = append(r.drains, hook)
+}
+
+// OnShutdown registers a hook called after the servers and tasks stopped, e.g. closing the database pool
+func (r *Runner) OnShutdown(hook func(ctx context.Context) error) {
+	r.shutdown = append(r.shutdown, hook)
+}
+
+// Run blocks until ctx is canceled, a signal is received or a server or task fails, then shuts down
+// It returns the error that stopped the runner joined with the shutdown errors
+func (r *Runner) Run(ctx context.Context) error {
+	ctx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	taskCtx, cancelTasks := context.WithCancel(context.Background())
+	defer cancelTasks()
+
+	errCh := make(chan error, len(r.servers)+len(r.tasks))
+	var tasks sync.WaitGroup
+	for _, srv := range r.servers {
+		go func(srv *http.Server) {
+			logger.Default().Info("http server listening", "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+			if err := ServeHTTPServer(srv); err != nil {
+				errCh <- fmt.Errorf("http server %s failed: %w", srv.Addr, err)
+			}
+		}(srv)
+	}
+	for _, task := range r.tasks {
+		tasks.Add(1)
+		go func(task func(ctx context.Context) error) {
+			defer tasks.Done()
+			if err := task(taskCtx); err != nil && !errors.Is(err, context.Canceled) {
+				errCh <- err
+			}
+		}(task)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logger.Default().Info("shutting down")
+	case runErr = <-errCh:
+		logger.Default().Error("shutting down after failure", "error", runErr)
+	}
+	stopSignals() // a second signal kills the process
+
+	return errors.Join(runErr, r.stop(cancelTasks, &tasks))
+}
+
+// stop drains, shuts the servers down, cancels the tasks and runs the shutdown hooks within the timeout
+func (r *Runner) stop(cancelTasks context.CancelFunc, tasks *sync.WaitGroup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.shutdownTimeout)
+	defer cancel()
+
+	for _, hook := range r.drains {
+		hook()
+	}
+	if r.drainDelay > 0 {
+		select {
+		case <-time.After(r.drainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	var errs []error
+	var servers sync.WaitGroup
+	var mu sync.Mutex
+	for _, srv := range r.servers {
+		servers.Add(1)
+		go func(srv *http.Server) {
+			defer servers.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("failed to shut down http server %s: %w", srv.Addr, err))
+				mu.Unlock()
+			}
+		}(srv)
+	}
+	servers.Wait()
+
+	cancelTasks()
+	done := make(chan struct{})
+	go func() {
+		tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, errors.New("background tasks did not stop before the shutdown timeout"))
+	}
+
+	for i := len(r.shutdown) - 1; i >= 0; i-- {
+		if err := r.shutdown[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ScanResult holds the outcome of a malware scan
+type ScanResult struct {
+	Clean     bool
+	Signature string // name of the detected threat when not clean
+}
+
+// Scanner defines the interface for malware scanning of uploaded content
+type Scanner interface {
+	Scan(ctx context.Context, content io.Reader) (ScanResult, error)
+}
+
+// ClamAVScanner implements Scanner using the clamd INSTREAM protocol
+type ClamAVScanner struct {
+	address   string
+	timeout   time.Duration
+	chunkSize int
+}
+
+// NewClamAVScanner creates a new clamd scanner, address is a host:port TCP address
+func NewClamAVScanner(address string, timeout time.Duration) Scanner {
+	return &ClamAVScanner{
+		address:   address,
+		timeout:   timeout,
+		chunkSize: 64 * 1024,
+	}
+}
+
+// Scan streams the content to clamd and parses the verdict
+func (s *ClamAVScanner) Scan(ctx context.Context, content io.Reader) (ScanResult, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	// Use the shorter of the scanner timeout and the context deadline
+	deadline, ok := ctx.Deadline()
+	if s.timeout > 0 && (!ok || time.Until(deadline) > s.timeout) {
+		deadline, ok = time.Now().Add(s.timeout), true
+	}
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return ScanResult{}, fmt.Errorf("failed to start clamd stream: %w", err)
+	}
+
+	// Each chunk is prefixed with its length as a 4-byte big-endian integer, a zero length ends the stream
+	buf := make([]byte, s.chunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return ScanResult{}, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return ScanResult{}, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return ScanResult{}, fmt.Errorf("failed to read content: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return ScanResult{}, fmt.Errorf("failed to end clamd stream: %w", err)
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseClamdReply parses replies such as "stream: OK" or "stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (ScanResult, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return ScanResult{Clean: true}, nil
+	case strings.HasSuffix(verdict, "FOUND"):
+		return ScanResult{Signature: strings.TrimSpace(strings.TrimSuffix(verdict, "FOUND"))}, nil
+	default:
+		return ScanResult{}, fmt.Errorf("clamd scan failed: %s", reply)
+	}
+}
+
+// WithScanner scans every upload before it is stored, infected files are rejected with a 422 CustomError
+func WithScanner(scanner Scanner) StorageOption {
+	return func(s *S3StorageClient) {
+		s.scanner = scanner
+	}
+}
+
+// scanContent runs the configured scanner on the content, if any
+func (s *S3StorageClient) scanContent(ctx context.Context, content []byte) error {
+	if s.scanner == nil {
+		return nil
+	}
+
+	result, err := s.scanner.Scan(ctx, bytes.NewReader(content))
+	if err != nil {
+		return NewCustomErrorWithTrace(err, "failed to scan file", http.StatusServiceUnavailable)
+	}
+	if !result.Clean {
+		return NewCustomError(fmt.Sprintf("file rejected by malware scan: %s", result.Signature), http.StatusUnprocessableEntity)
+	}
+	return nil
+}
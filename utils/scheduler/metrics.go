@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics are the Prometheus collectors of a Scheduler, a nil *metrics records nothing
+type metrics struct {
+	runs        *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	lastSuccess *prometheus.GaugeVec
+}
+
+// newMetrics registers the scheduler collectors with registerer
+func newMetrics(registerer prometheus.Registerer) *metrics {
+	return &metrics{
+		runs: utils.RegisterCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scheduler_job_runs_total",
+			Help: "Total number of scheduled job occurrences by job and status (success, failure, skipped, error).",
+		}, []string{"job", "status"})),
+		duration: utils.RegisterCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scheduler_job_duration_seconds",
+			Help:    "Duration of scheduled job runs in seconds.",
+			Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 3600},
+		}, []string{"job"})),
+		lastSuccess: utils.RegisterCollector(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "scheduler_job_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run of the job on this replica.",
+		}, []string{"job"})),
+	}
+}
+
+// observe records an occurrence, elapsed is ignored for occurrences that did not run here
+func (m *metrics) observe(job, status string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.runs.WithLabelValues(job, status).Inc()
+	if status != "success" && status != "failure" {
+		return
+	}
+	m.duration.WithLabelValues(job).Observe(elapsed.Seconds())
+	if status == "success" {
+		m.lastSuccess.WithLabelValues(job).SetToCurrentTime()
+	}
+}
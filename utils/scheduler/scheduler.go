@@ -0,0 +1,295 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// keyPrefix prefixes the Redis keys of the scheduler
+const keyPrefix = "cron:"
+
+// Job is a scheduled task, its name identifies it across replicas
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// funcJob adapts a function to Job
+type funcJob struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func (j funcJob) Name() string {
+	return j.name
+}
+
+func (j funcJob) Run(ctx context.Context) error {
+	return j.run(ctx)
+}
+
+// NewJob creates a job from a function
+func NewJob(name string, run func(ctx context.Context) error) Job {
+	return funcJob{name: name, run: run}
+}
+
+// MissedRuns tells what to do with occurrences missed while no replica was running
+type MissedRuns int
+
+const (
+	SkipMissed    MissedRuns = iota // wait for the next occurrence
+	RunMissedOnce                   // run once at startup when at least one occurrence was missed
+)
+
+// JobOption configures a registered job
+type JobOption func(*entry)
+
+// WithTimeout bounds each run (default: until the next occurrence, at most 1h)
+func WithTimeout(timeout time.Duration) JobOption {
+	return func(e *entry) {
+		e.timeout = timeout
+	}
+}
+
+// WithMissedRuns sets the missed run handling (default SkipMissed)
+func WithMissedRuns(policy MissedRuns) JobOption {
+	return func(e *entry) {
+		e.missed = policy
+	}
+}
+
+// Option configures a Scheduler
+type Option func(*Scheduler)
+
+// WithLocation evaluates cron expressions in loc (default UTC)
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scheduler) {
+		s.location = loc
+	}
+}
+
+// WithMetrics registers the job metrics with registerer
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(s *Scheduler) {
+		s.metrics = newMetrics(registerer)
+	}
+}
+
+// entry is a registered job
+type entry struct {
+	spec     string
+	schedule cron.Schedule
+	job      Job
+	timeout  time.Duration
+	missed   MissedRuns
+}
+
+// Scheduler runs jobs on cron schedules, each occurrence on exactly one replica: replicas race for a Redis key
+// unique to the job and the scheduled time, so clock skew between replicas cannot run an occurrence twice
+type Scheduler struct {
+	client   redis.Cmdable
+	location *time.Location
+	metrics  *metrics
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler coordinating replicas through client
+func New(client redis.Cmdable, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		client:   client,
+		location: time.UTC,
+		entries:  map[string]*entry{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register schedules job with a standard 5 field cron expression or a descriptor like @hourly or @every 10m
+// It must be called before Start
+func (s *Scheduler) Register(spec string, job Job, opts ...JobOption) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("failed to parse cron expression %q: %w", spec, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[job.Name()]; ok {
+		return fmt.Errorf("job %q registered twice", job.Name())
+	}
+
+	e := &entry{spec: spec, schedule: schedule, job: job}
+	for _, opt := range opts {
+		opt(e)
+	}
+	s.entries[job.Name()] = e
+	return nil
+}
+
+// Name implements worker.Worker
+func (s *Scheduler) Name() string {
+	return "scheduler"
+}
+
+// Run runs the jobs until ctx is canceled and waits for the running ones, for runner.Go or a worker.Manager
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.Start(ctx)
+	<-ctx.Done()
+	s.Stop()
+	return nil
+}
+
+// Start runs the jobs in the background until Stop is called or ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go func(e *entry) {
+			defer s.wg.Done()
+			s.loop(ctx, e)
+		}(e)
+	}
+}
+
+// Stop stops scheduling and waits for the running jobs to return
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	s.wg.Wait()
+}
+
+// loop waits for each occurrence of e and runs it
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	log := logger.Named("scheduler").With("job", e.job.Name())
+
+	if e.missed == RunMissedOnce {
+		if missed, ok := s.missedOccurrence(ctx, e); ok {
+			log.Info("running missed occurrence", "scheduled_at", missed)
+			s.runOccurrence(ctx, e, missed)
+		}
+	}
+
+	for {
+		next := e.schedule.Next(time.Now().In(s.location))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.runOccurrence(ctx, e, next)
+	}
+}
+
+// missedOccurrence returns the first occurrence after the last recorded run when it is already past
+func (s *Scheduler) missedOccurrence(ctx context.Context, e *entry) (time.Time, bool) {
+	value, err := s.client.Get(ctx, s.key(e, "last")).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logger.Named("scheduler").WarnContext(ctx, "failed to read last run", "job", e.job.Name(), "error", err)
+		}
+		return time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	missed := e.schedule.Next(time.Unix(unix, 0).In(s.location))
+	return missed, missed.Before(time.Now())
+}
+
+// runOccurrence runs the occurrence scheduled at when this replica wins it
+func (s *Scheduler) runOccurrence(ctx context.Context, e *entry, scheduledAt time.Time) {
+	name := e.job.Name()
+	log := logger.Named("scheduler").With("job", name, "scheduled_at", scheduledAt)
+
+	timeout := e.timeout
+	if timeout <= 0 {
+		timeout = time.Until(e.schedule.Next(scheduledAt))
+		if timeout <= 0 || timeout > time.Hour {
+			timeout = time.Hour
+		}
+	}
+
+	// The key outlives the run so replicas firing late cannot claim the occurrence again
+	won, err := s.client.SetNX(ctx, s.key(e, "run", strconv.FormatInt(scheduledAt.Unix(), 10)), token(), timeout+time.Minute).Result()
+	if err != nil {
+		log.ErrorContext(ctx, "failed to claim job occurrence", "error", err)
+		s.metrics.observe(name, "error", 0)
+		return
+	}
+	if !won {
+		s.metrics.observe(name, "skipped", 0)
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err = runJob(runCtx, e.job)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		log.ErrorContext(ctx, "job failed", "error", err, "duration", elapsed)
+		s.metrics.observe(name, "failure", elapsed)
+	} else {
+		log.InfoContext(ctx, "job completed", "duration", elapsed)
+		s.metrics.observe(name, "success", elapsed)
+	}
+
+	// Record the occurrence as run even on failure, the next occurrence retries the work
+	if err := s.client.Set(context.WithoutCancel(ctx), s.key(e, "last"), scheduledAt.Unix(), 0).Err(); err != nil {
+		log.WarnContext(ctx, "failed to record last run", "error", err)
+	}
+}
+
+// runJob runs job, converting a panic into an error reported to the global error reporter if any
+func runJob(ctx context.Context, job Job) (err error) {
+	defer utils.RecoverAndReport(ctx, "job "+job.Name(), &err)
+	return job.Run(ctx)
+}
+
+// key builds a Redis key of the job
+func (s *Scheduler) key(e *entry, parts ...string) string {
+	key := keyPrefix + e.job.Name()
+	for _, part := range parts {
+		key += ":" + part
+	}
+	return key
+}
+
+// token identifies the replica that claimed an occurrence, for debugging
+func token() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
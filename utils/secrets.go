@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// SecretsProvider fetches secrets from a secret store
+// path identifies the secret and key selects a field of it, an empty key returns the whole secret
+type SecretsProvider interface {
+	GetSecret(ctx context.Context, path, key string) (string, error)
+}
+
+var (
+	secretsMu        sync.RWMutex
+	secretsProviders = map[string]SecretsProvider{}
+)
+
+// RegisterSecretsProvider registers a provider for references of the form "<scheme>:<path>#<key>",
+// e.g. RegisterSecretsProvider("vault", vault) resolves "vault:secret/data/app#jwt_secret"
+func RegisterSecretsProvider(scheme string, provider SecretsProvider) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secretsProviders[scheme] = provider
+}
+
+// ResolveSecret resolves value if it is a reference to a registered provider, other values are returned unchanged
+func ResolveSecret(ctx context.Context, value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+
+	secretsMu.RLock()
+	provider, ok := secretsProviders[scheme]
+	secretsMu.RUnlock()
+	if !ok {
+		// Not a secret reference, e.g. a URL such as postgres://...
+		return value, nil
+	}
+
+	path, key, _ := strings.Cut(ref, "#")
+	secret, err := provider.GetSecret(ctx, path, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s:%s: %w", scheme, path, err)
+	}
+	return secret, nil
+}
+
+// ResolveSecrets replaces every string field of the struct pointed to by target (nested structs,
+// string slices and string maps included) holding a secret reference with the secret value
+func ResolveSecrets(ctx context.Context, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("secrets target must be a pointer to a struct, got %T", target)
+	}
+
+	var problems configProblems
+	resolveValue(ctx, v.Elem(), &problems)
+	return problems.err()
+}
+
+// resolveValue resolves secret references found in v
+func resolveValue(ctx context.Context, v reflect.Value, problems *configProblems) {
+	switch v.Kind() {
+	case reflect.String:
+		if !v.CanSet() {
+			return
+		}
+		resolved, err := ResolveSecret(ctx, v.String())
+		if err != nil {
+			problems.add("%v", err)
+			return
+		}
+		v.SetString(resolved)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				resolveValue(ctx, v.Field(i), problems)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			resolveValue(ctx, v.Index(i), problems)
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		for _, key := range v.MapKeys() {
+			resolved, err := ResolveSecret(ctx, v.MapIndex(key).String())
+			if err != nil {
+				problems.add("%v", err)
+				continue
+			}
+			v.SetMapIndex(key, reflect.ValueOf(resolved).Convert(v.Type().Elem()))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			resolveValue(ctx, v.Elem(), problems)
+		}
+	}
+}
+
+// selectSecretKey returns the whole secret for an empty key, otherwise the key of the JSON object secret
+func selectSecretKey(data map[string]interface{}, raw, key string) (string, error) {
+	if key == "" {
+		return raw, nil
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// VaultSecretsProvider reads secrets from HashiCorp Vault KV engines (v1 and v2) over the HTTP API
+type VaultSecretsProvider struct {
+	address string
+	token   string
+	client  *http.Client
+
+	mu    sync.Mutex
+	cache map[string]map[string]interface{}
+}
+
+// NewVaultSecretsProvider creates a Vault provider, address and token default to VAULT_ADDR and VAULT_TOKEN
+// For KV v2 the path includes the data segment, e.g. "secret/data/app"
+func NewVaultSecretsProvider(address, token string) *VaultSecretsProvider {
+	if address == "" {
+		address = GetEnv("VAULT_ADDR", "http://127.0.0.1:8200")
+	}
+	if token == "" {
+		token = GetEnv("VAULT_TOKEN", "")
+	}
+	return &VaultSecretsProvider{
+		address: strings.TrimRight(address, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		cache:   map[string]map[string]interface{}{},
+	}
+}
+
+// GetSecret reads the secret at path, each path is fetched once and cached
+func (p *VaultSecretsProvider) GetSecret(ctx context.Context, path, key string) (string, error) {
+	data, err := p.read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	raw := ""
+	if key == "" {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		raw = string(encoded)
+	}
+	return selectSecretKey(data, raw, key)
+}
+
+// read fetches and caches the data of a Vault path
+func (p *VaultSecretsProvider) read(ctx context.Context, path string) (map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if data, ok := p.cache[path]; ok {
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d for %s", resp.StatusCode, path)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+
+	// KV v2 nests the secret under data.data, KV v1 returns it under data
+	data := body.Data
+	if nested, ok := body.Data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	p.cache[path] = data
+	return data, nil
+}
+
+// AWSSecretsProvider reads secrets from AWS Secrets Manager, JSON secrets can be addressed by key
+type AWSSecretsProvider struct {
+	client *secretsmanager.Client
+}
+
+// NewAWSSecretsProvider creates a provider using the default AWS credential chain and the given region
+func NewAWSSecretsProvider(ctx context.Context, region string) (*AWSSecretsProvider, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return &AWSSecretsProvider{client: secretsmanager.NewFromConfig(cfg)}, nil
+}
+
+// NewAWSSecretsProviderFromClient creates a provider with an existing Secrets Manager client
+func NewAWSSecretsProviderFromClient(client *secretsmanager.Client) *AWSSecretsProvider {
+	return &AWSSecretsProvider{client: client}
+}
+
+// GetSecret reads the secret named path (name or ARN)
+func (p *AWSSecretsProvider) GetSecret(ctx context.Context, path, key string) (string, error) {
+	out, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(path),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret value: %w", err)
+	}
+
+	raw := aws.ToString(out.SecretString)
+	if key == "" {
+		return raw, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot select key %q", key)
+	}
+	return selectSecretKey(data, raw, key)
+}
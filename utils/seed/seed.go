@@ -0,0 +1,166 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// Common environments, any string can be used as environment name
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+	EnvTest        = "test"
+)
+
+// TrackingTable records the seeders already applied to a database
+const TrackingTable = "seeds"
+
+// Seeder inserts a set of data, it runs at most once per database
+type Seeder interface {
+	// Name identifies the seeder in the tracking table, renaming a seeder makes it run again
+	Name() string
+	// Run inserts the data, it runs in the same transaction that records the seeder as applied
+	Run(ctx context.Context, tx pgx.Tx) error
+}
+
+// funcSeeder adapts a function to the Seeder interface
+type funcSeeder struct {
+	name string
+	fn   func(ctx context.Context, tx pgx.Tx) error
+}
+
+func (s funcSeeder) Name() string { return s.name }
+
+func (s funcSeeder) Run(ctx context.Context, tx pgx.Tx) error { return s.fn(ctx, tx) }
+
+// Func creates a seeder from a function
+func Func(name string, fn func(ctx context.Context, tx pgx.Tx) error) Seeder {
+	return funcSeeder{name: name, fn: fn}
+}
+
+// SQL creates a seeder executing a SQL script, useful to port existing seed files
+func SQL(name, script string) Seeder {
+	return Func(name, func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, script)
+		return err
+	})
+}
+
+// entry is a registered seeder and the environments it applies to
+type entry struct {
+	seeder Seeder
+	envs   []string // empty applies to every environment
+}
+
+// Registry holds seeders in registration order
+type Registry struct {
+	mu      sync.RWMutex
+	entries []entry
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a seeder for the given environments, or for every environment when none is given
+// e.g. reference data for all environments, fixtures only for EnvDevelopment and EnvTest
+func (r *Registry) Register(seeder Seeder, envs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry{seeder: seeder, envs: envs})
+}
+
+// Seeders returns the seeders applying to env, in registration order
+func (r *Registry) Seeders(env string) []Seeder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var seeders []Seeder
+	for _, e := range r.entries {
+		if appliesTo(e.envs, env) {
+			seeders = append(seeders, e.seeder)
+		}
+	}
+	return seeders
+}
+
+// Run applies the seeders of env that were not applied yet and returns the names of the applied ones
+// Each seeder runs in its own transaction together with its tracking row, so a failed seeder is retried on the next run
+func (r *Registry) Run(ctx context.Context, db utils.PGXPool, env string) ([]string, error) {
+	if err := ensureTrackingTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, seeder := range r.Seeders(env) {
+		ran := false
+		err := utils.ExecTxContext(ctx, db, func(ctx context.Context, tx pgx.Tx) error {
+			// Concurrent runs block on the primary key until the first one commits, then skip the seeder
+			tag, err := tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (name, env) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING", TrackingTable), seeder.Name(), env)
+			if err != nil {
+				return fmt.Errorf("failed to record seed: %w", err)
+			}
+			if tag.RowsAffected() == 0 {
+				return nil
+			}
+
+			ran = true
+			return seeder.Run(ctx, tx)
+		})
+		if err != nil {
+			return applied, fmt.Errorf("failed to run seed %s: %w", seeder.Name(), err)
+		}
+
+		if ran {
+			log.Printf("Applied seed %s (%s)", seeder.Name(), env)
+			applied = append(applied, seeder.Name())
+		}
+	}
+	return applied, nil
+}
+
+// ensureTrackingTable creates the tracking table if it does not exist
+func ensureTrackingTable(ctx context.Context, db utils.PGXPool) error {
+	_, err := db.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		name TEXT PRIMARY KEY,
+		env TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`, TrackingTable))
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", TrackingTable, err)
+	}
+	return nil
+}
+
+// appliesTo reports whether a seeder registered for envs applies to env
+func appliesTo(envs []string, env string) bool {
+	if len(envs) == 0 {
+		return true
+	}
+	for _, e := range envs {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
+
+var defaultRegistry = NewRegistry()
+
+// Register adds a seeder to the default registry, typically from an init function
+func Register(seeder Seeder, envs ...string) {
+	defaultRegistry.Register(seeder, envs...)
+}
+
+// RunSeeds applies the pending seeders of the default registry for env
+func RunSeeds(ctx context.Context, db utils.PGXPool, env string) error {
+	_, err := defaultRegistry.Run(ctx, db, env)
+	return err
+}
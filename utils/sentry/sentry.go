@@ -0,0 +1,113 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/getsentry/sentry-go"
+)
+
+// Config configures the Sentry client installed by Setup
+type Config struct {
+	DSN         string // empty disables reporting
+	Environment string
+	Release     string
+	SampleRate  float64 // fraction of errors sent, default 1
+	Debug       bool
+}
+
+// ConfigFromEnv reads SENTRY_DSN, SENTRY_ENVIRONMENT (default APP_ENV), SENTRY_RELEASE and SENTRY_SAMPLE_RATE
+func ConfigFromEnv() Config {
+	rate, err := strconv.ParseFloat(utils.GetEnv("SENTRY_SAMPLE_RATE", "1"), 64)
+	if err != nil {
+		rate = 1
+	}
+
+	return Config{
+		DSN:         utils.GetEnv("SENTRY_DSN", ""),
+		Environment: utils.GetEnv("SENTRY_ENVIRONMENT", utils.CurrentAppEnv()),
+		Release:     utils.GetEnv("SENTRY_RELEASE", utils.GetEnv("SERVICE_VERSION", "")),
+		SampleRate:  rate,
+		Debug:       utils.GetEnvBool("SENTRY_DEBUG", false),
+	}
+}
+
+// Setup initializes the Sentry client and installs a Reporter as the global utils.ErrorReporter
+// Call Flush on shutdown so queued events are sent
+func Setup(cfg Config) (*Reporter, error) {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 1
+	}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		SampleRate:  cfg.SampleRate,
+		Debug:       cfg.Debug,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize sentry: %w", err)
+	}
+
+	reporter := NewReporter(sentry.CurrentHub())
+	utils.SetGlobalErrorReporter(reporter)
+	return reporter, nil
+}
+
+// Reporter is a utils.ErrorReporter sending errors to Sentry
+type Reporter struct {
+	hub *sentry.Hub
+}
+
+// NewReporter creates a reporter sending events through hub
+func NewReporter(hub *sentry.Hub) *Reporter {
+	return &Reporter{hub: hub}
+}
+
+// Report sends the error as an exception event tagged with the request, user and tenant
+func (r *Reporter) Report(ctx context.Context, report utils.ErrorReport) {
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	if report.Panic {
+		event.Level = sentry.LevelFatal
+	}
+	event.Exception = []sentry.Exception{{
+		Type:       reflect.TypeOf(report.Err).String(),
+		Value:      report.Err.Error(),
+		Stacktrace: stacktrace(report),
+	}}
+	event.Request = &sentry.Request{Method: report.Method, URL: report.Path}
+	event.Tags = map[string]string{"status": strconv.Itoa(report.Status)}
+	if report.RequestID != "" {
+		event.Tags["request_id"] = report.RequestID
+	}
+	if report.TenantID != "" {
+		event.Tags["tenant_id"] = report.TenantID
+	}
+	if report.UserID != "" {
+		event.User = sentry.User{ID: report.UserID}
+	}
+
+	r.hub.Clone().CaptureEvent(event)
+}
+
+// Flush waits up to timeout for queued events to be sent
+func (r *Reporter) Flush(timeout time.Duration) bool {
+	return r.hub.Flush(timeout)
+}
+
+// stacktrace converts the frames of the report, Sentry expects the outermost call first
+func stacktrace(report utils.ErrorReport) *sentry.Stacktrace {
+	if len(report.Stack) == 0 {
+		return nil
+	}
+	frames := make([]sentry.Frame, 0, len(report.Stack))
+	for i := len(report.Stack) - 1; i >= 0; i-- {
+		frames = append(frames, sentry.NewFrame(report.Stack[i]))
+	}
+	return &sentry.Stacktrace{Frames: frames}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTPServerConfig configures the server created by NewHTTPServer, zero durations use the defaults
+type HTTPServerConfig struct {
+	Addr              string        // default ":8000"
+	ReadHeaderTimeout time.Duration // default 5s, guards against slowloris
+	ReadTimeout       time.Duration // default 15s, whole request including the body
+	WriteTimeout      time.Duration // default 30s, keep it above the Timeout middleware
+	IdleTimeout       time.Duration // default 60s, keep-alive connections
+	MaxHeaderBytes    int           // default 1 MiB
+	ShutdownTimeout   time.Duration // pass to WithShutdownTimeout, default 20s
+
+	TLSCertFile      string // serve TLS with this certificate and TLSKeyFile
+	TLSKeyFile       string
+	AutocertDomains  []string // serve TLS with Let's Encrypt certificates for these domains instead
+	AutocertCacheDir string   // where autocert stores certificates, default "certs"
+	H2C              bool     // serve HTTP/2 without TLS, e.g. behind a proxy speaking h2c or for gRPC clients
+}
+
+// withDefaults fills the unset fields of cfg
+func (cfg HTTPServerConfig) withDefaults() HTTPServerConfig {
+	if cfg.Addr == "" {
+		cfg.Addr = ":8000"
+	}
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = 5 * time.Second
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = 15 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 60 * time.Second
+	}
+	if cfg.MaxHeaderBytes <= 0 {
+		cfg.MaxHeaderBytes = 1 << 20
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = 20 * time.Second
+	}
+	if cfg.AutocertCacheDir == "" {
+		cfg.AutocertCacheDir = "certs"
+	}
+	return cfg
+}
+
+// NewHTTPServer creates a server for handler (e.g. a gin engine) with timeouts and header limits,
+// which http.ListenAndServe and gin's Run leave unbounded. TLS is configured when certificate files or
+// autocert domains are set, serve it with Runner or ListenAndServeTLS("", "")
+func NewHTTPServer(cfg HTTPServerConfig, handler http.Handler) (*http.Server, error) {
+	cfg = cfg.withDefaults()
+
+	if cfg.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: cfg.IdleTimeout})
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+
+	switch {
+	case len(cfg.AutocertDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+		}
+		srv.TLSConfig = manager.TLSConfig()
+		srv.TLSConfig.MinVersion = tls.VersionTLS12
+	case cfg.TLSCertFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		srv.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	return srv, nil
+}
+
+// ServeHTTPServer listens on the address of srv, with TLS when NewHTTPServer configured it
+// It returns nil once the server is shut down
+func ServeHTTPServer(srv *http.Server) error {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
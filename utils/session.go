@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSessionNotFound is returned when a session does not exist or expired
+var ErrSessionNotFound = NewCustomError("session not found", http.StatusUnauthorized)
+
+// Session is a server-side session
+type Session struct {
+	ID        string                 `json:"id"`
+	UserID    string                 `json:"user_id"`
+	Values    map[string]interface{} `json:"values,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+}
+
+// SessionStore persists sessions
+type SessionStore interface {
+	// Save stores the session until its ExpiresAt
+	Save(ctx context.Context, session *Session) error
+	// Load returns the session, or ErrSessionNotFound
+	Load(ctx context.Context, id string) (*Session, error)
+	// Delete removes the session, missing sessions are ignored
+	Delete(ctx context.Context, id string) error
+}
+
+// RedisSessionStore stores sessions as JSON in Redis
+type RedisSessionStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisSessionStore creates a Redis session store using keys "session:<id>"
+func NewRedisSessionStore(client redis.UniversalClient) *RedisSessionStore {
+	return &RedisSessionStore{client: client, prefix: "session:"}
+}
+
+func (s *RedisSessionStore) Save(ctx context.Context, session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return s.Delete(ctx, session.ID)
+	}
+	if err := s.client.Set(ctx, s.prefix+session.ID, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) Load(ctx context.Context, id string) (*Session, error) {
+	data, err := s.client.Get(ctx, s.prefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &session, nil
+}
+
+func (s *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	if err := s.client.Del(ctx, s.prefix+id).Err(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// MemorySessionStore keeps sessions in process memory, for development and single instance apps
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+// NewMemorySessionStore creates an in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]Session{}}
+}
+
+func (s *MemorySessionStore) Save(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired sessions on write so the map does not grow without bounds
+	now := time.Now()
+	for id, stored := range s.sessions {
+		if now.After(stored.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[session.ID] = copySession(*session)
+	return nil
+}
+
+func (s *MemorySessionStore) Load(ctx context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		delete(s.sessions, id)
+		return nil, ErrSessionNotFound
+	}
+	session = copySession(session)
+	return &session, nil
+}
+
+func (s *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// copySession copies the values map so callers cannot mutate stored sessions
+func copySession(session Session) Session {
+	if session.Values != nil {
+		values := make(map[string]interface{}, len(session.Values))
+		for key, value := range session.Values {
+			values[key] = value
+		}
+		session.Values = values
+	}
+	return session
+}
+
+// SessionCookieConfig configures the session cookie
+type SessionCookieConfig struct {
+	Name     string // default "session_id"
+	Path     string // default "/"
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite // default http.SameSiteLaxMode
+}
+
+// SessionOption configures a SessionManager
+type SessionOption func(*SessionManager)
+
+// WithSessionCookie sets the cookie settings
+func WithSessionCookie(cfg SessionCookieConfig) SessionOption {
+	return func(m *SessionManager) {
+		m.cookie = cfg
+	}
+}
+
+// SessionManager creates, loads and expires sessions with a sliding TTL
+type SessionManager struct {
+	store  SessionStore
+	ttl    time.Duration
+	cookie SessionCookieConfig
+}
+
+// NewSessionManager creates a session manager, sessions expire after ttl without activity
+func NewSessionManager(store SessionStore, ttl time.Duration, opts ...SessionOption) *SessionManager {
+	m := &SessionManager{
+		store:  store,
+		ttl:    ttl,
+		cookie: SessionCookieConfig{Secure: true},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.cookie.Name == "" {
+		m.cookie.Name = "session_id"
+	}
+	if m.cookie.Path == "" {
+		m.cookie.Path = "/"
+	}
+	if m.cookie.SameSite == 0 {
+		m.cookie.SameSite = http.SameSiteLaxMode
+	}
+	return m
+}
+
+// CookieName returns the name of the session cookie
+func (m *SessionManager) CookieName() string {
+	return m.cookie.Name
+}
+
+// TTL returns the inactivity timeout of sessions
+func (m *SessionManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Create starts a session for userID with a random identifier
+func (m *SessionManager) Create(ctx context.Context, userID string, values map[string]interface{}) (*Session, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:        base64.RawURLEncoding.EncodeToString(id),
+		UserID:    userID,
+		Values:    values,
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.ttl),
+	}
+	if err := m.store.Save(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Get returns the session, or ErrSessionNotFound
+func (m *SessionManager) Get(ctx context.Context, id string) (*Session, error) {
+	if id == "" {
+		return nil, ErrSessionNotFound
+	}
+	return m.store.Load(ctx, id)
+}
+
+// Save stores changes made to the values of a session
+func (m *SessionManager) Save(ctx context.Context, session *Session) error {
+	return m.store.Save(ctx, session)
+}
+
+// Refresh extends the expiry of the session by the manager TTL
+func (m *SessionManager) Refresh(ctx context.Context, session *Session) error {
+	session.ExpiresAt = time.Now().Add(m.ttl)
+	return m.store.Save(ctx, session)
+}
+
+// Destroy deletes the session
+func (m *SessionManager) Destroy(ctx context.Context, id string) error {
+	return m.store.Delete(ctx, id)
+}
+
+// SetCookie writes the session cookie, it is HttpOnly and expires with the session
+func (m *SessionManager) SetCookie(w http.ResponseWriter, session *Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cookie.Name,
+		Value:    session.ID,
+		Path:     m.cookie.Path,
+		Domain:   m.cookie.Domain,
+		Expires:  session.ExpiresAt,
+		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
+		Secure:   m.cookie.Secure,
+		HttpOnly: true,
+		SameSite: m.cookie.SameSite,
+	})
+}
+
+// ClearCookie removes the session cookie from the client
+func (m *SessionManager) ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cookie.Name,
+		Value:    "",
+		Path:     m.cookie.Path,
+		Domain:   m.cookie.Domain,
+		MaxAge:   -1,
+		Secure:   m.cookie.Secure,
+		HttpOnly: true,
+		SameSite: m.cookie.SameSite,
+	})
+}
+
+type sessionContextKey struct{}
+
+// ContextWithSession returns a copy of ctx carrying the session
+func ContextWithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+// SessionFromContext returns the session carried by ctx, if any
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionContextKey{}).(*Session)
+	return session, ok && session != nil
+}
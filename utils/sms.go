@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+)
+
+// ErrInvalidPhoneNumber is returned for phone numbers that cannot be normalized to E.164
+var ErrInvalidPhoneNumber = &CustomError{Code: "INVALID_PHONE_NUMBER", Message: "Invalid phone number", StatusCode: http.StatusUnprocessableEntity}
+
+// SMSSender delivers text messages to E.164 phone numbers
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, body string) error
+}
+
+// NormalizePhoneNumber converts a phone number to E.164, e.g. "0812-3456 789" with default country code "62"
+// becomes "+628123456789". Separators are ignored, "00" is read as the international prefix and numbers with
+// a leading 0 are local to defaultCountryCode, an empty defaultCountryCode rejects local numbers
+func NormalizePhoneNumber(phone, defaultCountryCode string) (string, error) {
+	var digits strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", WrapError(nil, ErrInvalidPhoneNumber, "invalid phone number: unexpected character")
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case strings.HasPrefix(number, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = "+" + number[2:]
+	case strings.HasPrefix(number, "0") && defaultCountryCode != "":
+		number = "+" + strings.TrimPrefix(defaultCountryCode, "+") + number[1:]
+	case defaultCountryCode != "" && strings.HasPrefix(number, strings.TrimPrefix(defaultCountryCode, "+")):
+		// Country code without the international prefix, e.g. "628123456789"
+		number = "+" + number
+	default:
+		return "", WrapError(nil, ErrInvalidPhoneNumber, "invalid phone number: missing country code")
+	}
+
+	// E.164 allows up to 15 digits, the country code never starts with 0
+	if len(number) < 9 || len(number) > 16 || number[1] == '0' {
+		return "", WrapError(nil, ErrInvalidPhoneNumber, "invalid phone number: wrong length or country code")
+	}
+	return number, nil
+}
+
+// TwilioConfig configures the Twilio SMS sender
+type TwilioConfig struct {
+	AccountSID          string
+	AuthToken           string
+	From                string // sender number, ignored when MessagingServiceSID is set
+	MessagingServiceSID string
+	BaseURL             string // default https://api.twilio.com
+	Timeout             time.Duration
+}
+
+// TwilioConfigFromEnv reads TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN, TWILIO_FROM and TWILIO_MESSAGING_SERVICE_SID
+func TwilioConfigFromEnv() TwilioConfig {
+	return TwilioConfig{
+		AccountSID:          GetEnv("TWILIO_ACCOUNT_SID", ""),
+		AuthToken:           GetEnv("TWILIO_AUTH_TOKEN", ""),
+		From:                GetEnv("TWILIO_FROM", ""),
+		MessagingServiceSID: GetEnv("TWILIO_MESSAGING_SERVICE_SID", ""),
+		Timeout:             GetEnvDuration("TWILIO_TIMEOUT", 10*time.Second),
+	}
+}
+
+// TwilioSender sends text messages with the Twilio Messages API
+type TwilioSender struct {
+	cfg    TwilioConfig
+	client *http.Client
+}
+
+// NewTwilioSender creates a Twilio sender
+func NewTwilioSender(cfg TwilioConfig) *TwilioSender {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.twilio.com"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &TwilioSender{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// SendSMS sends body to the E.164 number to, 5xx and 429 responses are marked retryable
+func (s *TwilioSender) SendSMS(ctx context.Context, to, body string) error {
+	form := url.Values{"To": {to}, "Body": {body}}
+	if s.cfg.MessagingServiceSID != "" {
+		form.Set("MessagingServiceSid", s.cfg.MessagingServiceSID)
+	} else {
+		form.Set("From", s.cfg.From)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimSuffix(s.cfg.BaseURL, "/"), url.PathEscape(s.cfg.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create twilio request: %w", err)
+	}
+	req.SetBasicAuth(s.cfg.AccountSID, s.cfg.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return MarkRetryable(fmt.Errorf("failed to send sms: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = json.Unmarshal(data, &apiErr)
+		err := fmt.Errorf("failed to send sms: twilio returned %d (code %d): %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return MarkRetryable(err)
+		}
+		return MarkPermanent(err)
+	}
+	return nil
+}
+
+// LogSMSSender logs text messages instead of sending them, for development and tests
+type LogSMSSender struct{}
+
+// SendSMS logs the message, including its body
+func (LogSMSSender) SendSMS(ctx context.Context, to, body string) error {
+	logger.Named("sms").InfoContext(ctx, "sms not sent, logging only", "to", to, "body", body)
+	return nil
+}
@@ -3,16 +3,26 @@ package utils
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
 	"github.com/google/uuid"
 )
 
@@ -22,6 +32,33 @@ type StorageClient interface {
 	// UploadFile uploads a file to storage and returns the public URL
 	UploadFile(ctx context.Context, fileReader io.Reader, filename, contentType string) (string, error)
 
+	// UploadFileWithResult uploads a file to storage and returns the object key, public URL and checksums
+	UploadFileWithResult(ctx context.Context, fileReader io.Reader, filename, contentType string) (*UploadResult, error)
+
+	// UploadTemp uploads a file under the temp prefix, it expires unless moved to a permanent key
+	UploadTemp(ctx context.Context, fileReader io.Reader, filename, contentType string) (*UploadResult, error)
+
+	// ListFiles lists all objects whose key starts with the given prefix
+	ListFiles(ctx context.Context, prefix string) ([]ObjectInfo, error)
+
+	// CopyFile copies an object to a new key within the bucket
+	CopyFile(ctx context.Context, srcKey, dstKey string) error
+
+	// MoveFile moves an object to a new key within the bucket
+	MoveFile(ctx context.Context, srcKey, dstKey string) error
+
+	// DeleteFile deletes an object from storage
+	DeleteFile(ctx context.Context, key string) error
+
+	// Exists checks whether an object exists and returns its metadata without downloading it
+	Exists(ctx context.Context, key string) (bool, ObjectInfo, error)
+
+	// EnsureBucket creates the bucket with the desired settings if it does not exist
+	EnsureBucket(ctx context.Context, name string, opts BucketOptions) error
+
+	// PublicURL returns the public URL of an object
+	PublicURL(objectKey string) (string, error)
+
 	// GetBucket returns the bucket name
 	GetBucket() string
 
@@ -29,33 +66,91 @@ type StorageClient interface {
 	GetEndpoint() string
 }
 
+// UploadResult holds the outcome of an upload
+type UploadResult struct {
+	Key         string
+	URL         string
+	Size        int64
+	ContentType string
+	ETag        string
+	SHA256      string // hex encoded SHA-256 digest of the content, usable for deduplication
+	MD5         string // hex encoded MD5 digest of the content
+}
+
+// ObjectInfo holds metadata of a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+}
+
 // S3StorageClient implements StorageClient interface for S3-compatible storage (Supabase Storage, AWS S3, etc.)
 type S3StorageClient struct {
-	client   *s3.Client
-	bucket   string
-	endpoint string
+	client            *s3.Client
+	bucket            string
+	endpoint          string
+	retryPolicy       RetryPolicy
+	publicURLTemplate string
+	urlSigner         URLSigner
+	scanner           Scanner
+}
+
+// StorageOption configures optional behavior of the S3 storage client
+type StorageOption func(*S3StorageClient)
+
+// WithStorageRetryPolicy sets the retry policy applied to every storage operation
+func WithStorageRetryPolicy(policy RetryPolicy) StorageOption {
+	return func(s *S3StorageClient) {
+		s.retryPolicy = policy
+	}
 }
 
 // NewS3StorageClient creates a new S3 storage client
-func NewS3StorageClient(client *s3.Client, bucket, endpoint string) StorageClient {
-	return &S3StorageClient{
-		client:   client,
-		bucket:   bucket,
-		endpoint: endpoint,
+func NewS3StorageClient(client *s3.Client, bucket, endpoint string, opts ...StorageOption) StorageClient {
+	s := &S3StorageClient{
+		client:      client,
+		bucket:      bucket,
+		endpoint:    endpoint,
+		retryPolicy: DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // UploadFile uploads a file to storage and returns the public URL
 func (s *S3StorageClient) UploadFile(ctx context.Context, fileReader io.Reader, filename, contentType string) (string, error) {
+	result, err := s.UploadFileWithResult(ctx, fileReader, filename, contentType)
+	if err != nil {
+		return "", err
+	}
+	return result.URL, nil
+}
+
+// UploadFileWithResult uploads a file to storage and returns the object key, public URL and checksums
+func (s *S3StorageClient) UploadFileWithResult(ctx context.Context, fileReader io.Reader, filename, contentType string) (*UploadResult, error) {
 	// Generate unique filename
 	ext := filepath.Ext(filename)
 	newFilename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
 	objectKey := fmt.Sprintf("images/%s", newFilename)
 
+	return s.putObject(ctx, objectKey, fileReader, contentType)
+}
+
+// putObject stores the content under the given key after scanning it and verifies its integrity
+func (s *S3StorageClient) putObject(ctx context.Context, objectKey string, fileReader io.Reader, contentType string) (*UploadResult, error) {
 	// Read file content into buffer
 	fileContent, err := io.ReadAll(fileReader)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// Reject infected files before anything is stored
+	if err := s.scanContent(ctx, fileContent); err != nil {
+		return nil, err
 	}
 
 	// Detect content type if not provided
@@ -63,20 +158,122 @@ func (s *S3StorageClient) UploadFile(ctx context.Context, fileReader io.Reader,
 		contentType = "application/octet-stream"
 	}
 
-	// Upload to storage
-	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(objectKey),
-		Body:        bytes.NewReader(fileContent),
-		ContentType: aws.String(contentType),
-		ACL:         types.ObjectCannedACLPublicRead,
+	sha256Sum := sha256.Sum256(fileContent)
+	md5Sum := md5.Sum(fileContent)
+
+	// Upload to storage, the body is recreated on every attempt so retries resend the full content
+	var out *s3.PutObjectOutput
+	err = s.withRetry(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:         aws.String(s.bucket),
+			Key:            aws.String(objectKey),
+			Body:           bytes.NewReader(fileContent),
+			ContentType:    aws.String(contentType),
+			ContentMD5:     aws.String(base64.StdEncoding.EncodeToString(md5Sum[:])),
+			ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sha256Sum[:])),
+			ACL:            types.ObjectCannedACLPublicRead,
+		})
+		return err
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to upload to storage: %w", err)
+		return nil, fmt.Errorf("failed to upload to storage: %w", err)
 	}
 
+	// ContentMD5 and ChecksumSHA256 make the store reject a corrupted body, the ETag is not compared since
+	// SSE-KMS and some S3 compatible stores return ETags that look like, but are not, the content MD5
+	etag := strings.Trim(aws.ToString(out.ETag), `"`)
+
 	// Generate public URL
-	return s.generatePublicURL(objectKey), nil
+	publicURL, err := s.PublicURL(objectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadResult{
+		Key:         objectKey,
+		URL:         publicURL,
+		Size:        int64(len(fileContent)),
+		ContentType: contentType,
+		ETag:        etag,
+		SHA256:      hex.EncodeToString(sha256Sum[:]),
+		MD5:         hex.EncodeToString(md5Sum[:]),
+	}, nil
+}
+
+// CopyFile copies an object to a new key within the bucket
+func (s *S3StorageClient) CopyFile(ctx context.Context, srcKey, dstKey string) error {
+	err := s.withRetry(ctx, func(ctx context.Context) error {
+		_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(dstKey),
+			CopySource: aws.String(s.copySource(srcKey)),
+			ACL:        types.ObjectCannedACLPublicRead,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy object %s to %s: %w", srcKey, dstKey, err)
+	}
+	return nil
+}
+
+// MoveFile moves an object to a new key within the bucket
+// S3 has no native move, so the object is copied and the source is deleted afterwards
+func (s *S3StorageClient) MoveFile(ctx context.Context, srcKey, dstKey string) error {
+	if err := s.CopyFile(ctx, srcKey, dstKey); err != nil {
+		return err
+	}
+
+	if err := s.DeleteFile(ctx, srcKey); err != nil {
+		return fmt.Errorf("object copied to %s but failed to remove source: %w", dstKey, err)
+	}
+	return nil
+}
+
+// DeleteFile deletes an object from storage
+func (s *S3StorageClient) DeleteFile(ctx context.Context, key string) error {
+	err := s.withRetry(ctx, func(ctx context.Context) error {
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+// Exists checks whether an object exists and returns its metadata without downloading it
+func (s *S3StorageClient) Exists(ctx context.Context, key string) (bool, ObjectInfo, error) {
+	var out *s3.HeadObjectOutput
+	err := s.withRetry(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		if isNotFoundStorageError(err) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return false, ObjectInfo{}, fmt.Errorf("failed to check object %s: %w", key, err)
+	}
+	if out == nil {
+		return false, ObjectInfo{}, nil
+	}
+
+	return true, ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(out.ContentLength),
+		ETag:         strings.Trim(aws.ToString(out.ETag), `"`),
+		ContentType:  aws.ToString(out.ContentType),
+		LastModified: aws.ToTime(out.LastModified),
+	}, nil
 }
 
 // GetBucket returns the bucket name
@@ -89,8 +286,66 @@ func (s *S3StorageClient) GetEndpoint() string {
 	return s.endpoint
 }
 
+// withRetry runs a storage operation using the client's retry policy
+func (s *S3StorageClient) withRetry(ctx context.Context, fn func(ctx context.Context) error) error {
+	return retry.Do(ctx, s.retryPolicy, fn, retry.RetryIf(isRetryableStorageError))
+}
+
+// isRetryableStorageError reports whether a storage error is transient (5xx, throttling or network failure)
+func isRetryableStorageError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		status := respErr.HTTPStatusCode()
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || status == http.StatusRequestTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isNotFoundStorageError reports whether the error means the object does not exist
+func isNotFoundStorageError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound
+}
+
+// copySource builds the URL-encoded "bucket/key" value expected by CopyObject
+func (s *S3StorageClient) copySource(key string) string {
+	return escapeKeyPath(s.bucket + "/" + key)
+}
+
+// escapeKeyPath escapes each segment of an object key, keeping the slashes between them
+func escapeKeyPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
 // generatePublicURL generates the public URL for the uploaded file
 func (s *S3StorageClient) generatePublicURL(objectKey string) string {
+	if s.publicURLTemplate != "" {
+		return s.renderPublicURLTemplate(objectKey)
+	}
+
 	// Supabase Storage public URL format: https://<project-ref>.supabase.co/storage/v1/object/public/<bucket>/<path>
 	if strings.HasPrefix(s.endpoint, "https://") {
 		// Extract project ref: https://mhheblvgktovcrdcsjdo.storage.supabase.co/storage/v1/s3
@@ -104,15 +359,46 @@ func (s *S3StorageClient) generatePublicURL(objectKey string) string {
 	return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, objectKey)
 }
 
+// Global storage client instance
+var globalStorageClient StorageClient
+
+// SetGlobalStorageClient sets the global storage client
+func SetGlobalStorageClient(client StorageClient) {
+	globalStorageClient = client
+}
+
+// GetGlobalStorageClient returns the global storage client
+func GetGlobalStorageClient() (StorageClient, error) {
+	if globalStorageClient == nil {
+		return nil, errors.New("storage client not initialized")
+	}
+	return globalStorageClient, nil
+}
+
 // NewStorageClient creates a new storage client based on the provided config
 // This factory function returns the StorageClient interface, allowing easy swapping of implementations
-func NewStorageClient(config *Config) (StorageClient, error) {
+func NewStorageClient(config *Config, opts ...StorageOption) (StorageClient, error) {
+	return NewStorageClientFromConfig(StorageConfig{
+		Endpoint:          config.StorageEndpoint,
+		Region:            config.StorageRegion,
+		AccessKey:         config.StorageAccessKey,
+		SecretKey:         config.StorageSecretKey,
+		Bucket:            config.StorageBucket,
+		PublicURLTemplate: config.StoragePublicURLTemplate,
+		CDNSigningSecret:  config.StorageCDNSigningSecret,
+		CDNURLTTL:         config.StorageCDNURLTTL,
+		ClamAVAddress:     config.ClamAVAddress,
+	}, opts...)
+}
+
+// NewStorageClientFromConfig creates a new S3-compatible storage client from the storage settings (Config.Storage)
+func NewStorageClientFromConfig(config StorageConfig, opts ...StorageOption) (StorageClient, error) {
 	// Configure AWS SDK for S3-compatible storage (Supabase Storage)
 	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(),
-		awsconfig.WithRegion(config.StorageRegion),
+		awsconfig.WithRegion(config.Region),
 		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			config.StorageAccessKey,
-			config.StorageSecretKey,
+			config.AccessKey,
+			config.SecretKey,
 			"",
 		)),
 	)
@@ -122,15 +408,31 @@ func NewStorageClient(config *Config) (StorageClient, error) {
 
 	// Create S3 client with custom endpoint
 	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(config.StorageEndpoint)
+		o.BaseEndpoint = aws.String(config.Endpoint)
 		o.UsePathStyle = true // Required for Supabase Storage
 	})
 
 	// Use default bucket if not specified
-	bucket := config.StorageBucket
+	bucket := config.Bucket
 	if bucket == "" {
 		bucket = "images"
 	}
 
-	return NewS3StorageClient(s3Client, bucket, config.StorageEndpoint), nil
+	// Serve objects from a CDN when configured, explicit options take precedence
+	var configOpts []StorageOption
+	if config.PublicURLTemplate != "" {
+		configOpts = append(configOpts, WithPublicURLTemplate(config.PublicURLTemplate))
+	}
+	if config.CDNSigningSecret != "" {
+		ttl := config.CDNURLTTL
+		if ttl <= 0 {
+			ttl = time.Hour
+		}
+		configOpts = append(configOpts, WithSignedCDNURL(NewHMACURLSigner(config.CDNSigningSecret, ttl)))
+	}
+	if config.ClamAVAddress != "" {
+		configOpts = append(configOpts, WithScanner(NewClamAVScanner(config.ClamAVAddress, 30*time.Second)))
+	}
+
+	return NewS3StorageClient(s3Client, bucket, config.Endpoint, append(configOpts, opts...)...), nil
 }
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// BucketOptions configures a bucket created by EnsureBucket
+type BucketOptions struct {
+	ACL        types.BucketCannedACL // canned ACL of the bucket, e.g. types.BucketCannedACLPublicRead
+	Region     string                // location constraint, empty uses the client region
+	Versioning bool                  // enable object versioning
+}
+
+// EnsureBucket creates the bucket if it does not exist and applies the desired settings
+func (s *S3StorageClient) EnsureBucket(ctx context.Context, name string, opts BucketOptions) error {
+	exists, err := s.bucketExists(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		input := &s3.CreateBucketInput{
+			Bucket: aws.String(name),
+			ACL:    opts.ACL,
+		}
+		// us-east-1 is the default location and must not be sent as a constraint
+		if opts.Region != "" && opts.Region != "us-east-1" {
+			input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+				LocationConstraint: types.BucketLocationConstraint(opts.Region),
+			}
+		}
+
+		err = s.withRetry(ctx, func(ctx context.Context) error {
+			_, err := s.client.CreateBucket(ctx, input)
+			return err
+		})
+
+		var owned *types.BucketAlreadyOwnedByYou
+		if err != nil && !errors.As(err, &owned) {
+			return fmt.Errorf("failed to create bucket %s: %w", name, err)
+		}
+	}
+
+	if opts.Versioning {
+		err = s.withRetry(ctx, func(ctx context.Context) error {
+			_, err := s.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+				Bucket: aws.String(name),
+				VersioningConfiguration: &types.VersioningConfiguration{
+					Status: types.BucketVersioningStatusEnabled,
+				},
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to enable versioning on bucket %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// bucketExists checks whether the bucket exists and is accessible
+func (s *S3StorageClient) bucketExists(ctx context.Context, name string) (bool, error) {
+	exists := true
+	err := s.withRetry(ctx, func(ctx context.Context) error {
+		_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+			Bucket: aws.String(name),
+		})
+		if isNotFoundStorageError(err) {
+			exists = false
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check bucket %s: %w", name, err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// storageMetrics holds the Prometheus collectors for storage operations
+type storageMetrics struct {
+	operations    *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	uploadedBytes prometheus.Counter
+}
+
+// InstrumentedStorageClient decorates a StorageClient with Prometheus metrics and OpenTelemetry spans
+type InstrumentedStorageClient struct {
+	inner   StorageClient
+	metrics *storageMetrics
+	tracer  trace.Tracer
+}
+
+// NewInstrumentedStorageClient wraps a storage client with metrics and tracing
+// A nil registerer disables metrics and a nil tracer disables spans
+func NewInstrumentedStorageClient(inner StorageClient, registerer prometheus.Registerer, tracer trace.Tracer) StorageClient {
+	client := &InstrumentedStorageClient{
+		inner:  inner,
+		tracer: tracer,
+	}
+
+	if registerer != nil {
+		client.metrics = &storageMetrics{
+			operations: RegisterCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "storage_operations_total",
+				Help: "Total number of storage operations by operation and status.",
+			}, []string{"operation", "status"})),
+			duration: RegisterCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "storage_operation_duration_seconds",
+				Help:    "Duration of storage operations in seconds.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"operation"})),
+			uploadedBytes: RegisterCollector(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "storage_uploaded_bytes_total",
+				Help: "Total number of bytes uploaded to storage.",
+			})),
+		}
+	}
+
+	return client
+}
+
+// RegisterCollector registers a collector, reusing the already registered one on duplicates
+// so instrumented components can be created several times against the same registerer
+func RegisterCollector[T prometheus.Collector](registerer prometheus.Registerer, collector T) T {
+	if err := registerer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			if existing, ok := alreadyRegistered.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return collector
+}
+
+// observe starts a span for the operation and returns a function recording its outcome
+func (c *InstrumentedStorageClient) observe(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	start := time.Now()
+
+	var span trace.Span
+	if c.tracer != nil {
+		attrs = append(attrs, attribute.String("storage.bucket", c.inner.GetBucket()))
+		ctx, span = c.tracer.Start(ctx, "storage."+operation, trace.WithAttributes(attrs...), trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	return ctx, func(err error) {
+		if c.metrics != nil {
+			status := "success"
+			if err != nil {
+				status = "error"
+			}
+			c.metrics.operations.WithLabelValues(operation, status).Inc()
+			c.metrics.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		}
+
+		if span != nil {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+	}
+}
+
+// UploadFile uploads a file to storage and returns the public URL
+func (c *InstrumentedStorageClient) UploadFile(ctx context.Context, fileReader io.Reader, filename, contentType string) (string, error) {
+	result, err := c.UploadFileWithResult(ctx, fileReader, filename, contentType)
+	if err != nil {
+		return "", err
+	}
+	return result.URL, nil
+}
+
+// UploadFileWithResult uploads a file to storage and returns the object key, public URL and checksums
+func (c *InstrumentedStorageClient) UploadFileWithResult(ctx context.Context, fileReader io.Reader, filename, contentType string) (*UploadResult, error) {
+	ctx, done := c.observe(ctx, "upload", attribute.String("storage.filename", filename))
+	result, err := c.inner.UploadFileWithResult(ctx, fileReader, filename, contentType)
+	done(err)
+
+	if err == nil && c.metrics != nil {
+		c.metrics.uploadedBytes.Add(float64(result.Size))
+	}
+	return result, err
+}
+
+// UploadTemp uploads a file under the temp prefix
+func (c *InstrumentedStorageClient) UploadTemp(ctx context.Context, fileReader io.Reader, filename, contentType string) (*UploadResult, error) {
+	ctx, done := c.observe(ctx, "upload_temp", attribute.String("storage.filename", filename))
+	result, err := c.inner.UploadTemp(ctx, fileReader, filename, contentType)
+	done(err)
+
+	if err == nil && c.metrics != nil {
+		c.metrics.uploadedBytes.Add(float64(result.Size))
+	}
+	return result, err
+}
+
+// ListFiles lists all objects whose key starts with the given prefix
+func (c *InstrumentedStorageClient) ListFiles(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	ctx, done := c.observe(ctx, "list", attribute.String("storage.prefix", prefix))
+	objects, err := c.inner.ListFiles(ctx, prefix)
+	done(err)
+	return objects, err
+}
+
+// CopyFile copies an object to a new key within the bucket
+func (c *InstrumentedStorageClient) CopyFile(ctx context.Context, srcKey, dstKey string) error {
+	ctx, done := c.observe(ctx, "copy", attribute.String("storage.src_key", srcKey), attribute.String("storage.dst_key", dstKey))
+	err := c.inner.CopyFile(ctx, srcKey, dstKey)
+	done(err)
+	return err
+}
+
+// MoveFile moves an object to a new key within the bucket
+func (c *InstrumentedStorageClient) MoveFile(ctx context.Context, srcKey, dstKey string) error {
+	ctx, done := c.observe(ctx, "move", attribute.String("storage.src_key", srcKey), attribute.String("storage.dst_key", dstKey))
+	err := c.inner.MoveFile(ctx, srcKey, dstKey)
+	done(err)
+	return err
+}
+
+// DeleteFile deletes an object from storage
+func (c *InstrumentedStorageClient) DeleteFile(ctx context.Context, key string) error {
+	ctx, done := c.observe(ctx, "delete", attribute.String("storage.key", key))
+	err := c.inner.DeleteFile(ctx, key)
+	done(err)
+	return err
+}
+
+// Exists checks whether an object exists and returns its metadata without downloading it
+func (c *InstrumentedStorageClient) Exists(ctx context.Context, key string) (bool, ObjectInfo, error) {
+	ctx, done := c.observe(ctx, "exists", attribute.String("storage.key", key))
+	exists, info, err := c.inner.Exists(ctx, key)
+	done(err)
+	return exists, info, err
+}
+
+// EnsureBucket creates the bucket with the desired settings if it does not exist
+func (c *InstrumentedStorageClient) EnsureBucket(ctx context.Context, name string, opts BucketOptions) error {
+	ctx, done := c.observe(ctx, "ensure_bucket", attribute.String("storage.target_bucket", name))
+	err := c.inner.EnsureBucket(ctx, name, opts)
+	done(err)
+	return err
+}
+
+// PublicURL returns the public URL of an object
+func (c *InstrumentedStorageClient) PublicURL(objectKey string) (string, error) {
+	return c.inner.PublicURL(objectKey)
+}
+
+// GetBucket returns the bucket name
+func (c *InstrumentedStorageClient) GetBucket() string {
+	return c.inner.GetBucket()
+}
+
+// GetEndpoint returns the storage endpoint
+func (c *InstrumentedStorageClient) GetEndpoint() string {
+	return c.inner.GetEndpoint()
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+)
+
+// TempPrefix is the key prefix under which temporary (draft) uploads are stored
+const TempPrefix = "temp/"
+
+// UploadTemp uploads a file under the temp prefix, it is removed by the cleanup worker unless moved with MoveFile
+func (s *S3StorageClient) UploadTemp(ctx context.Context, fileReader io.Reader, filename, contentType string) (*UploadResult, error) {
+	objectKey := fmt.Sprintf("%s%s%s", TempPrefix, uuid.New().String(), filepath.Ext(filename))
+	return s.putObject(ctx, objectKey, fileReader, contentType)
+}
+
+// ListFiles lists all objects whose key starts with the given prefix
+func (s *S3StorageClient) ListFiles(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		var page *s3.ListObjectsV2Output
+		err := s.withRetry(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = paginator.NextPage(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err)
+		}
+
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         strings.Trim(aws.ToString(obj.ETag), `"`),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+// TempCleanupWorker periodically deletes temporary objects older than a configured age
+type TempCleanupWorker struct {
+	storage  StorageClient
+	maxAge   time.Duration
+	interval time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewTempCleanupWorker creates a new cleanup worker for objects under TempPrefix
+// A maxAge <= 0 defaults to 24h and an interval <= 0 to 1h
+func NewTempCleanupWorker(storage StorageClient, maxAge, interval time.Duration) *TempCleanupWorker {
+	if maxAge <= 0 {
+		maxAge = 24 * time.Hour
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &TempCleanupWorker{
+		storage:  storage,
+		maxAge:   maxAge,
+		interval: interval,
+	}
+}
+
+// Start runs the cleanup loop in the background until Stop is called or ctx is done
+func (w *TempCleanupWorker) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := w.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Temp cleanup failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the cleanup loop and waits for the current run to finish
+func (w *TempCleanupWorker) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+// RunOnce deletes expired temporary objects once and returns how many were deleted
+func (w *TempCleanupWorker) RunOnce(ctx context.Context) (int, error) {
+	objects, err := w.storage.ListFiles(ctx, TempPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	cutoff := time.Now().Add(-w.maxAge)
+	for _, obj := range objects {
+		if obj.LastModified.After(cutoff) {
+			continue
+		}
+		if err := w.storage.DeleteFile(ctx, obj.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted %d expired temporary objects", deleted)
+	}
+	return deleted, nil
+}
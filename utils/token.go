@@ -132,17 +132,37 @@ func GenerateToken(req GenerateTokenReq) (GenerateTokenResp, error) {
 
 // Redis-based token management
 type RedisTokenManager struct {
-	redisClient *redis.Client
+	redisClient redis.UniversalClient
 	secret      string
 	expiryHours int
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
 }
 
+// Default lifetimes of the tokens issued by GenerateTokenPair
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+)
+
 // NewRedisTokenManager creates a new Redis-based token manager
-func NewRedisTokenManager(redisClient *redis.Client, secret string, expiryHours int) *RedisTokenManager {
+func NewRedisTokenManager(redisClient redis.UniversalClient, secret string, expiryHours int) *RedisTokenManager {
 	return &RedisTokenManager{
 		redisClient: redisClient,
 		secret:      secret,
 		expiryHours: expiryHours,
+		accessTTL:   DefaultAccessTokenTTL,
+		refreshTTL:  DefaultRefreshTokenTTL,
+	}
+}
+
+// SetTokenPairTTL sets the lifetimes of access and refresh tokens, zero values keep the current ones
+func (rtm *RedisTokenManager) SetTokenPairTTL(accessTTL, refreshTTL time.Duration) {
+	if accessTTL > 0 {
+		rtm.accessTTL = accessTTL
+	}
+	if refreshTTL > 0 {
+		rtm.refreshTTL = refreshTTL
 	}
 }
 
@@ -256,8 +276,8 @@ func GenerateTokenPair(req GenerateTokenReq) (TokenPairResp, error) {
 		return TokenPairResp{}, errors.New("Redis token manager not initialized")
 	}
 
-	// Access token: 15 minutes
-	accessExpTime := time.Now().Add(15 * time.Minute)
+	// Access token: 15 minutes unless configured with SetTokenPairTTL
+	accessExpTime := time.Now().Add(globalRedisTokenManager.accessTTL)
 	accessExpToken := accessExpTime.Unix()
 	accessClaims := jwt.MapClaims{
 		"user_id":  req.UserID,
@@ -272,8 +292,8 @@ func GenerateTokenPair(req GenerateTokenReq) (TokenPairResp, error) {
 		return TokenPairResp{}, err
 	}
 
-	// Refresh token: 7 days
-	refreshExpTime := time.Now().Add(7 * 24 * time.Hour)
+	// Refresh token: 7 days unless configured with SetTokenPairTTL
+	refreshExpTime := time.Now().Add(globalRedisTokenManager.refreshTTL)
 	refreshExpToken := refreshExpTime.Unix()
 	refreshClaims := jwt.MapClaims{
 		"user_id":  req.UserID,
@@ -291,7 +311,7 @@ func GenerateTokenPair(req GenerateTokenReq) (TokenPairResp, error) {
 	return TokenPairResp{
 		AccessToken:  accessTokenString,
 		RefreshToken: refreshTokenString,
-		ExpiresIn:    900, // 15 minutes in seconds
+		ExpiresIn:    int64(globalRedisTokenManager.accessTTL.Seconds()),
 	}, nil
 }
 
@@ -301,8 +321,7 @@ func StoreRefreshTokenInRedis(ctx context.Context, userID, token string) error {
 		return errors.New("Redis token manager not initialized")
 	}
 	key := fmt.Sprintf("refresh_token:%s", userID)
-	expiration := 7 * 24 * time.Hour // 7 days
-	return globalRedisTokenManager.redisClient.Set(ctx, key, token, expiration).Err()
+	return globalRedisTokenManager.redisClient.Set(ctx, key, token, globalRedisTokenManager.refreshTTL).Err()
 }
 
 // ValidateRefreshToken validates a refresh token
@@ -351,3 +370,16 @@ func RevokeAllTokens(ctx context.Context, userID string) error {
 	}
 	return RevokeRefreshTokenFromRedis(ctx, userID)
 }
+
+type claimsContextKey struct{}
+
+// ContextWithClaims returns a copy of ctx carrying the claims of the authenticated user
+func ContextWithClaims(ctx context.Context, claims *TokenClaims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims set by the auth middleware, if any
+func ClaimsFromContext(ctx context.Context) (*TokenClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*TokenClaims)
+	return claims, ok && claims != nil
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes why a field failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError aggregates the field errors of a request, it is rendered as 422 VALIDATION_FAILED
+// with the fields as details and matches ErrValidation:
+//
+//	v := utils.NewValidationError()
+//	if taken {
+//		v.Add("email", "unique", "is already taken")
+//	}
+//	return v.Err()
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewValidationError creates an empty validation error
+func NewValidationError() *ValidationError {
+	return &ValidationError{}
+}
+
+// Add adds a field error
+func (v *ValidationError) Add(field, rule, message string) *ValidationError {
+	v.Fields = append(v.Fields, FieldError{Field: field, Rule: rule, Message: message})
+	return v
+}
+
+// Merge adds the field errors of err, a ValidationError or validator.ValidationErrors, other errors are ignored
+func (v *ValidationError) Merge(err error) *ValidationError {
+	if other, ok := ValidationErrorFrom(err); ok {
+		v.Fields = append(v.Fields, other.Fields...)
+	}
+	return v
+}
+
+// HasErrors reports whether any field error was added
+func (v *ValidationError) HasErrors() bool {
+	return len(v.Fields) > 0
+}
+
+// Err returns v when it has field errors and nil otherwise
+func (v *ValidationError) Err() error {
+	if !v.HasErrors() {
+		return nil
+	}
+	return v
+}
+
+func (v *ValidationError) Error() string {
+	messages := make([]string, len(v.Fields))
+	for i, fe := range v.Fields {
+		messages[i] = fe.Field + " " + fe.Message
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+// Is makes errors.Is(err, ErrValidation) true
+func (v *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// As converts v into a CustomError so renderers handling CustomError render the field errors as details
+func (v *ValidationError) As(target interface{}) bool {
+	customErr, ok := target.(**CustomError)
+	if !ok {
+		return false
+	}
+	*customErr = &CustomError{
+		Code:       CodeValidationFailed,
+		Message:    ErrValidation.Message,
+		StatusCode: http.StatusUnprocessableEntity,
+		Details:    v.Fields,
+	}
+	return true
+}
+
+// ValidationErrorFrom returns the ValidationError in the chain of err, converting validator.ValidationErrors
+// Field names are the namespace without the struct name, e.g. "address.city"
+func ValidationErrorFrom(err error) (*ValidationError, bool) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr, true
+	}
+
+	var validatorErrs validator.ValidationErrors
+	if !errors.As(err, &validatorErrs) {
+		return nil, false
+	}
+	v := &ValidationError{Fields: make([]FieldError, 0, len(validatorErrs))}
+	for _, fe := range validatorErrs {
+		field := fe.Namespace()
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest
+		}
+		v.Fields = append(v.Fields, FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fieldErrorMessage(fe),
+		})
+	}
+	return v, true
+}
+
+// fieldErrorMessage returns a readable message for the common validation rules
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url", "uri":
+		return "must be a valid URL"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "min", "gte":
+		if unit := lengthUnit(fe.Kind()); unit != "" {
+			return fmt.Sprintf("must have at least %s %s", fe.Param(), unit)
+		}
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max", "lte":
+		if unit := lengthUnit(fe.Kind()); unit != "" {
+			return fmt.Sprintf("must have at most %s %s", fe.Param(), unit)
+		}
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "len":
+		return fmt.Sprintf("must have a length of %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", fe.Param())
+	}
+	return fmt.Sprintf("failed the %s validation", fe.Tag())
+}
+
+// lengthUnit returns what min and max count for kinds measured by length
+func lengthUnit(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+)
+
+// Delivery is an event to be posted to an endpoint, with the outcome of its last attempt
+type Delivery struct {
+	ID             string          `json:"id"`
+	EndpointID     string          `json:"endpoint_id"`
+	TenantID       string          `json:"tenant_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// Attempt is a logged request of a delivery
+type Attempt struct {
+	Attempt      int           `json:"attempt"`
+	StatusCode   int           `json:"status_code,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	ResponseBody string        `json:"response_body,omitempty"` // first 4KB
+	Duration     time.Duration `json:"duration"`
+	AttemptedAt  time.Time     `json:"attempted_at"`
+}
+
+// DeliveryFilter narrows ListDeliveries, zero fields match everything
+type DeliveryFilter struct {
+	EndpointID string
+	Status     string
+	EventType  string
+	Before     time.Time // deliveries created before, to page through older deliveries
+	Limit      int       // default 50
+}
+
+// ListDeliveries returns the deliveries of the tenant matching filter, newest first
+func (d *Dispatcher) ListDeliveries(ctx context.Context, tenantID string, filter DeliveryFilter) ([]Delivery, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	var before interface{}
+	if !filter.Before.IsZero() {
+		before = filter.Before
+	}
+
+	rows, err := d.db.Query(ctx, `SELECT id, endpoint_id, tenant_id, event_id, event_type, payload, status, attempts,
+		next_attempt_at, last_status_code, last_error, created_at, delivered_at FROM `+DeliveriesTable+`
+		WHERE tenant_id = $1
+			AND ($2 = '' OR endpoint_id::text = $2)
+			AND ($3 = '' OR status = $3)
+			AND ($4 = '' OR event_type = $4)
+			AND ($5::timestamptz IS NULL OR created_at < $5)
+		ORDER BY created_at DESC, id
+		LIMIT $6`,
+		tenantID, filter.EndpointID, filter.Status, filter.EventType, before, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var dl Delivery
+		var payload []byte
+		if err := rows.Scan(&dl.ID, &dl.EndpointID, &dl.TenantID, &dl.EventID, &dl.EventType, &payload, &dl.Status, &dl.Attempts,
+			&dl.NextAttemptAt, &dl.LastStatusCode, &dl.LastError, &dl.CreatedAt, &dl.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		dl.Payload = payload
+		deliveries = append(deliveries, dl)
+	}
+	return deliveries, rows.Err()
+}
+
+// ListAttempts returns the logged attempts of a delivery of the tenant, oldest first
+func (d *Dispatcher) ListAttempts(ctx context.Context, tenantID, deliveryID string) ([]Attempt, error) {
+	rows, err := d.db.Query(ctx, `SELECT a.attempt, a.status_code, a.error, a.response_body, a.duration_ms, a.attempted_at
+		FROM `+AttemptsTable+` a JOIN `+DeliveriesTable+` d ON d.id = a.delivery_id
+		WHERE d.tenant_id = $1 AND d.id::text = $2
+		ORDER BY a.id`, tenantID, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []Attempt{}
+	for rows.Next() {
+		var a Attempt
+		var durationMS int64
+		if err := rows.Scan(&a.Attempt, &a.StatusCode, &a.Error, &a.ResponseBody, &durationMS, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %w", err)
+		}
+		a.Duration = time.Duration(durationMS) * time.Millisecond
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// Redeliver schedules a delivery of the tenant again right away with a fresh set of attempts,
+// e.g. a failed delivery once the receiver is fixed
+func (d *Dispatcher) Redeliver(ctx context.Context, tenantID, deliveryID string) error {
+	tag, err := d.db.Exec(ctx, `UPDATE `+DeliveriesTable+` SET status = $3, attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE tenant_id = $1 AND id::text = $2`, tenantID, deliveryID, StatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to redeliver webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return utils.NewNotFoundError("webhook delivery not found")
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+	"github.com/jackc/pgx/v5"
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed" // dead-lettered after exhausting the attempts, see Dispatcher.Redeliver
+)
+
+// ErrPrivateAddress is returned when an endpoint resolves to a loopback, private or link-local address
+var ErrPrivateAddress = errors.New("webhook endpoint resolves to a private address")
+
+// DispatcherOption configures a Dispatcher
+type DispatcherOption func(*Dispatcher)
+
+// WithHTTPClient sets the client posting deliveries (default 10s timeout, no redirects, private addresses refused)
+func WithHTTPClient(client *http.Client) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithRetryPolicy sets the attempts of a delivery and the backoff between them (default 10 attempts from 30s up to 6h)
+func WithRetryPolicy(policy retry.Policy) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.policy = policy
+	}
+}
+
+// WithBatchSize sets how many due deliveries are claimed per run (default 50)
+func WithBatchSize(n int) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.batchSize = n
+	}
+}
+
+// WithConcurrency sets how many deliveries of a batch are posted at the same time (default 10)
+func WithConcurrency(n int) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.concurrency = n
+	}
+}
+
+// WithInterval sets the polling interval (default 1s)
+func WithInterval(interval time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.interval = interval
+	}
+}
+
+// Dispatcher posts pending deliveries to their endpoints, signed with the endpoint secret
+// Deliveries are claimed with FOR UPDATE SKIP LOCKED and leased while they are posted, so several instances can
+// dispatch concurrently without holding transactions during requests. Every attempt is logged, failed deliveries
+// are retried with backoff and marked failed once their attempts are exhausted. Delivery is at-least-once,
+// receivers dedupe by the X-Webhook-ID header
+type Dispatcher struct {
+	db          utils.PGXPool
+	client      *http.Client
+	policy      retry.Policy
+	batchSize   int
+	concurrency int
+	interval    time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher of the deliveries stored in db
+func NewDispatcher(db utils.PGXPool, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		db: db,
+		policy: retry.Policy{
+			MaxAttempts: 10,
+			Backoff:     30 * time.Second,
+			MaxBackoff:  6 * time.Hour,
+			Multiplier:  2,
+			Jitter:      0.2,
+		},
+		batchSize:   50,
+		concurrency: 10,
+		interval:    time.Second,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.client == nil {
+		d.client = NewHTTPClient(10 * time.Second)
+	}
+	if d.concurrency < 1 {
+		d.concurrency = 1
+	}
+	if d.batchSize < 1 {
+		d.batchSize = 1
+	}
+	if d.interval <= 0 {
+		d.interval = time.Second
+	}
+	return d
+}
+
+// NewHTTPClient returns a client for posting to customer URLs: it does not follow redirects and refuses to connect
+// to loopback, private and link-local addresses, so endpoints cannot reach internal services
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+				ip.IsUnspecified() || ip.IsMulticast() {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Name implements worker.Worker
+func (d *Dispatcher) Name() string {
+	return "webhook-dispatcher"
+}
+
+// Run dispatches due deliveries until ctx is canceled
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		dispatched, err := d.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Named("webhook").Error("webhook dispatch failed", "error", err)
+		}
+
+		// Keep draining without waiting while full batches are dispatched
+		if dispatched == d.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Start runs the dispatch loop in the background until Stop is called or ctx is done
+func (d *Dispatcher) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.Run(ctx)
+	}()
+}
+
+// Stop stops the dispatch loop and waits for the current run to finish
+func (d *Dispatcher) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+}
+
+// claimed is a delivery leased by the current run with the endpoint to post it to
+type claimed struct {
+	id        string
+	eventID   string
+	eventType string
+	payload   []byte
+	attempt   int
+	url       string
+	secret    string
+	previous  string // previous secret while the rotation overlap lasts, empty otherwise
+	tenantID  string
+}
+
+// result is the outcome of posting a delivery
+type result struct {
+	statusCode int
+	response   string
+	duration   time.Duration
+	err        error
+}
+
+// RunOnce posts one batch of due deliveries and returns how many were attempted
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.claim(ctx)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	// Results are recorded on a context that is not canceled, so a shutdown does not lose finished attempts
+	recordCtx := context.WithoutCancel(ctx)
+	sem := make(chan struct{}, d.concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(delivery claimed) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res := d.post(ctx, delivery)
+			if err := d.record(recordCtx, delivery, res); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries), errors.Join(errs...)
+}
+
+// claim leases the next due deliveries of active endpoints for longer than a request may take
+func (d *Dispatcher) claim(ctx context.Context) ([]claimed, error) {
+	lease := d.client.Timeout + time.Minute
+	if d.client.Timeout == 0 {
+		lease = 10 * time.Minute
+	}
+
+	rows, err := d.db.Query(ctx, `UPDATE `+DeliveriesTable+` AS wd
+		SET next_attempt_at = NOW() + make_interval(secs => $2), attempts = wd.attempts + 1
+		FROM `+EndpointsTable+` AS we
+		WHERE wd.endpoint_id = we.id AND wd.id IN (
+			SELECT d.id FROM `+DeliveriesTable+` d JOIN `+EndpointsTable+` e ON e.id = d.endpoint_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND e.active
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING wd.id, wd.event_id, wd.event_type, wd.payload, wd.attempts, we.url, we.secret,
+			CASE WHEN we.previous_secret_expires_at > NOW() THEN we.previous_secret ELSE '' END, wd.tenant_id`,
+		d.batchSize, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []claimed
+	for rows.Next() {
+		var c claimed
+		if err := rows.Scan(&c.id, &c.eventID, &c.eventType, &c.payload, &c.attempt, &c.url, &c.secret, &c.previous, &c.tenantID); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, c)
+	}
+	return deliveries, rows.Err()
+}
+
+// post sends a delivery, any non 2xx response is a failure
+func (d *Dispatcher) post(ctx context.Context, delivery claimed) result {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return result{err: fmt.Errorf("failed to create webhook request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-modules-webhook/1")
+	req.Header.Set(HeaderEventID, delivery.eventID)
+	req.Header.Set(HeaderEventType, delivery.eventType)
+	req.Header.Set(HeaderDelivery, delivery.id)
+	if delivery.previous != "" {
+		req.Header.Set(HeaderSignature, SignAll(start, delivery.payload, delivery.secret, delivery.previous))
+	} else {
+		req.Header.Set(HeaderSignature, Sign(delivery.secret, start, delivery.payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return result{duration: time.Since(start), err: fmt.Errorf("failed to post webhook: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	res := result{statusCode: resp.StatusCode, response: string(response), duration: time.Since(start)}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		res.err = fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return res
+}
+
+// record logs the attempt and completes, reschedules or dead-letters the delivery
+func (d *Dispatcher) record(ctx context.Context, delivery claimed, res result) error {
+	errMessage := ""
+	if res.err != nil {
+		errMessage = res.err.Error()
+	}
+
+	return utils.ExecTxContext(ctx, d.db, func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `INSERT INTO `+AttemptsTable+` (delivery_id, attempt, status_code, error, response_body, duration_ms)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			delivery.id, delivery.attempt, res.statusCode, errMessage, res.response, res.duration.Milliseconds())
+		if err != nil {
+			return fmt.Errorf("failed to log webhook attempt: %w", err)
+		}
+
+		switch {
+		case res.err == nil:
+			_, err = tx.Exec(ctx, `UPDATE `+DeliveriesTable+` SET status = $2, last_status_code = $3, last_error = '', delivered_at = NOW()
+				WHERE id = $1`, delivery.id, StatusSucceeded, res.statusCode)
+		case delivery.attempt >= d.policy.Attempts():
+			logger.Named("webhook").WarnContext(ctx, "webhook delivery failed permanently", "delivery_id", delivery.id,
+				"tenant_id", delivery.tenantID, "event_type", delivery.eventType, "attempts", delivery.attempt, "error", res.err)
+			_, err = tx.Exec(ctx, `UPDATE `+DeliveriesTable+` SET status = $2, last_status_code = $3, last_error = $4
+				WHERE id = $1`, delivery.id, StatusFailed, res.statusCode, errMessage)
+		default:
+			retryAt := time.Now().Add(d.policy.Delay(delivery.attempt - 1))
+			_, err = tx.Exec(ctx, `UPDATE `+DeliveriesTable+` SET next_attempt_at = $2, last_status_code = $3, last_error = $4
+				WHERE id = $1`, delivery.id, retryAt, res.statusCode, errMessage)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update webhook delivery: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of webhook requests
+const (
+	HeaderSignature = "X-Webhook-Signature" // "t=<unix seconds>,v1=<hex HMAC-SHA256>", one v1 per secret while rotating
+	HeaderEventID   = "X-Webhook-ID"        // event id, the same for every delivery and attempt of an event
+	HeaderEventType = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery" // delivery id, the same for every attempt of a delivery
+)
+
+// Errors returned by Verify
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrSignatureExpired = errors.New("webhook signature timestamp outside tolerance")
+)
+
+// DefaultTolerance is the accepted age of a signature, bounding replays of captured requests
+const DefaultTolerance = 5 * time.Minute
+
+// Sign returns the signature header value of body sent at timestamp
+// The HMAC-SHA256 covers "<timestamp>.<body>", so the timestamp cannot be changed without the secret
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + signature(secret, t, body)
+}
+
+// SignAll returns the signature header value of body sent at timestamp with a v1 signature per secret
+// Verify accepts the header when any of them matches, so deliveries keep verifying while a secret is rotated
+func SignAll(timestamp time.Time, body []byte, secrets ...string) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	var sb strings.Builder
+	sb.WriteString("t=" + t)
+	for _, secret := range secrets {
+		sb.WriteString(",v1=" + signature(secret, t, body))
+	}
+	return sb.String()
+}
+
+// signature returns the hex HMAC-SHA256 of "<t>.<body>"
+func signature(secret, t string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header against body with any of secrets, pass the previous secret too while
+// rotating. A tolerance of 0 uses DefaultTolerance
+func Verify(header string, body []byte, tolerance time.Duration, secrets ...string) error {
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+
+	var t string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	for _, secret := range secrets {
+		expected := []byte(signature(secret, t, body))
+		for _, candidate := range signatures {
+			if hmac.Equal(expected, []byte(candidate)) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// VerifyRequest reads the body of a webhook request, up to 1MB, and verifies its signature
+// The body is returned and also restored on r so handlers can decode it again
+func VerifyRequest(r *http.Request, tolerance time.Duration, secrets ...string) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := Verify(r.Header.Get(HeaderSignature), body, tolerance, secrets...); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
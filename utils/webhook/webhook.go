@@ -0,0 +1,315 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Tables used by the webhook subsystem
+const (
+	EndpointsTable  = "webhook_endpoints"
+	DeliveriesTable = "webhook_deliveries"
+	AttemptsTable   = "webhook_delivery_attempts"
+)
+
+// Migration creates the webhook tables, copy it into a service migration or run it with EnsureTables
+const Migration = `CREATE TABLE IF NOT EXISTS webhook_endpoints (
+	id UUID PRIMARY KEY,
+	tenant_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	previous_secret TEXT NOT NULL DEFAULT '',
+	previous_secret_expires_at TIMESTAMPTZ,
+	event_types TEXT[] NOT NULL DEFAULT '{}',
+	description TEXT NOT NULL DEFAULT '',
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+ALTER TABLE webhook_endpoints ADD COLUMN IF NOT EXISTS previous_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE webhook_endpoints ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS webhook_endpoints_tenant_idx ON webhook_endpoints (tenant_id);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id UUID PRIMARY KEY,
+	endpoint_id UUID NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+	tenant_id TEXT NOT NULL,
+	event_id UUID NOT NULL,
+	event_type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_status_code INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	delivered_at TIMESTAMPTZ,
+	UNIQUE (endpoint_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_tenant_idx ON webhook_deliveries (tenant_id, created_at);
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+	id BIGSERIAL PRIMARY KEY,
+	delivery_id UUID NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+	attempt INT NOT NULL,
+	status_code INT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	response_body TEXT NOT NULL DEFAULT '',
+	duration_ms INT NOT NULL DEFAULT 0,
+	attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_idx ON webhook_delivery_attempts (delivery_id);`
+
+// EnsureTables creates the webhook tables if they do not exist
+func EnsureTables(ctx context.Context, db utils.PGXPool) error {
+	if _, err := db.Exec(ctx, Migration); err != nil {
+		return fmt.Errorf("failed to create webhook tables: %w", err)
+	}
+	return nil
+}
+
+// Execer executes statements, satisfied by utils.PGXPool and pgx.Tx
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Event is a notification sent to the endpoints of a tenant subscribed to its type
+type Event struct {
+	ID         string // unique event id, receivers use it to drop duplicates
+	TenantID   string
+	Type       string          // e.g. "invoice.paid"
+	Data       json.RawMessage // JSON payload, sent as the "data" field of the body
+	OccurredAt time.Time
+}
+
+// NewEvent creates an event with a new id and data marshaled to JSON
+func NewEvent(tenantID, eventType string, data interface{}) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to marshal webhook data: %w", err)
+	}
+	return Event{ID: uuid.NewString(), TenantID: tenantID, Type: eventType, Data: raw, OccurredAt: time.Now()}, nil
+}
+
+// Enqueue creates a pending delivery of the event for every active endpoint of its tenant subscribed to its type
+// and returns how many were created. Pass a pgx.Tx to enqueue the event if and only if the transaction commits,
+// enqueuing the same event twice is a no-op
+func Enqueue(ctx context.Context, db Execer, event Event) (int64, error) {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	if event.TenantID == "" || event.Type == "" {
+		return 0, errors.New("webhook event requires a tenant and a type")
+	}
+
+	payload, err := json.Marshal(body{ID: event.ID, Type: event.Type, CreatedAt: event.OccurredAt.UTC(), Data: event.Data})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	tag, err := db.Exec(ctx, `INSERT INTO `+DeliveriesTable+` (id, endpoint_id, tenant_id, event_id, event_type, payload)
+		SELECT gen_random_uuid(), id, tenant_id, $2::uuid, $3::text, $4::jsonb FROM `+EndpointsTable+`
+		WHERE tenant_id = $1 AND active AND (cardinality(event_types) = 0 OR $3 = ANY(event_types))
+		ON CONFLICT (endpoint_id, event_id) DO NOTHING`,
+		event.TenantID, event.ID, event.Type, payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook event: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// body is the JSON document posted to endpoints
+type body struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Endpoint is a URL of a tenant receiving the events of the listed types, all types when EventTypes is empty
+type Endpoint struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenant_id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"` // signing secret, only shown to the tenant on creation and rotation
+	EventTypes  []string  `json:"event_types"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Registry manages the endpoints of tenants, every method is scoped to a tenant
+type Registry struct {
+	db utils.PGXPool
+}
+
+// NewRegistry creates a registry storing endpoints in db
+func NewRegistry(db utils.PGXPool) *Registry {
+	return &Registry{db: db}
+}
+
+// Create registers an active endpoint, generating its id and, when empty, its secret
+func (r *Registry) Create(ctx context.Context, endpoint Endpoint) (Endpoint, error) {
+	if err := validateURL(endpoint.URL); err != nil {
+		return Endpoint{}, err
+	}
+	if endpoint.TenantID == "" {
+		return Endpoint{}, utils.NewValidationFailedError("webhook endpoint requires a tenant")
+	}
+	if endpoint.Secret == "" {
+		secret, err := GenerateSecret()
+		if err != nil {
+			return Endpoint{}, err
+		}
+		endpoint.Secret = secret
+	}
+	if endpoint.EventTypes == nil {
+		endpoint.EventTypes = []string{}
+	}
+	endpoint.ID = uuid.NewString()
+	endpoint.Active = true
+
+	err := r.db.QueryRow(ctx, `INSERT INTO `+EndpointsTable+` (id, tenant_id, url, secret, event_types, description)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at, updated_at`,
+		endpoint.ID, endpoint.TenantID, endpoint.URL, endpoint.Secret, endpoint.EventTypes, endpoint.Description,
+	).Scan(&endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+const endpointColumns = "id, tenant_id, url, secret, event_types, description, active, created_at, updated_at"
+
+// scanEndpoint scans a row of endpointColumns
+func scanEndpoint(row pgx.Row) (Endpoint, error) {
+	var e Endpoint
+	err := row.Scan(&e.ID, &e.TenantID, &e.URL, &e.Secret, &e.EventTypes, &e.Description, &e.Active, &e.CreatedAt, &e.UpdatedAt)
+	return e, err
+}
+
+// Get returns an endpoint of the tenant, or an error matching utils.ErrNotFound
+func (r *Registry) Get(ctx context.Context, tenantID, id string) (Endpoint, error) {
+	endpoint, err := scanEndpoint(r.db.QueryRow(ctx, `SELECT `+endpointColumns+` FROM `+EndpointsTable+`
+		WHERE tenant_id = $1 AND id::text = $2`, tenantID, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Endpoint{}, utils.WrapError(err, utils.ErrNotFound, "webhook endpoint not found")
+	}
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+// List returns the endpoints of the tenant, oldest first
+func (r *Registry) List(ctx context.Context, tenantID string) ([]Endpoint, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+endpointColumns+` FROM `+EndpointsTable+`
+		WHERE tenant_id = $1 ORDER BY created_at, id`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := []Endpoint{}
+	for rows.Next() {
+		endpoint, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+// Update changes the URL, event types, description and active flag of an endpoint
+func (r *Registry) Update(ctx context.Context, endpoint Endpoint) (Endpoint, error) {
+	if err := validateURL(endpoint.URL); err != nil {
+		return Endpoint{}, err
+	}
+	if endpoint.EventTypes == nil {
+		endpoint.EventTypes = []string{}
+	}
+
+	updated, err := scanEndpoint(r.db.QueryRow(ctx, `UPDATE `+EndpointsTable+`
+		SET url = $3, event_types = $4, description = $5, active = $6, updated_at = NOW()
+		WHERE tenant_id = $1 AND id::text = $2 RETURNING `+endpointColumns,
+		endpoint.TenantID, endpoint.ID, endpoint.URL, endpoint.EventTypes, endpoint.Description, endpoint.Active))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Endpoint{}, utils.WrapError(err, utils.ErrNotFound, "webhook endpoint not found")
+	}
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("failed to update webhook endpoint: %w", err)
+	}
+	return updated, nil
+}
+
+// DefaultSecretOverlap is how long RotateSecret keeps signing with the previous secret too
+const DefaultSecretOverlap = 24 * time.Hour
+
+// RotateSecret replaces the secret of an endpoint and returns the new one, see RotateSecretWithOverlap
+func (r *Registry) RotateSecret(ctx context.Context, tenantID, id string) (string, error) {
+	return r.RotateSecretWithOverlap(ctx, tenantID, id, DefaultSecretOverlap)
+}
+
+// RotateSecretWithOverlap replaces the secret of an endpoint and returns the new one
+// Deliveries carry a signature with the new secret and one with the previous secret until overlap elapses,
+// so receivers keep verifying with the previous secret until they are configured with the new one
+func (r *Registry) RotateSecretWithOverlap(ctx context.Context, tenantID, id string, overlap time.Duration) (string, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	tag, err := r.db.Exec(ctx, `UPDATE `+EndpointsTable+`
+		SET previous_secret = secret, previous_secret_expires_at = NOW() + make_interval(secs => $4),
+			secret = $3, updated_at = NOW()
+		WHERE tenant_id = $1 AND id::text = $2`, tenantID, id, secret, max(overlap, 0).Seconds())
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return "", utils.NewNotFoundError("webhook endpoint not found")
+	}
+	return secret, nil
+}
+
+// Delete removes an endpoint with its deliveries, missing endpoints are ignored
+func (r *Registry) Delete(ctx context.Context, tenantID, id string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM `+EndpointsTable+` WHERE tenant_id = $1 AND id::text = $2`, tenantID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	return nil
+}
+
+// GenerateSecret returns a random signing secret prefixed with "whsec_"
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validateURL accepts absolute http and https URLs
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return utils.NewValidationFailedError("webhook endpoint URL must be an absolute http or https URL")
+	}
+	return nil
+}
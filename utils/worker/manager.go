@@ -0,0 +1,270 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils"
+	"github.com/gadhittana01/go-modules-v3/utils/health"
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+	"github.com/gadhittana01/go-modules-v3/utils/retry"
+)
+
+// Worker states reported by Status
+const (
+	StateStarting   = "starting"
+	StateRunning    = "running"
+	StateRestarting = "restarting"
+	StateStopped    = "stopped"
+	StateDone       = "done"
+)
+
+// Status is the health of one worker
+type Status struct {
+	Name          string    `json:"name"`
+	State         string    `json:"state"`
+	Healthy       bool      `json:"healthy"`
+	Restarts      int       `json:"restarts"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorAt   time.Time `json:"last_error_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	StartedAt     time.Time `json:"started_at"`
+}
+
+// healthyRun is how long a worker must run before a failure restarts it without the accumulated backoff
+const healthyRun = time.Minute
+
+// state is the mutable status of a worker
+type state struct {
+	mu         sync.Mutex
+	status     Status
+	staleAfter time.Duration
+}
+
+// ManagerOption configures a Manager
+type ManagerOption func(*Manager)
+
+// WithRestartPolicy sets the backoff between restarts of a failed worker (default 1s up to 1m)
+// MaxAttempts is ignored, workers are restarted until the manager stops
+func WithRestartPolicy(policy retry.Policy) ManagerOption {
+	return func(m *Manager) {
+		m.restart = policy
+	}
+}
+
+// Manager runs workers, restarts them when they fail or panic and reports their health
+// Run it with the app runner: runner.Go(manager.Run)
+type Manager struct {
+	restart retry.Policy
+
+	mu      sync.Mutex
+	workers []Worker
+	states  map[string]*state
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewManager creates an empty manager
+func NewManager(opts ...ManagerOption) *Manager {
+	m := &Manager{
+		restart: retry.Policy{Backoff: time.Second, MaxBackoff: time.Minute, Multiplier: 2, Jitter: 0.2},
+		states:  map[string]*state{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Add registers workers, names must be unique
+func (m *Manager) Add(workers ...Worker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, w := range workers {
+		if _, ok := m.states[w.Name()]; ok {
+			panic(fmt.Sprintf("worker %q registered twice", w.Name()))
+		}
+		s := &state{status: Status{Name: w.Name(), State: StateStopped}}
+		if st, ok := w.(staler); ok {
+			s.staleAfter = st.StaleAfter()
+		}
+		m.states[w.Name()] = s
+		m.workers = append(m.workers, w)
+	}
+}
+
+// Run runs the workers until ctx is canceled and waits for them to return
+func (m *Manager) Run(ctx context.Context) error {
+	m.Start(ctx)
+	<-ctx.Done()
+	m.Stop()
+	return nil
+}
+
+// Start runs the workers in the background until Stop is called or ctx is done
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, w := range m.workers {
+		m.wg.Add(1)
+		go func(w Worker, s *state) {
+			defer m.wg.Done()
+			m.supervise(ctx, w, s)
+		}(w, m.states[w.Name()])
+	}
+}
+
+// Stop cancels the workers and waits for them to return
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	cancel := m.cancel
+	m.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	m.wg.Wait()
+}
+
+// supervise runs w until ctx is canceled, restarting it with backoff after errors and panics
+func (m *Manager) supervise(ctx context.Context, w Worker, s *state) {
+	log := logger.Named("worker").With("worker", w.Name())
+	s.update(func(status *Status) {
+		status.State = StateStarting
+		status.StartedAt = time.Now()
+	})
+
+	for failures := 0; ; {
+		s.update(func(status *Status) { status.State = StateRunning })
+		started := time.Now()
+		err := runSafely(contextWithState(ctx, s), w)
+		// A worker that ran fine for a while starts over from the initial backoff
+		if time.Since(started) > healthyRun {
+			failures = 0
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			s.update(func(status *Status) { status.State = StateStopped })
+			return
+		case err == nil:
+			log.Info("worker finished")
+			s.update(func(status *Status) { status.State = StateDone })
+			return
+		}
+
+		delay := m.restart.Delay(failures)
+		failures++
+		log.Error("worker failed, restarting", "error", err, "restarts", failures, "delay", delay)
+		s.update(func(status *Status) {
+			status.State = StateRestarting
+			status.Restarts++
+			status.LastError = err.Error()
+			status.LastErrorAt = time.Now()
+		})
+		if !sleep(ctx, delay) {
+			s.update(func(status *Status) { status.State = StateStopped })
+			return
+		}
+	}
+}
+
+// runSafely runs w, converting a panic into an error reported to the global error reporter if any
+func runSafely(ctx context.Context, w Worker) (err error) {
+	defer utils.RecoverAndReport(ctx, "worker "+w.Name(), &err)
+	return w.Run(ctx)
+}
+
+// recordError records the error of a ticker run in the worker status
+func recordError(ctx context.Context, err error) {
+	s, _ := ctx.Value(stateKey{}).(*state)
+	if s != nil {
+		s.update(func(status *Status) {
+			status.LastError = err.Error()
+			status.LastErrorAt = time.Now()
+		})
+	}
+}
+
+// Statuses returns the status of every worker sorted by name
+func (m *Manager) Statuses() []Status {
+	m.mu.Lock()
+	states := make([]*state, 0, len(m.states))
+	for _, s := range m.states {
+		states = append(states, s)
+	}
+	m.mu.Unlock()
+
+	statuses := make([]Status, len(states))
+	now := time.Now()
+	for i, s := range states {
+		statuses[i] = s.snapshot(now)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Checker returns a health checker failing while a worker is restarting or missed its heartbeats
+// Add it as a liveness check so a stuck worker gets the instance restarted
+func (m *Manager) Checker(name string) health.Checker {
+	return health.NewChecker(name, func(ctx context.Context) error {
+		var errs []error
+		for _, status := range m.Statuses() {
+			if !status.Healthy {
+				errs = append(errs, fmt.Errorf("worker %s is %s: %s", status.Name, status.State, status.LastError))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// update changes the status under the lock
+func (s *state) update(fn func(status *Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.status)
+}
+
+// snapshot returns the status with Healthy computed at now
+func (s *state) snapshot(now time.Time) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	status.Healthy = status.State != StateRestarting
+	if status.State == StateRunning && s.staleAfter > 0 {
+		last := status.LastHeartbeat
+		if last.IsZero() {
+			last = status.StartedAt
+		}
+		if now.Sub(last) > s.staleAfter {
+			status.Healthy = false
+			if status.LastError == "" {
+				status.LastError = "no heartbeat since " + last.Format(time.RFC3339)
+			}
+		}
+	}
+	return status
+}
+
+// stateKey carries the state of the running worker in its context
+type stateKey struct{}
+
+func contextWithState(ctx context.Context, s *state) context.Context {
+	return context.WithValue(ctx, stateKey{}, s)
+}
+
+// Heartbeat records progress of the worker running with ctx, workers with a stale threshold (see Every)
+// are reported unhealthy when they stop calling it
+func Heartbeat(ctx context.Context) {
+	if s, ok := ctx.Value(stateKey{}).(*state); ok {
+		s.update(func(status *Status) { status.LastHeartbeat = time.Now() })
+	}
+}
@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/gadhittana01/go-modules-v3/utils/logger"
+)
+
+// Worker is a background process run by a Manager
+// Run blocks until ctx is canceled; returning an error or panicking restarts it with backoff,
+// returning nil before ctx is canceled means the work is done
+type Worker interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// funcWorker adapts a function to Worker
+type funcWorker struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func (w funcWorker) Name() string {
+	return w.name
+}
+
+func (w funcWorker) Run(ctx context.Context) error {
+	return w.run(ctx)
+}
+
+// New creates a worker from a function
+func New(name string, run func(ctx context.Context) error) Worker {
+	return funcWorker{name: name, run: run}
+}
+
+// StartStopper is implemented by components running their own loop, like outbox.Relay,
+// utils.TempCleanupWorker, utils.PGListener and utils.LayeredCache
+type StartStopper interface {
+	Start(ctx context.Context)
+	Stop()
+}
+
+// FromStartStop adapts a StartStopper, it is started with the manager and stopped when ctx is canceled
+func FromStartStop(name string, s StartStopper) Worker {
+	return New(name, func(ctx context.Context) error {
+		s.Start(ctx)
+		<-ctx.Done()
+		s.Stop()
+		return nil
+	})
+}
+
+// tickerWorker runs a function at jittered intervals
+type tickerWorker struct {
+	name      string
+	interval  time.Duration
+	jitter    float64
+	immediate bool
+	fn        func(ctx context.Context) error
+}
+
+// TickerOption configures Every
+type TickerOption func(*tickerWorker)
+
+// WithJitter randomizes each interval by the fraction in both directions (default 0.1 = ±10%)
+// so replicas started together do not hit the database at the same time
+func WithJitter(jitter float64) TickerOption {
+	return func(w *tickerWorker) {
+		w.jitter = jitter
+	}
+}
+
+// WithDelayedStart waits one interval before the first run instead of running immediately
+func WithDelayedStart() TickerOption {
+	return func(w *tickerWorker) {
+		w.immediate = false
+	}
+}
+
+// Every creates a worker running fn every interval, e.g. a cleanup or cache warm-up job
+// Errors are logged and reported in the worker status without stopping the loop, successful runs
+// count as heartbeats and the worker is reported unhealthy after three intervals without one
+func Every(name string, interval time.Duration, fn func(ctx context.Context) error, opts ...TickerOption) Worker {
+	w := &tickerWorker{name: name, interval: interval, jitter: 0.1, immediate: true, fn: fn}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *tickerWorker) Name() string {
+	return w.name
+}
+
+// StaleAfter implements staler
+func (w *tickerWorker) StaleAfter() time.Duration {
+	return 3 * w.interval
+}
+
+func (w *tickerWorker) Run(ctx context.Context) error {
+	if !w.immediate && !sleep(ctx, w.next()) {
+		return nil
+	}
+
+	for {
+		if err := w.fn(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			recordError(ctx, err)
+			logger.Named("worker").WarnContext(ctx, "worker run failed", "worker", w.name, "error", err)
+		} else {
+			Heartbeat(ctx)
+		}
+
+		if !sleep(ctx, w.next()) {
+			return nil
+		}
+	}
+}
+
+// next returns the jittered delay before the next run
+func (w *tickerWorker) next() time.Duration {
+	delay := float64(w.interval)
+	if w.jitter > 0 {
+		delay += delay * w.jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// staler is implemented by workers expected to heartbeat regularly
+type staler interface {
+	StaleAfter() time.Duration
+}
+
+// sleep waits for d and reports false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}